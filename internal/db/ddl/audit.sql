@@ -0,0 +1,16 @@
+-- DDL to create the audit log table
+--
+-- Rows are only ever inserted, except when a user is deleted along with all their data. id_user has no foreign key so
+-- that events about unknown or deleted users can be kept. details is a JSON object whose fields depend on the event
+CREATE TABLE IF NOT EXISTS audit_event (
+    id         BIGINT       NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    id_user    INTEGER      NULL,
+    id_actor   INTEGER      NULL,
+    event      VARCHAR(32)  NOT NULL,
+    ip         VARCHAR(45)  NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    details    TEXT         NULL,
+    created_at DATETIME     NOT NULL,
+    KEY (id_user, id),
+    KEY (event, id)
+);
@@ -0,0 +1,49 @@
+-- DDL to create the refresh token table
+--
+-- Tokens are stored hashed. Each use revokes the token and creates a new one in the same family
+CREATE TABLE IF NOT EXISTS refresh_token (
+    id         INTEGER  NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    id_user    INTEGER  NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    family     CHAR(22) NOT NULL,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME NULL,
+    FOREIGN KEY (id_user) REFERENCES user (id),
+    UNIQUE KEY (token_hash),
+    KEY (family)
+);
+
+-- DDL to create the revoked auth token table
+--
+-- Also holds used two-factor challenge tokens. Rows can be deleted once the token expires
+CREATE TABLE IF NOT EXISTS revoked_token (
+    jti        CHAR(22) NOT NULL PRIMARY KEY,
+    expires_at DATETIME NOT NULL
+);
+
+-- DDL to create the password reset token table
+--
+-- Tokens are stored hashed and can only be used once, before they expire
+CREATE TABLE IF NOT EXISTS password_reset_token (
+    id         INTEGER  NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    id_user    INTEGER  NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at    DATETIME NULL,
+    FOREIGN KEY (id_user) REFERENCES user (id),
+    UNIQUE KEY (token_hash)
+);
+
+-- DDL to create the two-factor authentication recovery code table
+--
+-- Codes are stored hashed and can only be used once
+CREATE TABLE IF NOT EXISTS recovery_code (
+    id        INTEGER  NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    id_user   INTEGER  NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at   DATETIME NULL,
+    FOREIGN KEY (id_user) REFERENCES user (id),
+    UNIQUE KEY (id_user, code_hash)
+);
@@ -1,9 +1,58 @@
+-- DDL to create the versus match table
+--
+-- Each player of a match has their own game referencing it, created when the match ends
+CREATE TABLE IF NOT EXISTS versus_match (
+    id           INTEGER  NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    player_count INTEGER  NOT NULL,
+    started_at   DATETIME NOT NULL,
+    finished_at  DATETIME NOT NULL
+);
+
+-- DDL to create the challenge table
+--
+-- Everyone who redeems a challenge's code plays its words in their own game referencing it
+CREATE TABLE IF NOT EXISTS challenge (
+    id           INTEGER     NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    code         VARCHAR(32) NOT NULL UNIQUE,
+    id_user      INTEGER     NOT NULL,
+    word_length  INTEGER     NOT NULL,
+    word_count   INTEGER     NOT NULL,
+    custom_words BOOLEAN     NOT NULL DEFAULT FALSE,
+    hard_mode    BOOLEAN     NOT NULL DEFAULT FALSE,
+    created_at   DATETIME    NOT NULL,
+    FOREIGN KEY (id_user) REFERENCES user (id)
+);
+
+-- DDL to create the challenge word table
+CREATE TABLE IF NOT EXISTS challenge_word (
+    id_challenge INTEGER NOT NULL,
+    word         TEXT    NOT NULL,
+    idx          INTEGER NOT NULL,
+    FOREIGN KEY (id_challenge) REFERENCES challenge (id)
+);
+
 -- DDL to create the game table
+--
+-- A game is active while its result is NULL
 CREATE TABLE IF NOT EXISTS game (
-    id         INTEGER  NOT NULL PRIMARY KEY AUTO_INCREMENT,
-    id_user    INTEGER  NOT NULL,
-    is_active  BOOLEAN  NOT NULL DEFAULT TRUE,
-    FOREIGN KEY (id_user) REFERENCES user (id)
+    id                 INTEGER     NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    id_user            INTEGER     NOT NULL,
+    word_length        INTEGER     NOT NULL,
+    word_count         INTEGER     NOT NULL,
+    daily_date         DATE        NULL,
+    allow_free_guesses BOOLEAN     NOT NULL DEFAULT FALSE,
+    unranked           BOOLEAN     NOT NULL DEFAULT FALSE,
+    hard_mode          BOOLEAN     NOT NULL DEFAULT FALSE,
+    result             VARCHAR(16) NULL,
+    started_at         DATETIME    NOT NULL,
+    finished_at        DATETIME    NULL,
+    id_versus_match    INTEGER     NULL,
+    id_challenge       INTEGER     NULL,
+    FOREIGN KEY (id_user) REFERENCES user (id),
+    FOREIGN KEY (id_versus_match) REFERENCES versus_match (id),
+    FOREIGN KEY (id_challenge) REFERENCES challenge (id),
+    UNIQUE KEY (id_user, daily_date, word_count),
+    UNIQUE KEY (id_user, id_challenge)
 );
 
 -- DDL to create the game word table
@@ -17,8 +66,10 @@ CREATE TABLE IF NOT EXISTS game_word (
 
 -- DDL to create the game attempt table
 CREATE TABLE IF NOT EXISTS game_attempt (
-    id_game INTEGER NOT NULL,
-    attempt TEXT    NOT NULL,
-    idx     INTEGER NOT NULL,
-    FOREIGN KEY (id_game) REFERENCES game (id)
+    id_game    INTEGER  NOT NULL,
+    attempt    TEXT     NOT NULL,
+    idx        INTEGER  NOT NULL,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (id_game) REFERENCES game (id),
+    UNIQUE KEY (id_game, idx)
 );
@@ -0,0 +1,14 @@
+-- DDL to create the lobby table
+--
+-- Used as the lobby store shared by every server instance. Each lobby is kept as JSON, and its version is incremented
+-- whenever it's changed, so that concurrent changes can be detected. round_ends_at is only set while a round is being
+-- played, so that rounds whose time is up can be found and ended
+CREATE TABLE IF NOT EXISTS lobby (
+    code          VARCHAR(16) NOT NULL PRIMARY KEY,
+    data          MEDIUMTEXT  NOT NULL,
+    version       BIGINT      NOT NULL,
+    round_ends_at DATETIME    NULL,
+    updated_at    DATETIME    NOT NULL,
+    KEY (round_ends_at),
+    KEY (updated_at)
+);
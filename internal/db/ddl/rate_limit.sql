@@ -0,0 +1,11 @@
+-- DDL to create the rate limit bucket table
+--
+-- Used as the rate limit store shared by every server instance. Rows can be deleted once the bucket is full again,
+-- after full_at
+CREATE TABLE IF NOT EXISTS rate_limit_bucket (
+    bucket_key VARCHAR(255) NOT NULL PRIMARY KEY,
+    tokens     DOUBLE       NOT NULL,
+    updated_at DATETIME(6)  NOT NULL,
+    full_at    DATETIME(6)  NOT NULL,
+    KEY (full_at)
+);
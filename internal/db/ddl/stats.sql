@@ -0,0 +1,48 @@
+-- DDL to create the user stats table
+--
+-- Each row stores the stats of a user for a single word length and word count; the row with both set to 0 stores the
+-- stats of all games combined
+CREATE TABLE IF NOT EXISTS user_stats (
+    id_user         INTEGER NOT NULL,
+    word_length     INTEGER NOT NULL,
+    word_count      INTEGER NOT NULL,
+    games_played    INTEGER NOT NULL DEFAULT 0,
+    games_won       INTEGER NOT NULL DEFAULT 0,
+    games_abandoned INTEGER NOT NULL DEFAULT 0,
+    hard_mode_won   INTEGER NOT NULL DEFAULT 0,
+    current_streak  INTEGER NOT NULL DEFAULT 0,
+    max_streak      INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (id_user, word_length, word_count),
+    FOREIGN KEY (id_user) REFERENCES user (id)
+);
+
+-- DDL to create the user guess distribution table
+--
+-- Each row counts how many games of a user were won with a given number of attempts
+CREATE TABLE IF NOT EXISTS user_stats_guess (
+    id_user     INTEGER NOT NULL,
+    word_length INTEGER NOT NULL,
+    word_count  INTEGER NOT NULL,
+    attempts    INTEGER NOT NULL,
+    games       INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (id_user, word_length, word_count, attempts),
+    FOREIGN KEY (id_user) REFERENCES user (id)
+);
+
+-- DDL to create the user stats day table
+--
+-- Each row stores the stats of a user for the games of a single word length and word count finished on a single day,
+-- so that leaderboards of recent games don't have to scan them. Daily games are counted on the day of their daily game
+CREATE TABLE IF NOT EXISTS user_stats_day (
+    id_user      INTEGER NOT NULL,
+    day          DATE    NOT NULL,
+    word_length  INTEGER NOT NULL,
+    word_count   INTEGER NOT NULL,
+    daily        BOOLEAN NOT NULL,
+    games_played INTEGER NOT NULL DEFAULT 0,
+    games_won    INTEGER NOT NULL DEFAULT 0,
+    attempts_won INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (id_user, day, word_length, word_count, daily),
+    KEY (day),
+    FOREIGN KEY (id_user) REFERENCES user (id)
+);
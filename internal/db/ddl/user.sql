@@ -1,8 +1,53 @@
 -- DDL to create user table
+--
+-- Auth tokens issued before tokens_valid_after are rejected
+--
+-- failed_logins counts failed logins in a row; once it reaches the configured limit, the account can't log in until
+-- locked_until
+--
+-- totp_secret is set when the user starts enrolling in two-factor authentication, which is only required once
+-- totp_enabled is set. totp_last_step is the time step of the last code used, so that codes can't be reused
+--
+-- Guests have an empty password, so they can only keep playing with their tokens until they upgrade their account.
+-- last_active_at is updated at most once an hour, and guests are deleted once they stop being active
+--
+-- role is either 'user' or 'admin'. A user is banned while banned_at is set and banned_until is either NULL or in the
+-- future
+--
+-- name_key is the lowercase name, so that names differing only in upper/lowercase letters can't coexist
 CREATE TABLE IF NOT EXISTS user (
-    id       INTEGER     NOT NULL PRIMARY KEY AUTO_INCREMENT,
-    name     VARCHAR(32) NOT NULL,
-    password TEXT        NOT NULL,
-    score    INTEGER     NOT NULL DEFAULT 0,
-    UNIQUE KEY (name)
+    id                 INTEGER      NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name               VARCHAR(32)  NOT NULL,
+    password           TEXT         NOT NULL,
+    email              VARCHAR(254) NULL,
+    score              INTEGER      NOT NULL DEFAULT 0,
+    tokens_valid_after DATETIME     NULL,
+    failed_logins      INTEGER      NOT NULL DEFAULT 0,
+    locked_until       DATETIME     NULL,
+    is_guest           BOOLEAN      NOT NULL DEFAULT FALSE,
+    created_at         DATETIME     NULL,
+    last_active_at     DATETIME     NULL,
+    totp_secret        VARCHAR(32)  NULL,
+    totp_enabled       BOOLEAN      NOT NULL DEFAULT FALSE,
+    totp_last_step     BIGINT       NULL,
+    role               VARCHAR(16)  NOT NULL DEFAULT 'user',
+    banned_at          DATETIME     NULL,
+    banned_until       DATETIME     NULL,
+    ban_reason         VARCHAR(255) NULL,
+    name_key           VARCHAR(32)  AS (LOWER(name)) STORED,
+    UNIQUE KEY (name),
+    UNIQUE KEY (name_key),
+    UNIQUE KEY (email)
+);
+
+-- DDL to create the user name history table
+--
+-- Each row stores a name a user had until changed_at
+CREATE TABLE IF NOT EXISTS user_name_history (
+    id         INTEGER     NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    id_user    INTEGER     NOT NULL,
+    name       VARCHAR(32) NOT NULL,
+    changed_at DATETIME    NOT NULL,
+    FOREIGN KEY (id_user) REFERENCES user (id),
+    KEY (id_user, changed_at)
 );
@@ -0,0 +1,75 @@
+package entities
+
+import "time"
+
+// AdminUserResponse is used in admin endpoints to send the data needed to manage a user
+type AdminUserResponse struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Email       *string    `json:"email"`
+	Role        Role       `json:"role"`
+	IsGuest     bool       `json:"is_guest"`
+	Score       uint32     `json:"score"`
+	CreatedAt   *time.Time `json:"created_at"`
+	LockedUntil *time.Time `json:"locked_until"`
+	TOTPEnabled bool       `json:"totp_enabled"`
+	BannedAt    *time.Time `json:"banned_at"`
+	BannedUntil *time.Time `json:"banned_until"`
+	BanReason   *string    `json:"ban_reason"`
+}
+
+func (u User) ToAdminResponse() AdminUserResponse {
+	return AdminUserResponse{
+		ID:          u.ID,
+		Name:        u.Name,
+		Email:       u.Email,
+		Role:        u.Role,
+		IsGuest:     u.IsGuest,
+		Score:       u.Score,
+		CreatedAt:   u.CreatedAt,
+		LockedUntil: u.LockedUntil,
+		TOTPEnabled: u.TOTPEnabled,
+		BannedAt:    u.BannedAt,
+		BannedUntil: u.BannedUntil,
+		BanReason:   u.BanReason,
+	}
+}
+
+// AdminUserList is used in admin endpoints to send a page of users
+type AdminUserList struct {
+	// Users is the requested page of users
+	Users []AdminUserResponse `json:"users"`
+
+	// Total is how many users match the search
+	Total uint32 `json:"total"`
+}
+
+// ServerStats stores counts that give an overview of the server's usage
+type ServerStats struct {
+	// Users is how many registered users exist, not counting guests
+	Users uint32 `json:"users"`
+
+	// Guests is how many guest users exist
+	Guests uint32 `json:"guests"`
+
+	// BannedUsers is how many users are currently banned
+	BannedUsers uint32 `json:"banned_users"`
+
+	// UsersToday is how many users were created today (UTC)
+	UsersToday uint32 `json:"users_today"`
+
+	// Games is how many games were ever started
+	Games uint32 `json:"games"`
+
+	// ActiveGames is how many games are still being played
+	ActiveGames uint32 `json:"active_games"`
+
+	// GamesToday is how many games were started today (UTC)
+	GamesToday uint32 `json:"games_today"`
+
+	// StartedAt is when the server started
+	StartedAt time.Time `json:"started_at"`
+
+	// UptimeSeconds is how long the server has been running
+	UptimeSeconds int64 `json:"uptime_seconds"`
+}
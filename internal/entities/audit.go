@@ -0,0 +1,72 @@
+package entities
+
+import "time"
+
+type AuditEventType string
+
+// Events of the users' own actions
+const (
+	AuditEventRegister             AuditEventType = "register"
+	AuditEventGuestUpgrade         AuditEventType = "guest_upgrade"
+	AuditEventLogin                AuditEventType = "login"
+	AuditEventLoginFailure         AuditEventType = "login_failure"
+	AuditEventLogout               AuditEventType = "logout"
+	AuditEventLogoutAll            AuditEventType = "logout_all"
+	AuditEventRefreshTokenReuse    AuditEventType = "refresh_token_reuse"
+	AuditEventNameChange           AuditEventType = "name_change"
+	AuditEventPasswordChange       AuditEventType = "password_change"
+	AuditEventPasswordResetRequest AuditEventType = "password_reset_request"
+	AuditEventPasswordReset        AuditEventType = "password_reset"
+	AuditEventEmailChange          AuditEventType = "email_change"
+	AuditEventTwoFactorEnable      AuditEventType = "two_factor_enable"
+	AuditEventTwoFactorDisable     AuditEventType = "two_factor_disable"
+	AuditEventAccountDelete        AuditEventType = "account_delete"
+)
+
+// Events of admin actions, where the user is the target and the actor is the admin
+const (
+	AuditEventAdminBan        AuditEventType = "admin_ban"
+	AuditEventAdminUnban      AuditEventType = "admin_unban"
+	AuditEventAdminResetScore AuditEventType = "admin_reset_score"
+	AuditEventAdminFinishGame AuditEventType = "admin_finish_game"
+	AuditEventAdminSetRole    AuditEventType = "admin_set_role"
+)
+
+// Reasons of AuditEventLoginFailure events, stored in the reason detail
+const (
+	AuditLoginFailureNotFound      = "not_found"
+	AuditLoginFailureWrongPassword = "wrong_password"
+	AuditLoginFailureInvalidCode   = "invalid_code"
+	AuditLoginFailureBanned        = "banned"
+)
+
+// AuditEvent is a security-relevant event stored in the audit log
+type AuditEvent struct {
+	ID int64 `json:"id"`
+
+	// UserID is the user the event is about; nil if unknown, e.g. a login with an unknown name
+	UserID *int64 `json:"user_id"`
+
+	// ActorID is the user who caused the event, when it's not the user themselves, e.g. an admin
+	ActorID *int64 `json:"actor_id,omitempty"`
+
+	Type AuditEventType `json:"type"`
+
+	// IP and UserAgent identify the client that sent the request which caused the event
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+
+	// Details stores extra data about the event, which depends on its type
+	Details map[string]string `json:"details,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditFilter stores which events are returned from the audit log
+type AuditFilter struct {
+	// UserID filters events about a single user; nil returns events about every user
+	UserID *int64
+
+	// Type filters events of a single type; empty returns events of every type
+	Type AuditEventType
+}
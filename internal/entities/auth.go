@@ -0,0 +1,60 @@
+package entities
+
+import "time"
+
+// AuthToken stores the claims of a verified auth token
+type AuthToken struct {
+	// ID is the token's unique identifier (jti), used to revoke it
+	ID string
+
+	// UserID is the identifier of the user the token was issued to
+	UserID int64
+
+	// IssuedAt is when the token was issued
+	IssuedAt time.Time
+
+	// ExpiresAt is when the token stops being valid
+	ExpiresAt time.Time
+}
+
+// AuthTokens stores the tokens issued when a user logs in
+type AuthTokens struct {
+	// AccessToken is the short-lived token used to authenticate requests
+	AccessToken string
+
+	// RefreshToken is the long-lived token used to get new tokens once the access token expires
+	RefreshToken string
+}
+
+// RefreshToken maps data from refresh tokens in the database
+type RefreshToken struct {
+	// ID is the database identifier
+	ID int64
+
+	// UserID is the identifier of the user the token was issued to
+	UserID int64
+
+	// Family identifies the chain of tokens created by rotating a single login's refresh token
+	Family string
+
+	// ExpiresAt is when the token stops being valid
+	ExpiresAt time.Time
+
+	// RevokedAt is when the token was used or revoked; nil if it is still valid
+	RevokedAt *time.Time
+}
+
+// PasswordResetToken maps data from password reset tokens in the database
+type PasswordResetToken struct {
+	// ID is the database identifier
+	ID int64
+
+	// UserID is the identifier of the user whose password can be reset
+	UserID int64
+
+	// ExpiresAt is when the token stops being valid
+	ExpiresAt time.Time
+
+	// UsedAt is when the token was used; nil if it wasn't used yet
+	UsedAt *time.Time
+}
@@ -0,0 +1,84 @@
+package entities
+
+import "time"
+
+// Challenge stores a set of words chosen by a user for others to play through its code
+type Challenge struct {
+	// ID is the database identifier
+	ID int64
+
+	// Code is the opaque string the challenge is shared and redeemed with
+	Code string
+
+	// CreatorID is the identifier of the user who created the challenge
+	CreatorID int64
+
+	// CreatorName is the name of the user who created the challenge
+	CreatorName string
+
+	// Words is the list of words every player of the challenge has to find
+	Words []string
+
+	// CustomWords tells whether the words were typed by the creator, rather than chosen randomly
+	CustomWords bool
+
+	// HardMode tells whether the games of the challenge are played in hard mode
+	HardMode bool
+
+	CreatedAt time.Time
+}
+
+// ChallengeOptions stores the settings a challenge is created with. If words are provided, the word length and count
+// are taken from them
+type ChallengeOptions struct {
+	WordLength uint32   `json:"word_length"`
+	WordCount  uint32   `json:"word_count"`
+	Words      []string `json:"words"`
+	HardMode   bool     `json:"hard_mode"`
+}
+
+// ChallengeResponse is used in endpoints to send a challenge without its words
+type ChallengeResponse struct {
+	Code        string    `json:"code"`
+	CreatorName string    `json:"creator_name"`
+	WordLength  uint32    `json:"word_length"`
+	WordCount   uint32    `json:"word_count"`
+	MaxAttempts uint32    `json:"max_attempts"`
+	CustomWords bool      `json:"custom_words"`
+	HardMode    bool      `json:"hard_mode"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ChallengeResult is used in endpoints to send how a player did on a challenge, without their attempts
+type ChallengeResult struct {
+	UserName     string      `json:"user_name"`
+	AttemptCount uint32      `json:"attempt_count"`
+	Result       *GameResult `json:"result"`
+	StartedAt    time.Time   `json:"started_at"`
+	FinishedAt   *time.Time  `json:"finished_at"`
+}
+
+func (c Challenge) GetWordLength() uint32 {
+	if len(c.Words) == 0 {
+		return 0
+	}
+	return uint32(len(c.Words[0]))
+}
+
+func (c Challenge) GetWordCount() uint32 {
+	return uint32(len(c.Words))
+}
+
+// ToResponse builds the challenge as shown to anyone with its code
+func (c Challenge) ToResponse(maxAttempts uint32) ChallengeResponse {
+	return ChallengeResponse{
+		Code:        c.Code,
+		CreatorName: c.CreatorName,
+		WordLength:  c.GetWordLength(),
+		WordCount:   c.GetWordCount(),
+		MaxAttempts: maxAttempts,
+		CustomWords: c.CustomWords,
+		HardMode:    c.HardMode,
+		CreatedAt:   c.CreatedAt,
+	}
+}
@@ -0,0 +1,10 @@
+package entities
+
+// ClientInfo stores data about the client that sent a request
+type ClientInfo struct {
+	// IP is the client's IP address
+	IP string
+
+	// UserAgent is the client's User-Agent header
+	UserAgent string
+}
@@ -1,5 +1,11 @@
 package entities
 
+import "time"
+
+// DefaultAuthKeyID is the ID of the key used for tokens without a key ID, which were issued before key rotation was
+// supported
+const DefaultAuthKeyID = "default"
+
 type database struct {
 	User     string `json:"user"`
 	Password string `json:"password"`
@@ -8,14 +14,268 @@ type database struct {
 	Name     string `json:"name"`
 }
 
+// AuthKey is a key pair in the auth keyring, identified by its ID
+type AuthKey struct {
+	ID         string `json:"id"`
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key,omitempty"`
+
+	// RetiredAt is when the key stopped signing tokens. Tokens signed before it are still accepted until they expire
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+}
+
+type loginProtection struct {
+	// FreeFailures is how many failed logins an IP or account can have before having to wait between attempts
+	FreeFailures uint32 `json:"free_failures"`
+
+	// BackoffSeconds is the wait after the first failure past FreeFailures; it doubles after each further failure
+	BackoffSeconds uint32 `json:"backoff_seconds"`
+
+	// MaxBackoffSeconds caps the wait between attempts
+	MaxBackoffSeconds uint32 `json:"max_backoff_seconds"`
+
+	// LockoutFailures is how many failed logins in a row lock an account; 0 disables lockouts
+	LockoutFailures uint32 `json:"lockout_failures"`
+
+	// LockoutMinutes is how long an account stays locked
+	LockoutMinutes uint32 `json:"lockout_minutes"`
+
+	// RegistrationsPerHour is how many accounts a single IP can register per hour; 0 disables the limit
+	RegistrationsPerHour uint32 `json:"registrations_per_hour"`
+
+	// CollapseErrors makes logins with an unknown name, a wrong password or a locked account return the same status,
+	// so that names can't be enumerated
+	CollapseErrors bool `json:"collapse_errors"`
+}
+
+// Password hashing algorithms
+const (
+	PasswordHashArgon2id = "argon2id"
+	PasswordHashBcrypt   = "bcrypt"
+)
+
+type argon2Params struct {
+	// MemoryKiB is how much memory hashing a password takes, in KiB
+	MemoryKiB uint32 `json:"memory_kib"`
+
+	// Iterations is how many passes are made over the memory
+	Iterations uint32 `json:"iterations"`
+
+	// Parallelism is how many threads are used
+	Parallelism uint8 `json:"parallelism"`
+}
+
+type passwordHashing struct {
+	// Algorithm is how new passwords are hashed: PasswordHashArgon2id (the default) or PasswordHashBcrypt. Hashes
+	// made by either are still verified, and replaced on the next login if made by the other one or with other
+	// parameters
+	Algorithm string `json:"algorithm"`
+
+	// Argon2 are the argon2id parameters; each one has a default if 0
+	Argon2 argon2Params `json:"argon2"`
+
+	// BcryptCost is the bcrypt cost; defaults to 10 if 0
+	BcryptCost int `json:"bcrypt_cost"`
+}
+
+// Password character classes
+const (
+	PasswordClassUppercase = "uppercase"
+	PasswordClassLowercase = "lowercase"
+	PasswordClassDigit     = "digit"
+	PasswordClassSymbol    = "symbol"
+)
+
+type passwordPolicy struct {
+	// MinLength and MaxLength bound how many characters a password can have; default to 8 and 256 if 0. MaxLength
+	// can't be over 256
+	MinLength uint32 `json:"min_length"`
+	MaxLength uint32 `json:"max_length"`
+
+	// RequiredClasses are the kinds of characters every password needs: PasswordClassUppercase,
+	// PasswordClassLowercase, PasswordClassDigit and PasswordClassSymbol. Every class is required if not set; an
+	// empty list requires none
+	RequiredClasses []string `json:"required_classes"`
+
+	// PassphraseLength is how many characters a password needs to not require any class, so that long passphrases
+	// made of plain words are allowed; 0 disables it
+	PassphraseLength uint32 `json:"passphrase_length"`
+
+	// CommonPasswordsFile is a file with one password per line, such as a list of breached passwords, that can't be
+	// used on top of the built-in list of common passwords
+	CommonPasswordsFile string `json:"common_passwords_file"`
+
+	// AllowSimilarToName allows passwords that contain the user's name, forwards or backwards
+	AllowSimilarToName bool `json:"allow_similar_to_name"`
+}
+
 type auth struct {
+	// PublicKey and PrivateKey are a single key pair, used when Keys is empty. It is handled as a key with the ID
+	// DefaultAuthKeyID
 	PublicKey  string `json:"public_key"`
 	PrivateKey string `json:"private_key"`
+
+	// Keys is the keyring used to verify auth tokens; only the active key needs a private key
+	Keys []AuthKey `json:"keys"`
+
+	// ActiveKeyID is the ID of the key used to sign new tokens
+	ActiveKeyID string `json:"active_key_id"`
+
+	LoginProtection loginProtection `json:"login_protection"`
+
+	PasswordHashing passwordHashing `json:"password_hashing"`
+
+	PasswordPolicy passwordPolicy `json:"password_policy"`
+}
+
+type game struct {
+	// DailySecret is the server secret used to derive the daily words; changing it changes every daily game. Required
+	DailySecret string `json:"daily_secret"`
+}
+
+type leaderboard struct {
+	// MinGames is the minimum number of games a user needs to be ranked by win rate or average attempts
+	MinGames uint32 `json:"min_games"`
+}
+
+type rateLimit struct {
+	// Requests is how many requests are allowed every PerSeconds seconds; 0 disables the limit
+	Requests uint32 `json:"requests"`
+
+	PerSeconds uint32 `json:"per_seconds"`
+
+	// Burst is how many requests can be made at once; defaults to Requests if 0
+	Burst uint32 `json:"burst"`
+}
+
+// RateLimit converts the configured limit to a RateLimit counted by the provided key; returns nil if it's disabled
+func (l rateLimit) RateLimit(key RateLimitKey) *RateLimit {
+	if l.Requests == 0 || l.PerSeconds == 0 {
+		return nil
+	}
+
+	return &RateLimit{
+		Requests: l.Requests,
+		Per:      time.Duration(l.PerSeconds) * time.Second,
+		Burst:    l.Burst,
+		Key:      key,
+	}
+}
+
+// Rate limit stores
+const (
+	RateLimitStoreMemory   = "memory"
+	RateLimitStoreDatabase = "database"
+)
+
+type rateLimits struct {
+	// Store is where the rate limit buckets are kept: RateLimitStoreMemory (the default) or RateLimitStoreDatabase,
+	// which is shared by every server instance
+	Store string `json:"store"`
+
+	// FailClosed makes every limit refuse requests while the store fails, instead of allowing them. Limits of auth
+	// routes always do
+	FailClosed bool `json:"fail_closed"`
+
+	// API is the limit of requests to every /api route by each user, on top of the routes' own limits
+	API rateLimit `json:"api"`
+}
+
+type guest struct {
+	// MaxInactiveDays is how many days a guest can go without using their account before being deleted
+	MaxInactiveDays uint32 `json:"max_inactive_days"`
+}
+
+type names struct {
+	// RenameCooldownHours is how long users have to wait after changing their name to change it again; 0 disables it
+	RenameCooldownHours uint32 `json:"rename_cooldown_hours"`
+
+	// Reserved are names nobody can take, on top of the built-in ones, ignoring upper/lowercase letters
+	Reserved []string `json:"reserved"`
+
+	// BlockedWords are words no word of a name can start with, on top of the built-in profanity list
+	BlockedWords []string `json:"blocked_words"`
+}
+
+// Lobby stores
+const (
+	LobbyStoreMemory   = "memory"
+	LobbyStoreDatabase = "database"
+)
+
+type lobby struct {
+	// Store is where lobbies are kept: LobbyStoreMemory (the default) or LobbyStoreDatabase, which is shared by every
+	// server instance. With the database store, each instance checks for changes made by the others every second and
+	// sends them to its players
+	Store string `json:"store"`
+
+	// IdleMinutes is how long a lobby can go without any change before being deleted; defaults to 30
+	IdleMinutes uint32 `json:"idle_minutes"`
+}
+
+// Mail drivers
+const (
+	MailDriverSMTP = "smtp"
+	MailDriverLog  = "log"
+)
+
+type smtpServer struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+type mail struct {
+	// Driver is how emails are delivered: MailDriverSMTP, or MailDriverLog (the default), which only logs them or
+	// writes them to Dir, for local development
+	Driver string `json:"driver"`
+
+	// From is the sender address of every email
+	From string `json:"from"`
+
+	SMTP smtpServer `json:"smtp"`
+
+	// Dir is the directory where the log driver writes emails; if empty, they are only logged
+	Dir string `json:"dir"`
+
+	// PasswordResetURL is the front-end page where users choose a new password. The reset token is added to it as the
+	// token query parameter
+	PasswordResetURL string `json:"password_reset_url"`
+}
+
+type server struct {
+	// TrustProxy makes the client IP be read from the X-Forwarded-For header; only enable it behind a reverse proxy
+	TrustProxy bool `json:"trust_proxy"`
+
+	// ProxyHops is how many reverse proxies requests go through, each appending the address it got the request from
+	// to X-Forwarded-For; defaults to 1. Only used with TrustProxy
+	ProxyHops uint32 `json:"proxy_hops"`
+
+	// AllowedOrigins are the origins, such as "https://termo.example.com", of the front ends that can connect
+	// websockets, which CORS doesn't cover. The server's own origin is always allowed
+	AllowedOrigins []string `json:"allowed_origins"`
 }
 
 // Config is a struct used for loading the config.json file with all project configurations
 type Config struct {
+	Server server `json:"server"`
+
+	RateLimit rateLimits `json:"rate_limit"`
+
 	Database database `json:"db"`
 
 	Auth auth `json:"auth"`
+
+	Game game `json:"game"`
+
+	Leaderboard leaderboard `json:"leaderboard"`
+
+	Mail mail `json:"mail"`
+
+	Guest guest `json:"guest"`
+
+	Names names `json:"names"`
+
+	Lobby lobby `json:"lobby"`
 }
@@ -0,0 +1,41 @@
+package entities
+
+type UserEventType string
+
+const (
+	// UserEventGameStarted is sent with the new game, like the active game endpoint returns it
+	UserEventGameStarted UserEventType = "GAME_STARTED"
+
+	// UserEventGameAttempt is sent with each attempt registered on the active game
+	UserEventGameAttempt UserEventType = "GAME_ATTEMPT"
+
+	// UserEventGameFinished is sent when the active game is won, lost or abandoned, along with its words
+	UserEventGameFinished UserEventType = "GAME_FINISHED"
+
+	// UserEventLobbyUpdated is sent to the players of a lobby whenever it changes, with the lobby as they see it.
+	// Players who left or were removed get it one last time, without themselves in it
+	UserEventLobbyUpdated UserEventType = "LOBBY_UPDATED"
+
+	// UserEventSync is sent to subscribers who missed events that can't be replayed, so that they reload everything
+	UserEventSync UserEventType = "SYNC"
+)
+
+// UserEvent is published to every subscriber of a user, such as their other devices. IDs increase with each event, so
+// that subscribers can resume after the last event they got
+type UserEvent struct {
+	ID   uint64
+	Type UserEventType
+	Data any
+}
+
+// GameAttemptEvent is the data of UserEventGameAttempt events
+type GameAttemptEvent struct {
+	Attempt   string    `json:"attempt"`
+	GameState GameState `json:"game_state"`
+}
+
+// GameFinishedEvent is the data of UserEventGameFinished events
+type GameFinishedEvent struct {
+	Result GameResult `json:"result"`
+	Words  []string   `json:"words"`
+}
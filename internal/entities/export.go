@@ -0,0 +1,60 @@
+package entities
+
+import "time"
+
+// UserExport is the archive of every personal data of a user, sent when they export their data
+type UserExport struct {
+	ExportedAt time.Time          `json:"exported_at"`
+	Profile    UserExportProfile  `json:"profile"`
+	Stats      *UserStatsResponse `json:"stats"`
+	Games      []GameExport       `json:"games"`
+}
+
+// UserExportProfile stores the account data of a user in their data export
+type UserExportProfile struct {
+	ID               int64      `json:"id"`
+	Name             string     `json:"name"`
+	Email            *string    `json:"email"`
+	Score            uint32     `json:"score"`
+	IsGuest          bool       `json:"is_guest"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	CreatedAt        *time.Time `json:"created_at"`
+}
+
+// GameExportAttempt stores a single attempt of a game in a user's data export
+type GameExportAttempt struct {
+	Attempt   string    `json:"attempt"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GameExport stores a game in a user's data export. The words of active games are left out, so that exporting can't
+// be used to cheat
+type GameExport struct {
+	ID               int64               `json:"id"`
+	WordLength       uint32              `json:"word_length"`
+	WordCount        uint32              `json:"word_count"`
+	Words            []string            `json:"words,omitempty"`
+	Attempts         []GameExportAttempt `json:"attempts"`
+	Result           *GameResult         `json:"result"`
+	DailyDate        string              `json:"daily_date,omitempty"`
+	AllowFreeGuesses bool                `json:"allow_free_guesses"`
+	Unranked         bool                `json:"unranked"`
+	HardMode         bool                `json:"hard_mode"`
+	VersusMatchID    *int64              `json:"versus_match_id,omitempty"`
+	ChallengeID      *int64              `json:"challenge_id,omitempty"`
+	StartedAt        time.Time           `json:"started_at"`
+	FinishedAt       *time.Time          `json:"finished_at"`
+}
+
+// ToExportProfile builds the profile of the user's data export
+func (u User) ToExportProfile() UserExportProfile {
+	return UserExportProfile{
+		ID:               u.ID,
+		Name:             u.Name,
+		Email:            u.Email,
+		Score:            u.Score,
+		IsGuest:          u.IsGuest,
+		TwoFactorEnabled: u.TOTPEnabled,
+		CreatedAt:        u.CreatedAt,
+	}
+}
@@ -1,5 +1,10 @@
 package entities
 
+import "time"
+
+// DailyDateFormat is the layout used when sending daily game dates
+const DailyDateFormat = "2006-01-02"
+
 type GameLetterState int8
 type GameWordState []GameLetterState
 type GameState []GameWordState
@@ -15,38 +20,232 @@ const (
 	GameLetterStateWrong
 )
 
+type GameResult string
+
+const (
+	// GameResultWon is set when every game word was found
+	GameResultWon GameResult = "WON"
+
+	// GameResultLost is set when the user ran out of attempts
+	GameResultLost GameResult = "LOST"
+
+	// GameResultAbandoned is set when the user forfeited the game
+	GameResultAbandoned GameResult = "ABANDONED"
+
+	// GameResultExpired is set when the game was finished by the server for being inactive for too long
+	GameResultExpired GameResult = "EXPIRED"
+)
+
 // Game maps data from games in the database
 type Game struct {
 	// ID is the database identifier
 	ID int64
 
+	// UserID is the identifier of the user playing this game
+	UserID int64
+
 	// Words is a list containing all the game's chosen words
 	Words []string
 
 	// Attempts is a list containing all the user attempts on this game
 	Attempts []string
 
+	// AttemptTimes is a list containing when each attempt in Attempts was made
+	AttemptTimes []time.Time
+
 	// IsActive tells whether this game is active
 	IsActive bool
+
+	// Result tells how the game ended; nil while the game is active
+	Result *GameResult
+
+	// StartedAt is when the game was started
+	StartedAt time.Time
+
+	// FinishedAt is when the game ended; nil while the game is active
+	FinishedAt *time.Time
+
+	// DailyDate is the day of the daily game this game belongs to; nil if it's not a daily game
+	DailyDate *time.Time
+
+	// AllowFreeGuesses tells whether attempts that aren't dictionary words are accepted. Such practice games don't count
+	// toward the score, stats or leaderboards
+	AllowFreeGuesses bool
+
+	// Unranked tells whether the game is left out of the score, stats and leaderboards, like practice games. Games of
+	// challenges with custom words are unranked, since whoever was given the words can win them right away
+	Unranked bool
+
+	// HardMode tells whether attempts must reuse every hint revealed by previous attempts
+	HardMode bool
+
+	// VersusMatchID is the versus match this game was played in; nil if it's not a versus game
+	VersusMatchID *int64
+
+	// ChallengeID is the challenge whose words this game is played with; nil if it's not a challenge game
+	ChallengeID *int64
+}
+
+// GameSummary stores the data of a finished game shown in the user's game history
+type GameSummary struct {
+	ID            int64      `json:"id"`
+	WordLength    uint32     `json:"word_length"`
+	WordCount     uint32     `json:"word_count"`
+	AttemptCount  uint32     `json:"attempt_count"`
+	Result        GameResult `json:"result"`
+	DailyDate     string     `json:"daily_date,omitempty"`
+	HardMode      bool       `json:"hard_mode"`
+	VersusMatchID *int64     `json:"versus_match_id,omitempty"`
+	ChallengeID   *int64     `json:"challenge_id,omitempty"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    time.Time  `json:"finished_at"`
+}
+
+// GameAttemptResponse is used in endpoints to send a single attempt of a game replay
+type GameAttemptResponse struct {
+	Attempt   string    `json:"attempt"`
+	GameState GameState `json:"game_state"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GameReplayResponse is used in endpoints to send every detail of a finished game
+type GameReplayResponse struct {
+	ID               int64                 `json:"id"`
+	WordLength       uint32                `json:"word_length"`
+	WordCount        uint32                `json:"word_count"`
+	MaxAttempts      uint32                `json:"max_attempts"`
+	Words            []string              `json:"words"`
+	Attempts         []GameAttemptResponse `json:"attempts"`
+	Result           *GameResult           `json:"result"`
+	DailyDate        string                `json:"daily_date,omitempty"`
+	AllowFreeGuesses bool                  `json:"allow_free_guesses"`
+	Unranked         bool                  `json:"unranked"`
+	HardMode         bool                  `json:"hard_mode"`
+	VersusMatchID    *int64                `json:"versus_match_id,omitempty"`
+	ChallengeID      *int64                `json:"challenge_id,omitempty"`
+	StartedAt        time.Time             `json:"started_at"`
+	FinishedAt       *time.Time            `json:"finished_at"`
+}
+
+// GameOptions stores the settings a new game is created with
+type GameOptions struct {
+	// DailyDate is the day of the daily game being started; nil for regular games
+	DailyDate *time.Time
+
+	// AllowFreeGuesses tells whether attempts that aren't dictionary words are accepted, useful for practice games.
+	// Such games don't count toward the score, stats or leaderboards
+	AllowFreeGuesses bool
+
+	// Unranked tells whether the game is left out of the score, stats and leaderboards even though it only accepts
+	// dictionary words
+	Unranked bool
+
+	// HardMode tells whether attempts must reuse every hint revealed by previous attempts
+	HardMode bool
+
+	// ChallengeID is the challenge whose words the game is played with; nil for regular games
+	ChallengeID *int64
+}
+
+type HardModeRule string
+
+const (
+	// HardModeRuleCorrectPosition is violated when a letter revealed in the correct position is not kept in place
+	HardModeRuleCorrectPosition HardModeRule = "CORRECT_POSITION"
+
+	// HardModeRuleMissingLetter is violated when a letter revealed in the wrong position is not used anywhere
+	HardModeRuleMissingLetter HardModeRule = "MISSING_LETTER"
+)
+
+// HardModeViolation describes which hint an attempt failed to reuse in a hard mode game
+type HardModeViolation struct {
+	// Rule is the hard mode rule that was violated
+	Rule HardModeRule `json:"rule"`
+
+	// Board is the index of the game word whose hint was not reused
+	Board int `json:"board"`
+
+	// Letter is the letter that was not reused
+	Letter string `json:"letter"`
+
+	// Position is where the letter was revealed by a previous attempt
+	Position int `json:"position"`
 }
 
 // GameResponse is used in endpoints to send the minimum required public data
 type GameResponse struct {
-	WordLength  uint32      `json:"word_length"`
-	WordCount   uint32      `json:"word_count"`
-	MaxAttempts uint32      `json:"max_attempts"`
-	Attempts    []string    `json:"attempts"`
-	GameStates  []GameState `json:"game_states"`
+	WordLength       uint32      `json:"word_length"`
+	WordCount        uint32      `json:"word_count"`
+	MaxAttempts      uint32      `json:"max_attempts"`
+	Attempts         []string    `json:"attempts"`
+	GameStates       []GameState `json:"game_states"`
+	DailyDate        string      `json:"daily_date,omitempty"`
+	AllowFreeGuesses bool        `json:"allow_free_guesses"`
+	Unranked         bool        `json:"unranked"`
+	HardMode         bool        `json:"hard_mode"`
+	StartedAt        time.Time   `json:"started_at"`
 }
 
 func (g Game) ToResponse(states []GameState, maxAttempts uint32) GameResponse {
+	var dailyDate string
+	if g.DailyDate != nil {
+		dailyDate = g.DailyDate.Format(DailyDateFormat)
+	}
+
 	return GameResponse{
-		WordLength:  g.GetWordLength(),
-		WordCount:   g.GetWordCount(),
-		MaxAttempts: maxAttempts,
-		Attempts:    g.Attempts,
-		GameStates:  states,
+		WordLength:       g.GetWordLength(),
+		WordCount:        g.GetWordCount(),
+		MaxAttempts:      maxAttempts,
+		Attempts:         g.Attempts,
+		GameStates:       states,
+		DailyDate:        dailyDate,
+		AllowFreeGuesses: g.AllowFreeGuesses,
+		Unranked:         g.Unranked,
+		HardMode:         g.HardMode,
+		StartedAt:        g.StartedAt,
+	}
+}
+
+// ToReplayResponse builds the replay of a game; originalWords are the game words as shown to the user
+func (g Game) ToReplayResponse(states []GameState, originalWords []string, maxAttempts uint32) GameReplayResponse {
+	attempts := make([]GameAttemptResponse, len(g.Attempts))
+	for i, attempt := range g.Attempts {
+		attempts[i] = GameAttemptResponse{
+			Attempt:   attempt,
+			GameState: states[i],
+		}
+		if i < len(g.AttemptTimes) {
+			attempts[i].CreatedAt = g.AttemptTimes[i]
+		}
 	}
+
+	var dailyDate string
+	if g.DailyDate != nil {
+		dailyDate = g.DailyDate.Format(DailyDateFormat)
+	}
+
+	return GameReplayResponse{
+		ID:               g.ID,
+		WordLength:       g.GetWordLength(),
+		WordCount:        g.GetWordCount(),
+		MaxAttempts:      maxAttempts,
+		Words:            originalWords,
+		Attempts:         attempts,
+		Result:           g.Result,
+		DailyDate:        dailyDate,
+		AllowFreeGuesses: g.AllowFreeGuesses,
+		Unranked:         g.Unranked,
+		HardMode:         g.HardMode,
+		VersusMatchID:    g.VersusMatchID,
+		ChallengeID:      g.ChallengeID,
+		StartedAt:        g.StartedAt,
+		FinishedAt:       g.FinishedAt,
+	}
+}
+
+// IsRanked tells whether the game counts toward the score, stats and leaderboards
+func (g Game) IsRanked() bool {
+	return !g.AllowFreeGuesses && !g.Unranked
 }
 
 func (g Game) GetWordLength() uint32 {
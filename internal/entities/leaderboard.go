@@ -0,0 +1,69 @@
+package entities
+
+import "time"
+
+type LeaderboardKind string
+type LeaderboardWindow string
+
+const (
+	// LeaderboardKindScore ranks users by how many games they won
+	LeaderboardKindScore LeaderboardKind = "score"
+
+	// LeaderboardKindWinRate ranks users by the percentage of games they won
+	LeaderboardKindWinRate LeaderboardKind = "win_rate"
+
+	// LeaderboardKindAverageAttempts ranks users by the average attempts needed to win a game
+	LeaderboardKindAverageAttempts LeaderboardKind = "average_attempts"
+)
+
+const (
+	// LeaderboardWindowAll counts every game ever finished
+	LeaderboardWindowAll LeaderboardWindow = "all"
+
+	// LeaderboardWindowWeek counts games finished since the start of the current week (Monday, UTC)
+	LeaderboardWindowWeek LeaderboardWindow = "week"
+
+	// LeaderboardWindowDaily counts only today's daily games
+	LeaderboardWindowDaily LeaderboardWindow = "daily"
+)
+
+// LeaderboardFilter stores which games are counted in a leaderboard
+type LeaderboardFilter struct {
+	// WordLength filters games by word length; 0 counts every word length
+	WordLength uint32
+
+	// WordCount filters games by word count; 0 counts every word count
+	WordCount uint32
+
+	// FinishedAfter filters games finished on or after this day; zero counts every game
+	FinishedAfter time.Time
+
+	// DailyDate filters daily games of a single day; nil counts every game
+	DailyDate *time.Time
+
+	// MinGames is the minimum number of counted games a user needs to be ranked
+	MinGames uint32
+}
+
+// LeaderboardEntry stores the position of a single user in a leaderboard
+type LeaderboardEntry struct {
+	Position        uint32  `json:"position"`
+	UserID          int64   `json:"user_id"`
+	Name            string  `json:"name"`
+	GamesPlayed     uint32  `json:"games_played"`
+	GamesWon        uint32  `json:"games_won"`
+	WinPercentage   float64 `json:"win_percentage"`
+	AverageAttempts float64 `json:"average_attempts"`
+}
+
+// Leaderboard is used in endpoints to send a page of a leaderboard
+type Leaderboard struct {
+	// Entries is the requested page of the leaderboard
+	Entries []LeaderboardEntry `json:"entries"`
+
+	// Own is the requesting user's entry; nil if they are not ranked
+	Own *LeaderboardEntry `json:"own"`
+
+	// Total is how many users are ranked
+	Total uint32 `json:"total"`
+}
@@ -0,0 +1,205 @@
+package entities
+
+import (
+	"slices"
+	"time"
+)
+
+type LobbyState string
+
+const (
+	// LobbyStateWaiting is set while the host hasn't started a round, including between rounds
+	LobbyStateWaiting LobbyState = "WAITING"
+
+	// LobbyStatePlaying is set while the players of a round are finding its words
+	LobbyStatePlaying LobbyState = "PLAYING"
+)
+
+// LobbySettings stores the game settings a host picks for the rounds of their lobby
+type LobbySettings struct {
+	WordLength uint32 `json:"word_length"`
+	WordCount  uint32 `json:"word_count"`
+	MaxPlayers uint32 `json:"max_players"`
+
+	// RoundSeconds is how long each round lasts; players who haven't finished by then lose the round
+	RoundSeconds uint32 `json:"round_seconds"`
+}
+
+// LobbyPlayer stores a player of a lobby, along with their game in the current round
+type LobbyPlayer struct {
+	ID       int64     `json:"id"`
+	Name     string    `json:"name"`
+	JoinedAt time.Time `json:"joined_at"`
+
+	// Score is the sum of the points of every round the player played
+	Score uint32 `json:"score"`
+
+	// Playing tells whether the player is in the current round; players who join during a round wait for the next one
+	Playing bool `json:"playing"`
+
+	Attempts   []string    `json:"attempts"`
+	GameStates []GameState `json:"game_states"`
+	Result     *GameResult `json:"result"`
+	Points     uint32      `json:"points"`
+}
+
+// LobbyRoundResult stores how a player did in a finished round
+type LobbyRoundResult struct {
+	PlayerID     int64      `json:"player_id"`
+	Name         string     `json:"name"`
+	Result       GameResult `json:"result"`
+	AttemptCount uint32     `json:"attempt_count"`
+	Points       uint32     `json:"points"`
+}
+
+// LobbyRound stores a finished round of a lobby
+type LobbyRound struct {
+	Number  uint32             `json:"number"`
+	Words   []string           `json:"words"`
+	Results []LobbyRoundResult `json:"results"`
+}
+
+// Lobby stores a lobby where players play synchronized rounds with the same words. Shared lobby stores keep it as JSON
+type Lobby struct {
+	Code     string        `json:"code"`
+	HostID   int64         `json:"host_id"`
+	Settings LobbySettings `json:"settings"`
+	State    LobbyState    `json:"state"`
+
+	// Players are kept in the order they joined
+	Players []LobbyPlayer `json:"players"`
+
+	// Round is the number of the current round, or of the last one while waiting
+	Round          uint32    `json:"round"`
+	Words          []string  `json:"words"`
+	RoundStartedAt time.Time `json:"round_started_at"`
+	RoundEndsAt    time.Time `json:"round_ends_at"`
+
+	// Rounds are the latest finished rounds, from the oldest to the newest
+	Rounds []LobbyRound `json:"rounds"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Version is incremented whenever the lobby is stored, so that concurrent changes can be detected
+	Version int64 `json:"-"`
+}
+
+// LobbyPlayerResponse is used in endpoints to send a player of a lobby. Only the player themselves gets the letters of
+// their attempts
+type LobbyPlayerResponse struct {
+	ID         int64       `json:"id"`
+	Name       string      `json:"name"`
+	Score      uint32      `json:"score"`
+	Playing    bool        `json:"playing"`
+	Attempts   []string    `json:"attempts,omitempty"`
+	GameStates []GameState `json:"game_states"`
+	Result     *GameResult `json:"result,omitempty"`
+	Points     uint32      `json:"points"`
+}
+
+// LobbyScore is used in endpoints to send the total score of a player in a lobby's scoreboard
+type LobbyScore struct {
+	PlayerID int64  `json:"player_id"`
+	Name     string `json:"name"`
+	Score    uint32 `json:"score"`
+}
+
+// LobbyResponse is used in endpoints to send a lobby as seen by one of its players
+type LobbyResponse struct {
+	Code           string                `json:"code"`
+	HostID         int64                 `json:"host_id"`
+	Settings       LobbySettings         `json:"settings"`
+	State          LobbyState            `json:"state"`
+	Round          uint32                `json:"round"`
+	MaxAttempts    uint32                `json:"max_attempts"`
+	RoundStartedAt *time.Time            `json:"round_started_at,omitempty"`
+	RoundEndsAt    *time.Time            `json:"round_ends_at,omitempty"`
+	Players        []LobbyPlayerResponse `json:"players"`
+	Scoreboard     []LobbyScore          `json:"scoreboard"`
+	Rounds         []LobbyRound          `json:"rounds"`
+}
+
+// FindPlayer returns the index of the player with the provided user ID; returns -1 if they are not in the lobby
+func (l Lobby) FindPlayer(userID int64) int {
+	return slices.IndexFunc(l.Players, func(p LobbyPlayer) bool {
+		return p.ID == userID
+	})
+}
+
+// Clone returns a copy of the lobby that shares nothing with it, so that either can be changed alone
+func (l Lobby) Clone() Lobby {
+	l.Words = slices.Clone(l.Words)
+
+	l.Players = slices.Clone(l.Players)
+	for i, player := range l.Players {
+		l.Players[i].Attempts = slices.Clone(player.Attempts)
+		l.Players[i].GameStates = slices.Clone(player.GameStates)
+		if player.Result != nil {
+			result := *player.Result
+			l.Players[i].Result = &result
+		}
+	}
+
+	l.Rounds = slices.Clone(l.Rounds)
+	for i, round := range l.Rounds {
+		l.Rounds[i].Words = slices.Clone(round.Words)
+		l.Rounds[i].Results = slices.Clone(round.Results)
+	}
+
+	return l
+}
+
+// ToResponse builds the lobby as seen by the player with the provided user ID
+func (l Lobby) ToResponse(viewerID int64, maxAttempts uint32) LobbyResponse {
+	response := LobbyResponse{
+		Code:        l.Code,
+		HostID:      l.HostID,
+		Settings:    l.Settings,
+		State:       l.State,
+		Round:       l.Round,
+		MaxAttempts: maxAttempts,
+		Players:     make([]LobbyPlayerResponse, len(l.Players)),
+		Scoreboard:  make([]LobbyScore, len(l.Players)),
+		Rounds:      l.Rounds,
+	}
+
+	if l.State == LobbyStatePlaying {
+		response.RoundStartedAt = &l.RoundStartedAt
+		response.RoundEndsAt = &l.RoundEndsAt
+	}
+	if response.Rounds == nil {
+		response.Rounds = make([]LobbyRound, 0)
+	}
+
+	for i, player := range l.Players {
+		response.Players[i] = LobbyPlayerResponse{
+			ID:         player.ID,
+			Name:       player.Name,
+			Score:      player.Score,
+			Playing:    player.Playing,
+			GameStates: player.GameStates,
+			Result:     player.Result,
+			Points:     player.Points,
+		}
+		if player.ID == viewerID {
+			response.Players[i].Attempts = player.Attempts
+		}
+		if response.Players[i].GameStates == nil {
+			response.Players[i].GameStates = make([]GameState, 0)
+		}
+
+		response.Scoreboard[i] = LobbyScore{
+			PlayerID: player.ID,
+			Name:     player.Name,
+			Score:    player.Score,
+		}
+	}
+
+	// Ties keep the order players joined in
+	slices.SortStableFunc(response.Scoreboard, func(a, b LobbyScore) int {
+		return int(b.Score) - int(a.Score)
+	})
+
+	return response
+}
@@ -28,4 +28,13 @@ type RouteDefinition struct {
 
 	// HttpMethods is a list of HTTP methods accepted by the route
 	HttpMethods []string
+
+	// RequiresSession tells whether the route needs an authenticated user even though it's outside the /api prefix
+	RequiresSession bool
+
+	// RateLimit limits how often each client can call the route; nil if the route has no limit of its own
+	RateLimit *RateLimit
+
+	// Roles lists the roles allowed to call the route; any authenticated user can call it if empty
+	Roles []Role
 }
@@ -0,0 +1,47 @@
+package entities
+
+import "time"
+
+// RateLimitKey tells which client identifier a rate limit is counted by
+type RateLimitKey string
+
+const (
+	// RateLimitKeyUser counts requests by the authenticated user, or by client IP for requests without a session
+	RateLimitKeyUser RateLimitKey = "user"
+
+	// RateLimitKeyIP counts requests by client IP
+	RateLimitKeyIP RateLimitKey = "ip"
+)
+
+// RateLimit describes a token bucket: each client can make Burst requests at once, and regains Requests requests every
+// Per
+type RateLimit struct {
+	// Requests is how many requests are allowed every Per
+	Requests uint32
+
+	// Per is the period in which Requests requests are allowed
+	Per time.Duration
+
+	// Burst is how many requests can be made at once; defaults to Requests if 0
+	Burst uint32
+
+	// Key tells which client identifier the requests are counted by
+	Key RateLimitKey
+
+	// FailClosed makes requests be refused while the rate limit store fails, instead of allowed, whatever the
+	// configured failure mode is. Meant for limits that protect against guessing
+	FailClosed bool
+}
+
+// Capacity returns how many requests can be made at once
+func (l RateLimit) Capacity() float64 {
+	if l.Burst == 0 {
+		return float64(l.Requests)
+	}
+	return float64(l.Burst)
+}
+
+// Rate returns how many requests are regained per second
+func (l RateLimit) Rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
@@ -0,0 +1,90 @@
+package entities
+
+// UserStats maps data from the stats of a user in the database for a single game mode
+type UserStats struct {
+	// WordLength is the word length of the games counted in these stats; 0 if all games are counted
+	WordLength uint32 `json:"word_length"`
+
+	// WordCount is the word count of the games counted in these stats; 0 if all games are counted
+	WordCount uint32 `json:"word_count"`
+
+	// GamesPlayed tells how many games were finished, including abandoned ones
+	GamesPlayed uint32 `json:"games_played"`
+
+	// GamesWon tells how many games were won
+	GamesWon uint32 `json:"games_won"`
+
+	// GamesAbandoned tells how many games were forfeited
+	GamesAbandoned uint32 `json:"games_abandoned"`
+
+	// HardModeWon tells how many hard mode games were won; these are also counted in GamesWon
+	HardModeWon uint32 `json:"hard_mode_won"`
+
+	// CurrentStreak tells how many games were won in a row since the last loss
+	CurrentStreak uint32 `json:"current_streak"`
+
+	// MaxStreak is the longest streak of games won in a row
+	MaxStreak uint32 `json:"max_streak"`
+
+	// GuessDistribution maps a number of attempts to how many games were won with it
+	GuessDistribution map[uint32]uint32 `json:"guess_distribution"`
+}
+
+// UserStatsEntry is used in endpoints to send the stats of a single game mode
+type UserStatsEntry struct {
+	UserStats
+
+	// WinPercentage is the percentage of played games that were won, between 0 and 100
+	WinPercentage float64 `json:"win_percentage"`
+}
+
+// UserStatsResponse is used in endpoints to send all the stats of a user
+type UserStatsResponse struct {
+	// Overall is the stats of all games combined
+	Overall UserStatsEntry `json:"overall"`
+
+	// Modes is the stats of each game mode played, identified by word length and word count
+	Modes []UserStatsEntry `json:"modes"`
+}
+
+// IsOverall tells whether these stats count all games combined instead of a single game mode
+func (s UserStats) IsOverall() bool {
+	return s.WordLength == 0 && s.WordCount == 0
+}
+
+// WinPercentage returns the percentage of played games that were won, between 0 and 100
+func (s UserStats) WinPercentage() float64 {
+	if s.GamesPlayed == 0 {
+		return 0
+	}
+	return float64(s.GamesWon) / float64(s.GamesPlayed) * 100
+}
+
+func (s UserStats) ToEntry() UserStatsEntry {
+	if s.GuessDistribution == nil {
+		s.GuessDistribution = make(map[uint32]uint32)
+	}
+
+	return UserStatsEntry{
+		UserStats:     s,
+		WinPercentage: s.WinPercentage(),
+	}
+}
+
+// UserStatsToResponse groups the stats of every game mode of a user
+func UserStatsToResponse(stats []UserStats) UserStatsResponse {
+	response := UserStatsResponse{
+		Overall: UserStats{}.ToEntry(),
+		Modes:   make([]UserStatsEntry, 0, len(stats)),
+	}
+
+	for _, s := range stats {
+		if s.IsOverall() {
+			response.Overall = s.ToEntry()
+		} else {
+			response.Modes = append(response.Modes, s.ToEntry())
+		}
+	}
+
+	return response
+}
@@ -1,5 +1,18 @@
 package entities
 
+import "time"
+
+// Role defines what a user is allowed to do
+type Role string
+
+const (
+	// RoleUser is the role of every player
+	RoleUser Role = "user"
+
+	// RoleAdmin is the role of operators, who can manage other users through the /admin routes
+	RoleAdmin Role = "admin"
+)
+
 // User maps data from users in the database
 type User struct {
 	// ID is the database identifier
@@ -11,8 +24,69 @@ type User struct {
 	// Password is the user's hashed password
 	Password string
 
+	// Email is the user's email address, used to recover the account; nil if not set
+	Email *string
+
 	// Score tells how many games the user has won
 	Score uint32
+
+	// IsGuest tells whether the user was created without registering. Guests are not ranked in leaderboards
+	IsGuest bool
+
+	// CreatedAt is when the user was created; nil for users created before it was stored
+	CreatedAt *time.Time
+
+	// LastActiveAt is about when the user last made an authenticated request; nil if they didn't since it's stored
+	LastActiveAt *time.Time
+
+	// TokensValidAfter is the moment before which every auth token issued to the user is rejected; nil if none
+	TokensValidAfter *time.Time
+
+	// LockedUntil is the moment until which the user can't log in, after too many failed logins; nil if never locked
+	LockedUntil *time.Time
+
+	// TOTPSecret is the user's two-factor authentication secret; nil if they never enrolled
+	TOTPSecret *string
+
+	// TOTPEnabled tells whether the user confirmed their enrollment, so that logging in requires a code
+	TOTPEnabled bool
+
+	// Role is what the user is allowed to do
+	Role Role
+
+	// BannedAt is when the user was banned; nil if not banned
+	BannedAt *time.Time
+
+	// BannedUntil is when the user's ban ends; nil if the ban is permanent
+	BannedUntil *time.Time
+
+	// BanReason is why the user was banned, as told by the admin; nil if not given
+	BanReason *string
+}
+
+// IsBanned tells whether the user is banned at the provided moment
+func (u User) IsBanned(now time.Time) bool {
+	return u.BannedAt != nil && (u.BannedUntil == nil || now.Before(*u.BannedUntil))
+}
+
+// HasRole tells whether the user has any of the provided roles
+func (u User) HasRole(roles ...Role) bool {
+	for _, role := range roles {
+		if u.Role == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NameChange stores a name a user had before changing it
+type NameChange struct {
+	// Name is the previous name
+	Name string `json:"name"`
+
+	// ChangedAt is when the user stopped using the name
+	ChangedAt time.Time `json:"changed_at"`
 }
 
 // UserCredentials stores data for an attempt at user registration/login
@@ -22,6 +96,9 @@ type UserCredentials struct {
 
 	// Password is the user's password attempt
 	Password string `json:"password"`
+
+	// Email is the user's email address; optional and only used for registration
+	Email string `json:"email,omitempty"`
 }
 
 // UserResponse is used in endpoints to send the minimum required public data
@@ -32,24 +109,53 @@ type UserResponse struct {
 	// Name is the user's name
 	Name string `json:"name"`
 
+	// Email is the user's email address
+	Email *string `json:"email"`
+
+	// IsGuest tells whether the user still has to upgrade their guest account
+	IsGuest bool `json:"is_guest"`
+
 	// Score tells how many games the user has won
 	Score uint32 `json:"score"`
 
+	// HardModeScore tells how many hard mode games the user has won; taken from the overall stats
+	HardModeScore uint32 `json:"hard_mode_score"`
+
+	// Abandoned tells how many games the user has forfeited; taken from the overall stats
+	Abandoned uint32 `json:"abandoned"`
+
 	// ActiveGame is the user's active game data
 	ActiveGame *GameResponse `json:"active_game"`
+
+	// Stats is the user's game statistics
+	Stats *UserStatsResponse `json:"stats"`
 }
 
-func (u User) ToResponse(game *Game, gameStatuses []GameState, maxGameAttempts *uint32) UserResponse {
+func (u User) ToResponse(
+	game *Game,
+	gameStatuses []GameState,
+	maxGameAttempts *uint32,
+	stats *UserStatsResponse,
+) UserResponse {
 	var gameResponse *GameResponse
 	if game != nil && maxGameAttempts != nil {
 		response := game.ToResponse(gameStatuses, *maxGameAttempts)
 		gameResponse = &response
 	}
 
-	return UserResponse{
+	response := UserResponse{
 		ID:         u.ID,
 		Name:       u.Name,
+		Email:      u.Email,
+		IsGuest:    u.IsGuest,
 		Score:      u.Score,
 		ActiveGame: gameResponse,
+		Stats:      stats,
 	}
+	if stats != nil {
+		response.HardModeScore = stats.Overall.HardModeWon
+		response.Abandoned = stats.Overall.GamesAbandoned
+	}
+
+	return response
 }
@@ -0,0 +1,106 @@
+package entities
+
+import "time"
+
+type VersusRoomState string
+
+const (
+	// VersusRoomStateWaiting is set while the room waits for players to join
+	VersusRoomStateWaiting VersusRoomState = "WAITING"
+
+	// VersusRoomStatePlaying is set while the players race to find the words
+	VersusRoomStatePlaying VersusRoomState = "PLAYING"
+
+	// VersusRoomStateFinished is set once a player found every word or every player finished
+	VersusRoomStateFinished VersusRoomState = "FINISHED"
+)
+
+type VersusEventType string
+
+const (
+	// VersusEventRoom is sent with the whole room whenever players join, leave, connect or disconnect, and when the
+	// match starts
+	VersusEventRoom VersusEventType = "ROOM"
+
+	// VersusEventProgress is sent when an opponent makes an attempt or finishes their game
+	VersusEventProgress VersusEventType = "PROGRESS"
+
+	// VersusEventFinished is sent when the match ends, along with its words
+	VersusEventFinished VersusEventType = "FINISHED"
+)
+
+// VersusRoomOptions stores the settings a versus room is created with
+type VersusRoomOptions struct {
+	WordLength uint32 `json:"word_length"`
+	WordCount  uint32 `json:"word_count"`
+
+	// MaxPlayers is how many players can join the room; the match starts as soon as it's full
+	MaxPlayers uint32 `json:"max_players"`
+
+	// Public tells whether the room is listed in the lobby; private rooms can only be joined with their code
+	Public bool `json:"public"`
+}
+
+// VersusPlayerResponse is used in versus events to send a player's progress. Opponents only get the states of the
+// letters of each attempt, never the letters themselves
+type VersusPlayerResponse struct {
+	ID         int64       `json:"id"`
+	Name       string      `json:"name"`
+	Connected  bool        `json:"connected"`
+	Attempts   []string    `json:"attempts,omitempty"`
+	GameStates []GameState `json:"game_states"`
+	Result     *GameResult `json:"result,omitempty"`
+}
+
+// VersusRoomResponse is used in versus events to send the whole state of a room
+type VersusRoomResponse struct {
+	Code        string                 `json:"code"`
+	State       VersusRoomState        `json:"state"`
+	HostID      int64                  `json:"host_id"`
+	WordLength  uint32                 `json:"word_length"`
+	WordCount   uint32                 `json:"word_count"`
+	MaxAttempts uint32                 `json:"max_attempts"`
+	MaxPlayers  uint32                 `json:"max_players"`
+	Public      bool                   `json:"public"`
+	Players     []VersusPlayerResponse `json:"players"`
+	StartedAt   *time.Time             `json:"started_at,omitempty"`
+}
+
+// VersusProgressResponse is used in versus events to send a single attempt of a player
+type VersusProgressResponse struct {
+	PlayerID  int64       `json:"player_id"`
+	GameState GameState   `json:"game_state,omitempty"`
+	Result    *GameResult `json:"result,omitempty"`
+}
+
+// VersusFinishedResponse is used in versus events to send how a match ended
+type VersusFinishedResponse struct {
+	// WinnerID is the player who found every word first; nil if nobody did
+	WinnerID *int64                 `json:"winner_id,omitempty"`
+	Words    []string               `json:"words"`
+	Players  []VersusPlayerResponse `json:"players"`
+}
+
+// VersusRoomSummary is used in the lobby to list the public rooms waiting for players
+type VersusRoomSummary struct {
+	Code       string    `json:"code"`
+	HostName   string    `json:"host_name"`
+	WordLength uint32    `json:"word_length"`
+	WordCount  uint32    `json:"word_count"`
+	Players    uint32    `json:"players"`
+	MaxPlayers uint32    `json:"max_players"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// VersusEvent is pushed to the players of a versus room; Data is one of the responses above, according to the type
+type VersusEvent struct {
+	Type VersusEventType `json:"type"`
+	Data any             `json:"data"`
+}
+
+// VersusMatch stores a finished versus match to be saved, with the game of each player
+type VersusMatch struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Games      []Game
+}
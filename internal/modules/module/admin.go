@@ -0,0 +1,365 @@
+package module
+
+import (
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"strconv"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/modules/service"
+	"termo_back_end/internal/status_codes"
+	"termo_back_end/internal/util"
+	"time"
+)
+
+type adminModule struct {
+	service      service.AdminService
+	auditService service.AuditService
+	limiter      *util.RateLimiter
+	path         string
+}
+
+func NewAdminModule(
+	service service.AdminService,
+	auditService service.AuditService,
+	limiter *util.RateLimiter,
+) entities.Module {
+	return adminModule{
+		service:      service,
+		auditService: auditService,
+		limiter:      limiter,
+		path:         "/admin",
+	}
+}
+
+// adminRoles are the roles allowed to call every admin route
+var adminRoles = []entities.Role{entities.RoleAdmin}
+
+func (m adminModule) Path() string {
+	return m.path
+}
+
+func (m adminModule) Setup(r *mux.Router) ([]entities.RouteDefinition, *mux.Router) {
+	defs := []entities.RouteDefinition{
+		{
+			Path:        "/users",
+			Handler:     m.listUsers,
+			HttpMethods: []string{http.MethodGet},
+			Roles:       adminRoles,
+		},
+		{
+			Path:        "/users/{id:[0-9]+}/names",
+			Handler:     m.nameHistory,
+			HttpMethods: []string{http.MethodGet},
+			Roles:       adminRoles,
+		},
+		{
+			Path:        "/users/{id:[0-9]+}/ban",
+			Handler:     m.ban,
+			HttpMethods: []string{http.MethodPost},
+			Roles:       adminRoles,
+		},
+		{
+			Path:        "/users/{id:[0-9]+}/unban",
+			Handler:     m.unban,
+			HttpMethods: []string{http.MethodPost},
+			Roles:       adminRoles,
+		},
+		{
+			Path:        "/users/{id:[0-9]+}/resetScore",
+			Handler:     m.resetScore,
+			HttpMethods: []string{http.MethodPost},
+			Roles:       adminRoles,
+		},
+		{
+			Path:        "/users/{id:[0-9]+}/finishGame",
+			Handler:     m.finishGame,
+			HttpMethods: []string{http.MethodPost},
+			Roles:       adminRoles,
+		},
+		{
+			Path:        "/users/{id:[0-9]+}/role",
+			Handler:     m.setRole,
+			HttpMethods: []string{http.MethodPost},
+			Roles:       adminRoles,
+		},
+		{
+			Path:        "/audit",
+			Handler:     m.audit,
+			HttpMethods: []string{http.MethodGet},
+			Roles:       adminRoles,
+		},
+		{
+			Path:        "/stats",
+			Handler:     m.stats,
+			HttpMethods: []string{http.MethodGet},
+			Roles:       adminRoles,
+		},
+	}
+
+	for _, d := range defs {
+		handler := m.limiter.Wrap(m.path+d.Path, d.RateLimit, d.Handler)
+		handler = util.RequireRoles(d.Roles, handler)
+		r.Handle(d.Path, handler).Methods(d.HttpMethods...)
+	}
+
+	return defs, nil
+}
+
+// listUsers accepts the optional query parameters query, page and limit
+func (m adminModule) listUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var params [2]uint64
+	for i, name := range []string{"page", "limit"} {
+		if !query.Has(name) {
+			continue
+		}
+
+		var err error
+		params[i], err = strconv.ParseUint(query.Get(name), 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid "+name, http.StatusBadRequest)
+			return
+		}
+	}
+
+	users, err := m.service.ListUsers(r.Context(), query.Get("query"), uint32(params[0]), uint32(params[1]))
+	if err != nil {
+		log.Printf("[ListUsers] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, users)
+}
+
+func (m adminModule) nameHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+
+	status, history, err := m.service.GetNameHistory(r.Context(), userID)
+	if err != nil {
+		log.Printf("[GetNameHistory] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.AdminNameHistory]
+		Names []entities.NameChange `json:"names,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		Names:                   history,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m adminModule) ban(w http.ResponseWriter, r *http.Request) {
+	admin, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+
+		// DurationHours is how long the ban lasts; 0 bans the user permanently
+		DurationHours uint32 `json:"duration_hours"`
+	}
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	duration := time.Duration(body.DurationHours) * time.Hour
+	status, err := m.service.BanUser(r.Context(), admin, userID, duration, body.Reason)
+	if err != nil {
+		log.Printf("[BanUser] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, util.BuildDefaultEndpointStatusResponse(status))
+}
+
+func (m adminModule) unban(w http.ResponseWriter, r *http.Request) {
+	admin, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := m.service.UnbanUser(r.Context(), admin, userID)
+	if err != nil {
+		log.Printf("[UnbanUser] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, util.BuildDefaultEndpointStatusResponse(status))
+}
+
+func (m adminModule) resetScore(w http.ResponseWriter, r *http.Request) {
+	admin, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := m.service.ResetScore(r.Context(), admin, userID)
+	if err != nil {
+		log.Printf("[ResetScore] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, util.BuildDefaultEndpointStatusResponse(status))
+}
+
+func (m adminModule) finishGame(w http.ResponseWriter, r *http.Request) {
+	admin, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := m.service.FinishGame(r.Context(), admin, userID)
+	if err != nil {
+		log.Printf("[FinishGame] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, util.BuildDefaultEndpointStatusResponse(status))
+}
+
+func (m adminModule) setRole(w http.ResponseWriter, r *http.Request) {
+	admin, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Role entities.Role `json:"role"`
+	}
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	status, err := m.service.SetRole(r.Context(), admin, userID, body.Role)
+	if err != nil {
+		log.Printf("[SetRole] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, util.BuildDefaultEndpointStatusResponse(status))
+}
+
+// audit accepts the optional query parameters user_id, type, cursor and limit
+func (m adminModule) audit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := entities.AuditFilter{
+		Type: entities.AuditEventType(query.Get("type")),
+	}
+
+	if query.Has("user_id") {
+		userID, err := strconv.ParseInt(query.Get("user_id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user_id", http.StatusBadRequest)
+			return
+		}
+		filter.UserID = &userID
+	}
+
+	var limit uint64
+	if query.Has("limit") {
+		var err error
+		limit, err = strconv.ParseUint(query.Get("limit"), 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	status, events, nextCursor, err := m.auditService.GetEvents(r.Context(), filter, query.Get("cursor"), uint32(limit))
+	if err != nil {
+		log.Printf("[GetEvents] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, newAuditEventsResponse(status, events, nextCursor))
+}
+
+func (m adminModule) stats(w http.ResponseWriter, r *http.Request) {
+	stats, err := m.service.GetServerStats(r.Context())
+	if err != nil {
+		log.Printf("[GetServerStats] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, stats)
+}
+
+// readUserID reads the target user's ID from the route; writes an error response and returns false if it's invalid
+func readUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	userID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	return userID, true
+}
+
+// auditEventsResponse is the response of the routes that return a page of the audit log
+type auditEventsResponse struct {
+	util.DefaultEndpointResponse[status_codes.AuditEventsGet]
+	Events     []entities.AuditEvent `json:"events,omitempty"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+func newAuditEventsResponse(
+	status status_codes.AuditEventsGet,
+	events []entities.AuditEvent,
+	nextCursor string,
+) auditEventsResponse {
+	return auditEventsResponse{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		Events:                  events,
+		NextCursor:              nextCursor,
+	}
+}
@@ -3,56 +3,187 @@ package module
 import (
 	"context"
 	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 	"log"
 	"net/http"
 	"strings"
 	"termo_back_end/internal/entities"
 	"termo_back_end/internal/modules/service"
+	"termo_back_end/internal/rules"
 	"termo_back_end/internal/status_codes"
 	"termo_back_end/internal/util"
+	"time"
 )
 
 type authModule struct {
-	service service.AuthService
-	path    string
+	service      service.AuthService
+	limiter      *util.RateLimiter
+	path         string
+	proxyHops    uint32
+	apiRateLimit *entities.RateLimit
 }
 
-func NewAuthModule(service service.AuthService) entities.Module {
+func NewAuthModule(config entities.Config, service service.AuthService, limiter *util.RateLimiter) entities.Module {
 	return authModule{
-		service: service,
-		path:    "/",
+		service:      service,
+		limiter:      limiter,
+		path:         "/",
+		proxyHops:    util.GetProxyHops(config),
+		apiRateLimit: config.RateLimit.API.RateLimit(entities.RateLimitKeyUser),
 	}
 }
 
+// authRateLimit limits requests to the routes that issue auth tokens, on top of the login protection
+var authRateLimit = &entities.RateLimit{
+	Requests:   20,
+	Per:        time.Minute,
+	Key:        entities.RateLimitKeyIP,
+	FailClosed: true,
+}
+
+// twoFactorRateLimit limits changes to two-factor authentication, whose codes can be guessed
+var twoFactorRateLimit = &entities.RateLimit{
+	Requests:   10,
+	Per:        time.Minute,
+	Key:        entities.RateLimitKeyUser,
+	FailClosed: true,
+}
+
+// passwordResetRateLimit limits password reset requests, since each one sends an email
+var passwordResetRateLimit = &entities.RateLimit{
+	Requests:   5,
+	Per:        time.Hour,
+	Key:        entities.RateLimitKeyIP,
+	FailClosed: true,
+}
+
 func (m authModule) Path() string {
 	return m.path
 }
 
 func (m authModule) Setup(r *mux.Router) ([]entities.RouteDefinition, *mux.Router) {
+	// Every route can tell services who sent the request, e.g. for the audit log
+	r.Use(m.clientMiddleware)
+
 	defs := []entities.RouteDefinition{
 		{
 			Path:        "/register",
 			Handler:     m.register,
 			HttpMethods: []string{http.MethodPost},
+			RateLimit:   authRateLimit,
+		},
+		{
+			Path:        "/guest",
+			Handler:     m.guest,
+			HttpMethods: []string{http.MethodPost},
+			RateLimit:   authRateLimit,
+		},
+		{
+			Path:            "/guest/upgrade",
+			Handler:         m.upgradeGuest,
+			HttpMethods:     []string{http.MethodPost},
+			RequiresSession: true,
+			RateLimit:       authRateLimit,
 		},
 		{
 			Path:        "/login",
 			Handler:     m.login,
 			HttpMethods: []string{http.MethodPost},
+			RateLimit:   authRateLimit,
+		},
+		{
+			Path:        "/login/2fa",
+			Handler:     m.loginSecondFactor,
+			HttpMethods: []string{http.MethodPost},
+			RateLimit:   authRateLimit,
+		},
+		{
+			Path:        "/refresh",
+			Handler:     m.refresh,
+			HttpMethods: []string{http.MethodPost},
+			RateLimit:   authRateLimit,
+		},
+		{
+			Path:        "/password-reset/request",
+			Handler:     m.requestPasswordReset,
+			HttpMethods: []string{http.MethodPost},
+			RateLimit:   passwordResetRateLimit,
+		},
+		{
+			Path:        "/password-reset/confirm",
+			Handler:     m.confirmPasswordReset,
+			HttpMethods: []string{http.MethodPost},
+			RateLimit:   authRateLimit,
+		},
+		{
+			Path:            "/logout",
+			Handler:         m.logout,
+			HttpMethods:     []string{http.MethodPost},
+			RequiresSession: true,
+		},
+		{
+			Path:            "/logout-all",
+			Handler:         m.logoutAll,
+			HttpMethods:     []string{http.MethodPost},
+			RequiresSession: true,
+		},
+		{
+			Path:            "/stream-ticket",
+			Handler:         m.streamTicket,
+			HttpMethods:     []string{http.MethodPost},
+			RequiresSession: true,
+		},
+		{
+			Path:            "/2fa/enroll",
+			Handler:         m.enrollTwoFactor,
+			HttpMethods:     []string{http.MethodPost},
+			RequiresSession: true,
+			RateLimit:       twoFactorRateLimit,
+		},
+		{
+			Path:            "/2fa/confirm",
+			Handler:         m.confirmTwoFactor,
+			HttpMethods:     []string{http.MethodPost},
+			RequiresSession: true,
+			RateLimit:       twoFactorRateLimit,
+		},
+		{
+			Path:            "/2fa/disable",
+			Handler:         m.disableTwoFactor,
+			HttpMethods:     []string{http.MethodPost},
+			RequiresSession: true,
+			RateLimit:       twoFactorRateLimit,
 		},
 	}
 
 	for _, d := range defs {
-		r.HandleFunc(d.Path, d.Handler).Methods(d.HttpMethods...)
+		// The session is checked first, so that limits can be counted by user
+		handler := m.limiter.Wrap(d.Path, d.RateLimit, d.Handler)
+		handler = util.RequireRoles(d.Roles, handler)
+		if d.RequiresSession {
+			handler = m.sessionMiddleware(handler)
+		}
+		r.Handle(d.Path, handler).Methods(d.HttpMethods...)
 	}
 
 	// Add /api prefix to all other modules
 	api := r.PathPrefix("/api").Subrouter()
 	api.Use(m.sessionMiddleware)
+	if m.apiRateLimit != nil {
+		api.Use(m.limiter.Middleware("/api", *m.apiRateLimit))
+	}
 
 	return defs, api
 }
 
+// clientMiddleware stores the data of the client that sent the request in its context
+func (m *authModule) clientMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := util.WithClientInfo(r.Context(), util.GetClientInfo(r, m.proxyHops))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (m *authModule) sessionMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		c := r.Context()
@@ -63,17 +194,39 @@ func (m *authModule) sessionMiddleware(next http.Handler) http.Handler {
 			token = strings.ReplaceAll(authHeader, "Bearer ", "")
 		}
 
-		if token == "" {
+		// Browsers can't set headers on websockets and event streams, so they send a single-use ticket in the query
+		// instead, keeping the auth token out of the URLs that proxies log. Used tickets are refused, so clients fetch
+		// a fresh one whenever they reconnect
+		var ticket string
+		if token == "" && (websocket.IsWebSocketUpgrade(r) || r.Header.Get("Accept") == "text/event-stream") {
+			ticket = r.URL.Query().Get("ticket")
+		}
+
+		if token == "" && ticket == "" {
 			log.Printf("No token found in the request\n")
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		user, err := m.service.GetUserFromToken(c, token)
-		if err != nil {
-			log.Printf("[GetUserFromToken] | %v", err)
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
-			return
+		var (
+			user   *entities.User
+			claims *entities.AuthToken
+			err    error
+		)
+		if token != "" {
+			user, claims, err = m.service.GetUserFromToken(c, token)
+			if err != nil {
+				log.Printf("[GetUserFromToken] | %v", err)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		} else {
+			user, claims, err = m.service.GetUserFromStreamTicket(c, ticket)
+			if err != nil {
+				log.Printf("[GetUserFromStreamTicket] | %v", err)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
 		}
 
 		if user == nil {
@@ -81,7 +234,13 @@ func (m *authModule) sessionMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		if user.IsBanned(time.Now()) {
+			http.Error(w, "banned", http.StatusForbidden)
+			return
+		}
+
 		ctx := context.WithValue(c, "user", user)
+		ctx = context.WithValue(ctx, "token", claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -92,18 +251,77 @@ func (m *authModule) register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	status, token, err := m.service.RegisterUser(r.Context(), credentials)
+	data, err := m.service.RegisterUser(r.Context(), credentials, util.GetClientInfo(r, m.proxyHops))
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	retryAfter := util.SetRetryAfter(w, data.RetryAfter)
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.UserRegister]
+		authTokensResponse
+		RetryAfter       uint32                  `json:"retry_after,omitempty"`
+		PasswordProblems []rules.PasswordProblem `json:"password_problems,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(data.Status),
+		authTokensResponse:      newAuthTokensResponse(data.Tokens),
+		RetryAfter:              retryAfter,
+		PasswordProblems:        data.PasswordProblems,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m *authModule) guest(w http.ResponseWriter, r *http.Request) {
+	data, err := m.service.RegisterGuest(r.Context(), util.GetClientInfo(r, m.proxyHops))
 	if err != nil {
+		log.Printf("[RegisterGuest] | %v", err)
 		util.WriteInternalError(w)
 		return
 	}
 
+	retryAfter := util.SetRetryAfter(w, data.RetryAfter)
+
 	response := struct {
 		util.DefaultEndpointResponse[status_codes.UserRegister]
-		Token string `json:"token,omitempty"`
+		authTokensResponse
+		RetryAfter uint32 `json:"retry_after,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(data.Status),
+		authTokensResponse:      newAuthTokensResponse(data.Tokens),
+		RetryAfter:              retryAfter,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m *authModule) upgradeGuest(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	var credentials entities.UserCredentials
+	if !util.ReadBody(w, r, &credentials) {
+		return
+	}
+
+	status, passwordProblems, err := m.service.UpgradeGuest(r.Context(), user, credentials)
+	if err != nil {
+		log.Printf("[UpgradeGuest] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.UserUpgrade]
+		PasswordProblems []rules.PasswordProblem `json:"password_problems,omitempty"`
 	}{
 		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
-		Token:                   token,
+		PasswordProblems:        passwordProblems,
 	}
 
 	util.WriteResponseJSON(w, response)
@@ -115,19 +333,306 @@ func (m *authModule) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	status, token, err := m.service.LoginUser(r.Context(), credentials)
+	data, err := m.service.LoginUser(r.Context(), credentials, util.GetClientInfo(r, m.proxyHops))
 	if err != nil {
 		util.WriteInternalError(w)
 		return
 	}
 
+	retryAfter := util.SetRetryAfter(w, data.RetryAfter)
+
 	response := struct {
 		util.DefaultEndpointResponse[status_codes.UserLogin]
-		Token string `json:"token,omitempty"`
+		authTokensResponse
+		ChallengeToken string     `json:"challenge_token,omitempty"`
+		RetryAfter     uint32     `json:"retry_after,omitempty"`
+		BannedUntil    *time.Time `json:"banned_until,omitempty"`
+		BanReason      *string    `json:"ban_reason,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(data.Status),
+		authTokensResponse:      newAuthTokensResponse(data.Tokens),
+		ChallengeToken:          data.ChallengeToken,
+		RetryAfter:              retryAfter,
+		BannedUntil:             data.BannedUntil,
+		BanReason:               data.BanReason,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m *authModule) loginSecondFactor(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	data, err := m.service.LoginSecondFactor(
+		r.Context(),
+		body.ChallengeToken,
+		body.Code,
+		util.GetClientInfo(r, m.proxyHops),
+	)
+	if err != nil {
+		log.Printf("[LoginSecondFactor] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	retryAfter := util.SetRetryAfter(w, data.RetryAfter)
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.LoginSecondFactor]
+		authTokensResponse
+		RetryAfter uint32 `json:"retry_after,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(data.Status),
+		authTokensResponse:      newAuthTokensResponse(data.Tokens),
+		RetryAfter:              retryAfter,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m *authModule) refresh(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	status, tokens, err := m.service.RefreshTokens(r.Context(), body.RefreshToken)
+	if err != nil {
+		log.Printf("[RefreshTokens] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.TokenRefresh]
+		authTokensResponse
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		authTokensResponse:      newAuthTokensResponse(tokens),
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m *authModule) requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	status, err := m.service.RequestPasswordReset(r.Context(), body.Email)
+	if err != nil {
+		log.Printf("[RequestPasswordReset] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, util.BuildDefaultEndpointStatusResponse(status))
+}
+
+func (m *authModule) confirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	status, passwordProblems, err := m.service.ConfirmPasswordReset(r.Context(), body.Token, body.NewPassword)
+	if err != nil {
+		log.Printf("[ConfirmPasswordReset] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.PasswordResetConfirm]
+		PasswordProblems []rules.PasswordProblem `json:"password_problems,omitempty"`
 	}{
 		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
-		Token:                   token,
+		PasswordProblems:        passwordProblems,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m *authModule) logout(w http.ResponseWriter, r *http.Request) {
+	token, err := util.GetAuthToken(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	// The refresh token is optional; an empty body only revokes the auth token
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if r.ContentLength != 0 && !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	err = m.service.Logout(r.Context(), token, body.RefreshToken)
+	if err != nil {
+		log.Printf("[Logout] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, util.BuildDefaultEndpointStatusResponse(status_codes.UserLogoutSuccess))
+}
+
+func (m *authModule) logoutAll(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	err = m.service.LogoutAll(r.Context(), user)
+	if err != nil {
+		log.Printf("[LogoutAll] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, util.BuildDefaultEndpointStatusResponse(status_codes.UserLogoutSuccess))
+}
+
+func (m *authModule) streamTicket(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	ticket, err := m.service.CreateStreamTicket(r.Context(), user)
+	if err != nil {
+		log.Printf("[CreateStreamTicket] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.StreamTicket]
+		Ticket string `json:"ticket"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status_codes.StreamTicketSuccess),
+		Ticket:                  ticket,
 	}
 
 	util.WriteResponseJSON(w, response)
 }
+
+func (m *authModule) enrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	status, enrollment, err := m.service.EnrollTwoFactor(r.Context(), user)
+	if err != nil {
+		log.Printf("[EnrollTwoFactor] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.TwoFactorEnroll]
+		Secret string `json:"secret,omitempty"`
+		URI    string `json:"uri,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+	}
+	if enrollment != nil {
+		response.Secret = enrollment.Secret
+		response.URI = enrollment.URI
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m *authModule) confirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	status, recoveryCodes, err := m.service.ConfirmTwoFactor(r.Context(), user, body.Code)
+	if err != nil {
+		log.Printf("[ConfirmTwoFactor] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.TwoFactorConfirm]
+		RecoveryCodes []string `json:"recovery_codes,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		RecoveryCodes:           recoveryCodes,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m *authModule) disableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	var body struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	status, err := m.service.DisableTwoFactor(r.Context(), user, body.Password, body.Code)
+	if err != nil {
+		log.Printf("[DisableTwoFactor] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, util.BuildDefaultEndpointStatusResponse(status))
+}
+
+// authTokensResponse is embedded in responses of endpoints that issue auth tokens
+type authTokensResponse struct {
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+func newAuthTokensResponse(tokens *entities.AuthTokens) authTokensResponse {
+	if tokens == nil {
+		return authTokensResponse{}
+	}
+
+	return authTokensResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}
+}
@@ -0,0 +1,205 @@
+package module
+
+import (
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/modules/service"
+	"termo_back_end/internal/rules"
+	"termo_back_end/internal/status_codes"
+	"termo_back_end/internal/util"
+	"time"
+)
+
+/*
+
+Endpoints:
+ - /create: creates a challenge with custom or random words; returns its code
+ - /{code}: returns a challenge, without its words
+ - /{code}/play: starts a game with the challenge's words, played through the game module
+ - /{code}/results: returns how each player did; creator only
+
+*/
+
+type challengeModule struct {
+	service     service.ChallengeService
+	gameService service.GameService
+	limiter     *util.RateLimiter
+	path        string
+}
+
+func NewChallengeModule(
+	service service.ChallengeService,
+	gameService service.GameService,
+	limiter *util.RateLimiter,
+) entities.Module {
+	return challengeModule{
+		service:     service,
+		gameService: gameService,
+		limiter:     limiter,
+		path:        "/challenge",
+	}
+}
+
+// challengeCreateRateLimit limits how many challenges each user can create
+var challengeCreateRateLimit = &entities.RateLimit{
+	Requests: 10,
+	Per:      time.Hour,
+	Burst:    5,
+	Key:      entities.RateLimitKeyUser,
+}
+
+func (m challengeModule) Path() string {
+	return m.path
+}
+
+func (m challengeModule) Setup(r *mux.Router) ([]entities.RouteDefinition, *mux.Router) {
+	defs := []entities.RouteDefinition{
+		{
+			Path:        "/create",
+			Handler:     m.create,
+			HttpMethods: []string{http.MethodPost},
+			RateLimit:   challengeCreateRateLimit,
+		},
+		{
+			Path:        "/{code}",
+			Handler:     m.get,
+			HttpMethods: []string{http.MethodGet},
+		},
+		{
+			Path:        "/{code}/play",
+			Handler:     m.play,
+			HttpMethods: []string{http.MethodPost},
+			RateLimit:   gameStartRateLimit,
+		},
+		{
+			Path:        "/{code}/results",
+			Handler:     m.results,
+			HttpMethods: []string{http.MethodGet},
+		},
+	}
+
+	for _, d := range defs {
+		handler := m.limiter.Wrap(m.path+d.Path, d.RateLimit, d.Handler)
+		handler = util.RequireRoles(d.Roles, handler)
+		r.Handle(d.Path, handler).Methods(d.HttpMethods...)
+	}
+
+	return defs, nil
+}
+
+func (m challengeModule) create(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	var body entities.ChallengeOptions
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	data, err := m.service.CreateChallenge(r.Context(), user, body)
+	if err != nil {
+		log.Printf("[CreateChallenge] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.ChallengeCreate]
+		Code         string   `json:"code,omitempty"`
+		InvalidWords []string `json:"invalid_words,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(data.Status),
+		Code:                    data.Code,
+		InvalidWords:            data.InvalidWords,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m challengeModule) get(w http.ResponseWriter, r *http.Request) {
+	status, challenge, err := m.service.GetChallenge(r.Context(), mux.Vars(r)["code"])
+	if err != nil {
+		log.Printf("[GetChallenge] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.ChallengeGet]
+		Challenge *entities.ChallengeResponse `json:"challenge,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		Challenge:               challenge,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m challengeModule) play(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	status, challenge, err := m.gameService.StartChallengeGame(r.Context(), user, mux.Vars(r)["code"])
+	if err != nil {
+		log.Printf("[StartChallengeGame] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	var (
+		maxAttempts uint32
+		wordLength  uint32
+		wordCount   uint32
+	)
+	if status == status_codes.GameStartSuccess {
+		wordLength = challenge.GetWordLength()
+		wordCount = challenge.GetWordCount()
+		maxAttempts = rules.GetGameMaxAttempts(wordLength, wordCount)
+	}
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.GameStart]
+		MaxAttempts uint32 `json:"max_attempts,omitempty"`
+		WordLength  uint32 `json:"word_length,omitempty"`
+		WordCount   uint32 `json:"word_count,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		MaxAttempts:             maxAttempts,
+		WordLength:              wordLength,
+		WordCount:               wordCount,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m challengeModule) results(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	status, results, err := m.service.GetChallengeResults(r.Context(), user, mux.Vars(r)["code"])
+	if err != nil {
+		log.Printf("[GetChallengeResults] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.ChallengeResults]
+		Results []entities.ChallengeResult `json:"results,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		Results:                 results,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
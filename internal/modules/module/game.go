@@ -1,14 +1,19 @@
 package module
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gorilla/mux"
 	"log"
 	"net/http"
+	"strconv"
 	"termo_back_end/internal/entities"
 	"termo_back_end/internal/modules/service"
 	"termo_back_end/internal/rules"
 	"termo_back_end/internal/status_codes"
 	"termo_back_end/internal/util"
+	"time"
 )
 
 /*
@@ -25,16 +30,52 @@ Notes:
 
 type gameModule struct {
 	service service.GameService
+	limiter *util.RateLimiter
 	path    string
 }
 
-func NewGameModule(service service.GameService) entities.Module {
+func NewGameModule(service service.GameService, limiter *util.RateLimiter) entities.Module {
 	return gameModule{
 		service: service,
+		limiter: limiter,
 		path:    "/game",
 	}
 }
 
+// Rate limits of game routes. Attempts are limited loosely, since players may type fast, but not like a bot
+var (
+	gameStartRateLimit = &entities.RateLimit{
+		Requests: 10,
+		Per:      time.Minute,
+		Key:      entities.RateLimitKeyUser,
+	}
+	gameAttemptRateLimit = &entities.RateLimit{
+		Requests: 30,
+		Per:      time.Minute,
+		Burst:    10,
+		Key:      entities.RateLimitKeyUser,
+	}
+	gameEventsRateLimit = &entities.RateLimit{
+		Requests: 20,
+		Per:      time.Minute,
+		Key:      entities.RateLimitKeyUser,
+	}
+)
+
+// Event stream settings
+const (
+	// gameEventsWriteWait is how long writing an event can take. Each write pushes the deadline forward, so that the
+	// stream isn't cut by the server's write timeout
+	gameEventsWriteWait = 10 * time.Second
+
+	// gameEventsKeepAlive is how often a comment is sent while there are no events, so that proxies keep the stream
+	gameEventsKeepAlive = 30 * time.Second
+
+	// gameEventsRetry is how long browsers wait before reconnecting, in milliseconds. Tickets are single use, so
+	// reconnecting to the same URL fails; clients should rather open a new stream with a fresh ticket
+	gameEventsRetry = 3000
+)
+
 func (m gameModule) Path() string {
 	return m.path
 }
@@ -45,21 +86,52 @@ func (m gameModule) Setup(r *mux.Router) ([]entities.RouteDefinition, *mux.Route
 			Path:        "/start",
 			Handler:     m.start,
 			HttpMethods: []string{http.MethodPost},
+			RateLimit:   gameStartRateLimit,
+		},
+		{
+			Path:        "/daily",
+			Handler:     m.daily,
+			HttpMethods: []string{http.MethodPost},
+			RateLimit:   gameStartRateLimit,
 		},
 		{
 			Path:        "/attempt",
 			Handler:     m.attempt,
 			HttpMethods: []string{http.MethodPost},
+			RateLimit:   gameAttemptRateLimit,
+		},
+		{
+			Path:        "/forfeit",
+			Handler:     m.forfeit,
+			HttpMethods: []string{http.MethodPost},
 		},
 		{
 			Path:        "/getActive",
 			Handler:     m.getActive,
 			HttpMethods: []string{http.MethodGet},
 		},
+		{
+			Path:        "/history",
+			Handler:     m.history,
+			HttpMethods: []string{http.MethodGet},
+		},
+		{
+			Path:        "/{id:[0-9]+}",
+			Handler:     m.replay,
+			HttpMethods: []string{http.MethodGet},
+		},
+		{
+			Path:        "/events",
+			Handler:     m.events,
+			HttpMethods: []string{http.MethodGet},
+			RateLimit:   gameEventsRateLimit,
+		},
 	}
 
 	for _, d := range defs {
-		r.HandleFunc(d.Path, d.Handler).Methods(d.HttpMethods...)
+		handler := m.limiter.Wrap(m.path+d.Path, d.RateLimit, d.Handler)
+		handler = util.RequireRoles(d.Roles, handler)
+		r.Handle(d.Path, handler).Methods(d.HttpMethods...)
 	}
 
 	return defs, nil
@@ -73,14 +145,20 @@ func (m gameModule) start(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var body struct {
-		WordLength uint32 `json:"word_length"`
-		WordCount  uint32 `json:"word_count"`
+		WordLength       uint32 `json:"word_length"`
+		WordCount        uint32 `json:"word_count"`
+		AllowFreeGuesses bool   `json:"allow_free_guesses"`
+		HardMode         bool   `json:"hard_mode"`
 	}
 	if !util.ReadBody(w, r, &body) {
 		return
 	}
 
-	status, err := m.service.StartGame(r.Context(), user, body.WordLength, body.WordCount)
+	options := entities.GameOptions{
+		AllowFreeGuesses: body.AllowFreeGuesses,
+		HardMode:         body.HardMode,
+	}
+	status, err := m.service.StartGame(r.Context(), user, body.WordLength, body.WordCount, options)
 	if err != nil {
 		log.Printf("[StartGame] | %v", err)
 		util.WriteInternalError(w)
@@ -102,6 +180,47 @@ func (m gameModule) start(w http.ResponseWriter, r *http.Request) {
 	util.WriteResponseJSON(w, response)
 }
 
+func (m gameModule) daily(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	var body struct {
+		WordCount uint32 `json:"word_count"`
+		HardMode  bool   `json:"hard_mode"`
+	}
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	status, date, err := m.service.StartDailyGame(r.Context(), user, body.WordCount, body.HardMode)
+	if err != nil {
+		log.Printf("[StartDailyGame] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	var maxAttempts uint32
+	if status == status_codes.GameStartSuccess {
+		maxAttempts = rules.GetGameMaxAttempts(rules.DailyWordLength, body.WordCount)
+	}
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.GameStart]
+		MaxAttempts uint32 `json:"max_attempts,omitempty"`
+		WordLength  uint32 `json:"word_length"`
+		DailyDate   string `json:"daily_date"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		MaxAttempts:             maxAttempts,
+		WordLength:              rules.DailyWordLength,
+		DailyDate:               date.Format(entities.DailyDateFormat),
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
 func (m gameModule) attempt(w http.ResponseWriter, r *http.Request) {
 	user, err := util.GetUser(r)
 	if err != nil {
@@ -125,14 +244,41 @@ func (m gameModule) attempt(w http.ResponseWriter, r *http.Request) {
 
 	response := struct {
 		util.DefaultEndpointResponse[status_codes.GameAttempt]
-		GameState []entities.GameWordState `json:"game_state,omitempty"`
-		Words     []string                 `json:"words,omitempty"`
-		Won       bool                     `json:"won"`
+		GameState         []entities.GameWordState    `json:"game_state,omitempty"`
+		Words             []string                    `json:"words,omitempty"`
+		Won               bool                        `json:"won"`
+		HardModeViolation *entities.HardModeViolation `json:"hard_mode_violation,omitempty"`
 	}{
 		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(data.Status),
 		GameState:               data.GameState,
 		Words:                   data.Words,
 		Won:                     data.Won,
+		HardModeViolation:       data.HardModeViolation,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m gameModule) forfeit(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	status, words, err := m.service.ForfeitGame(r.Context(), user)
+	if err != nil {
+		log.Printf("[ForfeitGame] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.GameForfeit]
+		Words []string `json:"words,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		Words:                   words,
 	}
 
 	util.WriteResponseJSON(w, response)
@@ -157,3 +303,174 @@ func (m gameModule) getActive(w http.ResponseWriter, r *http.Request) {
 		game.ToResponse(gameStatuses, rules.GetGameMaxAttempts(game.GetWordLength(), game.GetWordCount())),
 	)
 }
+
+func (m gameModule) history(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	query := r.URL.Query()
+
+	var limit uint64
+	if query.Has("limit") {
+		limit, err = strconv.ParseUint(query.Get("limit"), 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	status, games, nextCursor, err := m.service.GetGameHistory(r.Context(), user, query.Get("cursor"), uint32(limit))
+	if err != nil {
+		log.Printf("[GetGameHistory] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.GameHistory]
+		Games      []entities.GameSummary `json:"games,omitempty"`
+		NextCursor string                 `json:"next_cursor,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		Games:                   games,
+		NextCursor:              nextCursor,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m gameModule) replay(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	gameID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid game ID", http.StatusBadRequest)
+		return
+	}
+
+	data, err := m.service.GetGameReplay(r.Context(), user, gameID)
+	if err != nil {
+		log.Printf("[GetGameReplay] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	var game *entities.GameReplayResponse
+	if data.Game != nil {
+		maxAttempts := rules.GetGameMaxAttempts(data.Game.GetWordLength(), data.Game.GetWordCount())
+		replay := data.Game.ToReplayResponse(data.GameStates, data.Words, maxAttempts)
+		game = &replay
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.GameReplay]
+		Game *entities.GameReplayResponse `json:"game,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(data.Status),
+		Game:                    game,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+// events streams the user's game events with Server-Sent Events. Streams are opened with single-use tickets, so
+// clients reconnect by fetching a fresh ticket and opening a new stream, sending the ID of the last event they got in
+// the last_event_id query parameter. The Last-Event-ID header, which browsers can't set on new streams, is also read
+func (m gameModule) events(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	subscription, missed := m.service.SubscribeEvents(user, lastEventID)
+	defer subscription.Close()
+
+	controller := http.NewResponseController(w)
+	write := func(writeFunc func() error) bool {
+		err := controller.SetWriteDeadline(time.Now().Add(gameEventsWriteWait))
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return false
+		}
+
+		err = writeFunc()
+		if err == nil {
+			err = controller.Flush()
+		}
+		return err == nil
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	ok := write(func() error {
+		_, err := fmt.Fprintf(w, "retry: %d\n\n", gameEventsRetry)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range missed {
+			err = writeGameEvent(w, event)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(gameEventsKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, open := <-subscription.Events():
+			// Subscriptions that fell behind are closed; the client reconnects and gets the missed events
+			if !open {
+				return
+			}
+
+			ok = write(func() error {
+				return writeGameEvent(w, event)
+			})
+		case <-ticker.C:
+			ok = write(func() error {
+				_, err := fmt.Fprint(w, ": keep-alive\n\n")
+				return err
+			})
+		case <-r.Context().Done():
+			return
+		}
+
+		if !ok {
+			return
+		}
+	}
+}
+
+// writeGameEvent writes an event in the Server-Sent Events format
+func writeGameEvent(w http.ResponseWriter, event entities.UserEvent) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
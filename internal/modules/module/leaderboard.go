@@ -0,0 +1,123 @@
+package module
+
+import (
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"strconv"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/modules/service"
+	"termo_back_end/internal/status_codes"
+	"termo_back_end/internal/util"
+	"time"
+)
+
+type leaderboardModule struct {
+	service service.LeaderboardService
+	limiter *util.RateLimiter
+	path    string
+}
+
+func NewLeaderboardModule(service service.LeaderboardService, limiter *util.RateLimiter) entities.Module {
+	return leaderboardModule{
+		service: service,
+		limiter: limiter,
+		path:    "/leaderboard",
+	}
+}
+
+// leaderboardRateLimit limits leaderboard queries, which are expensive to compute
+var leaderboardRateLimit = &entities.RateLimit{
+	Requests: 30,
+	Per:      time.Minute,
+	Key:      entities.RateLimitKeyUser,
+}
+
+func (m leaderboardModule) Path() string {
+	return m.path
+}
+
+func (m leaderboardModule) Setup(r *mux.Router) ([]entities.RouteDefinition, *mux.Router) {
+	defs := []entities.RouteDefinition{
+		{
+			Path:        "/score",
+			Handler:     m.handler(entities.LeaderboardKindScore),
+			HttpMethods: []string{http.MethodGet},
+			RateLimit:   leaderboardRateLimit,
+		},
+		{
+			Path:        "/winRate",
+			Handler:     m.handler(entities.LeaderboardKindWinRate),
+			HttpMethods: []string{http.MethodGet},
+			RateLimit:   leaderboardRateLimit,
+		},
+		{
+			Path:        "/averageAttempts",
+			Handler:     m.handler(entities.LeaderboardKindAverageAttempts),
+			HttpMethods: []string{http.MethodGet},
+			RateLimit:   leaderboardRateLimit,
+		},
+	}
+
+	for _, d := range defs {
+		handler := m.limiter.Wrap(m.path+d.Path, d.RateLimit, d.Handler)
+		handler = util.RequireRoles(d.Roles, handler)
+		r.Handle(d.Path, handler).Methods(d.HttpMethods...)
+	}
+
+	return defs, nil
+}
+
+// handler builds the handler for a leaderboard kind
+//
+// Accepts the optional query parameters word_length, word_count, window (all, week or daily), page and limit
+func (m leaderboardModule) handler(kind entities.LeaderboardKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := util.GetUser(r)
+		if err != nil {
+			util.WriteInternalError(w)
+			return
+		}
+
+		query := r.URL.Query()
+
+		var params [4]uint64
+		for i, name := range []string{"word_length", "word_count", "page", "limit"} {
+			if !query.Has(name) {
+				continue
+			}
+
+			params[i], err = strconv.ParseUint(query.Get(name), 10, 32)
+			if err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+		}
+
+		status, leaderboard, err := m.service.GetLeaderboard(
+			r.Context(),
+			user,
+			kind,
+			entities.LeaderboardWindow(query.Get("window")),
+			uint32(params[0]),
+			uint32(params[1]),
+			uint32(params[2]),
+			uint32(params[3]),
+		)
+		if err != nil {
+			log.Printf("[GetLeaderboard] | %v", err)
+			util.WriteInternalError(w)
+			return
+		}
+
+		response := struct {
+			util.DefaultEndpointResponse[status_codes.LeaderboardGet]
+			*entities.Leaderboard
+		}{
+			DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+			Leaderboard:             leaderboard,
+		}
+
+		util.WriteResponseJSON(w, response)
+	}
+}
@@ -0,0 +1,359 @@
+package module
+
+import (
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/modules/service"
+	"termo_back_end/internal/status_codes"
+	"termo_back_end/internal/util"
+	"time"
+)
+
+/*
+
+Endpoints:
+ - /create: creates a private lobby hosted by the user; returns it along with its join code
+ - /{code}/join: joins a lobby
+ - /{code}: returns a lobby; players only
+ - /{code}/leave: leaves a lobby, passing the host role on if needed
+ - /{code}/settings: changes the settings of the next rounds; host only
+ - /{code}/start: starts a round; host only
+ - /{code}/attempt: registers an attempt on the user's game in the current round
+ - /{code}/kick: removes a player from a lobby; host only
+ - /{code}/transfer: makes another player the host; host only
+
+Lobby changes are sent to every player through the game module's events endpoint
+
+*/
+
+type lobbyModule struct {
+	service service.LobbyService
+	limiter *util.RateLimiter
+	path    string
+}
+
+func NewLobbyModule(service service.LobbyService, limiter *util.RateLimiter) entities.Module {
+	return lobbyModule{
+		service: service,
+		limiter: limiter,
+		path:    "/lobby",
+	}
+}
+
+// Rate limits of lobby routes. Joins are limited so that codes can't be guessed
+var (
+	lobbyCreateRateLimit = &entities.RateLimit{
+		Requests: 10,
+		Per:      time.Minute,
+		Key:      entities.RateLimitKeyUser,
+	}
+	lobbyJoinRateLimit = &entities.RateLimit{
+		Requests: 20,
+		Per:      time.Minute,
+		Key:      entities.RateLimitKeyUser,
+	}
+)
+
+func (m lobbyModule) Path() string {
+	return m.path
+}
+
+func (m lobbyModule) Setup(r *mux.Router) ([]entities.RouteDefinition, *mux.Router) {
+	defs := []entities.RouteDefinition{
+		{
+			Path:        "/create",
+			Handler:     m.create,
+			HttpMethods: []string{http.MethodPost},
+			RateLimit:   lobbyCreateRateLimit,
+		},
+		{
+			Path:        "/{code}/join",
+			Handler:     m.join,
+			HttpMethods: []string{http.MethodPost},
+			RateLimit:   lobbyJoinRateLimit,
+		},
+		{
+			Path:        "/{code}",
+			Handler:     m.get,
+			HttpMethods: []string{http.MethodGet},
+		},
+		{
+			Path:        "/{code}/leave",
+			Handler:     m.leave,
+			HttpMethods: []string{http.MethodPost},
+		},
+		{
+			Path:        "/{code}/settings",
+			Handler:     m.settings,
+			HttpMethods: []string{http.MethodPost},
+		},
+		{
+			Path:        "/{code}/start",
+			Handler:     m.start,
+			HttpMethods: []string{http.MethodPost},
+			RateLimit:   gameStartRateLimit,
+		},
+		{
+			Path:        "/{code}/attempt",
+			Handler:     m.attempt,
+			HttpMethods: []string{http.MethodPost},
+			RateLimit:   gameAttemptRateLimit,
+		},
+		{
+			Path:        "/{code}/kick",
+			Handler:     m.kick,
+			HttpMethods: []string{http.MethodPost},
+		},
+		{
+			Path:        "/{code}/transfer",
+			Handler:     m.transfer,
+			HttpMethods: []string{http.MethodPost},
+		},
+	}
+
+	for _, d := range defs {
+		handler := m.limiter.Wrap(m.path+d.Path, d.RateLimit, d.Handler)
+		handler = util.RequireRoles(d.Roles, handler)
+		r.Handle(d.Path, handler).Methods(d.HttpMethods...)
+	}
+
+	return defs, nil
+}
+
+func (m lobbyModule) create(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	var body entities.LobbySettings
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	status, lobby, err := m.service.CreateLobby(r.Context(), user, body)
+	if err != nil {
+		log.Printf("[CreateLobby] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.LobbySettings]
+		Lobby *entities.LobbyResponse `json:"lobby,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		Lobby:                   lobby,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m lobbyModule) join(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	status, lobby, err := m.service.JoinLobby(r.Context(), user, mux.Vars(r)["code"])
+	if err != nil {
+		log.Printf("[JoinLobby] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.LobbyJoin]
+		Lobby *entities.LobbyResponse `json:"lobby,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		Lobby:                   lobby,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m lobbyModule) get(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	status, lobby, err := m.service.GetLobby(r.Context(), user, mux.Vars(r)["code"])
+	if err != nil {
+		log.Printf("[GetLobby] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.LobbyGet]
+		Lobby *entities.LobbyResponse `json:"lobby,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		Lobby:                   lobby,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m lobbyModule) leave(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	status, err := m.service.LeaveLobby(r.Context(), user, mux.Vars(r)["code"])
+	if err != nil {
+		log.Printf("[LeaveLobby] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, util.BuildDefaultEndpointStatusResponse(status))
+}
+
+func (m lobbyModule) settings(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	var body entities.LobbySettings
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	status, lobby, err := m.service.UpdateSettings(r.Context(), user, mux.Vars(r)["code"], body)
+	if err != nil {
+		log.Printf("[UpdateSettings] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.LobbySettings]
+		Lobby *entities.LobbyResponse `json:"lobby,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		Lobby:                   lobby,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m lobbyModule) start(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	status, lobby, err := m.service.StartRound(r.Context(), user, mux.Vars(r)["code"])
+	if err != nil {
+		log.Printf("[StartRound] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.LobbyStart]
+		Lobby *entities.LobbyResponse `json:"lobby,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		Lobby:                   lobby,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m lobbyModule) attempt(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	var body struct {
+		Attempt string `json:"attempt"`
+	}
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	data, err := m.service.AttemptRound(r.Context(), user, mux.Vars(r)["code"], body.Attempt)
+	if err != nil {
+		log.Printf("[AttemptRound] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.LobbyAttempt]
+		GameState []entities.GameWordState `json:"game_state,omitempty"`
+		Result    *entities.GameResult     `json:"result,omitempty"`
+		Words     []string                 `json:"words,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(data.Status),
+		GameState:               data.GameState,
+		Result:                  data.Result,
+		Words:                   data.Words,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m lobbyModule) kick(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	var body struct {
+		UserID int64 `json:"user_id"`
+	}
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	status, err := m.service.KickPlayer(r.Context(), user, mux.Vars(r)["code"], body.UserID)
+	if err != nil {
+		log.Printf("[KickPlayer] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, util.BuildDefaultEndpointStatusResponse(status))
+}
+
+func (m lobbyModule) transfer(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	var body struct {
+		UserID int64 `json:"user_id"`
+	}
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	status, err := m.service.TransferHost(r.Context(), user, mux.Vars(r)["code"], body.UserID)
+	if err != nil {
+		log.Printf("[TransferHost] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, util.BuildDefaultEndpointStatusResponse(status))
+}
@@ -1,28 +1,56 @@
 package module
 
 import (
+	"fmt"
 	"github.com/gorilla/mux"
+	"log"
 	"net/http"
+	"strconv"
 	"termo_back_end/internal/entities"
 	"termo_back_end/internal/modules/service"
 	"termo_back_end/internal/rules"
+	"termo_back_end/internal/status_codes"
 	"termo_back_end/internal/util"
+	"time"
 )
 
 type module struct {
-	service     service.UserService
-	gameService service.GameService
-	path        string
+	service      service.UserService
+	gameService  service.GameService
+	auditService service.AuditService
+	limiter      *util.RateLimiter
+	path         string
 }
 
-func NewUserModule(service service.UserService, gameService service.GameService) entities.Module {
+func NewUserModule(
+	service service.UserService,
+	gameService service.GameService,
+	auditService service.AuditService,
+	limiter *util.RateLimiter,
+) entities.Module {
 	return module{
-		service:     service,
-		gameService: gameService,
-		path:        "/user",
+		service:      service,
+		gameService:  gameService,
+		auditService: auditService,
+		limiter:      limiter,
+		path:         "/user",
 	}
 }
 
+// userUpdateRateLimit limits changes to the user's account
+var userUpdateRateLimit = &entities.RateLimit{
+	Requests: 5,
+	Per:      time.Minute,
+	Key:      entities.RateLimitKeyUser,
+}
+
+// userExportRateLimit limits data exports, which read every game of the user
+var userExportRateLimit = &entities.RateLimit{
+	Requests: 5,
+	Per:      time.Hour,
+	Key:      entities.RateLimitKeyUser,
+}
+
 func (m module) Path() string {
 	return m.path
 }
@@ -34,20 +62,52 @@ func (m module) Setup(r *mux.Router) ([]entities.RouteDefinition, *mux.Router) {
 			Handler:     m.getData,
 			HttpMethods: []string{http.MethodGet},
 		},
+		{
+			Path:        "/stats",
+			Handler:     m.stats,
+			HttpMethods: []string{http.MethodGet},
+		},
 		{
 			Path:        "/updateName",
 			Handler:     m.updateName,
 			HttpMethods: []string{http.MethodPost},
+			RateLimit:   userUpdateRateLimit,
 		},
 		{
 			Path:        "/updatePassword",
 			Handler:     m.updatePassword,
 			HttpMethods: []string{http.MethodPost},
+			RateLimit:   userUpdateRateLimit,
+		},
+		{
+			Path:        "/updateEmail",
+			Handler:     m.updateEmail,
+			HttpMethods: []string{http.MethodPost},
+			RateLimit:   userUpdateRateLimit,
+		},
+		{
+			Path:        "/securityEvents",
+			Handler:     m.securityEvents,
+			HttpMethods: []string{http.MethodGet},
+		},
+		{
+			Path:        "/export",
+			Handler:     m.export,
+			HttpMethods: []string{http.MethodGet},
+			RateLimit:   userExportRateLimit,
+		},
+		{
+			Path:        "/delete",
+			Handler:     m.delete,
+			HttpMethods: []string{http.MethodPost},
+			RateLimit:   userUpdateRateLimit,
 		},
 	}
 
 	for _, d := range defs {
-		r.HandleFunc(d.Path, d.Handler).Methods(d.HttpMethods...)
+		handler := m.limiter.Wrap(m.path+d.Path, d.RateLimit, d.Handler)
+		handler = util.RequireRoles(d.Roles, handler)
+		r.Handle(d.Path, handler).Methods(d.HttpMethods...)
 	}
 
 	return defs, nil
@@ -67,6 +127,14 @@ func (m module) getData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Get stats
+	stats, err := m.service.GetStats(r.Context(), user)
+	if err != nil {
+		log.Printf("[GetStats] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
 	var maxAttempts *uint32
 	if game != nil {
 		_max := rules.GetGameMaxAttempts(game.GetWordLength(), game.GetWordCount())
@@ -76,9 +144,27 @@ func (m module) getData(w http.ResponseWriter, r *http.Request) {
 		game,
 		gameStatuses,
 		maxAttempts,
+		stats,
 	))
 }
 
+func (m module) stats(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	stats, err := m.service.GetStats(r.Context(), user)
+	if err != nil {
+		log.Printf("[GetStats] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, stats)
+}
+
 func (m module) updateName(w http.ResponseWriter, r *http.Request) {
 	user, err := util.GetUser(r)
 	if err != nil {
@@ -93,13 +179,23 @@ func (m module) updateName(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	status, err := m.service.UpdateName(r.Context(), user, body.NewName)
+	data, err := m.service.UpdateName(r.Context(), user, body.NewName)
 	if err != nil {
 		util.WriteInternalError(w)
 		return
 	}
 
-	util.WriteResponseJSON(w, util.BuildDefaultEndpointStatusResponse(status))
+	retryAfter := util.SetRetryAfter(w, data.RetryAfter)
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.UserUpdateName]
+		RetryAfter uint32 `json:"retry_after,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(data.Status),
+		RetryAfter:              retryAfter,
+	}
+
+	util.WriteResponseJSON(w, response)
 }
 
 func (m module) updatePassword(w http.ResponseWriter, r *http.Request) {
@@ -117,7 +213,129 @@ func (m module) updatePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	status, err := m.service.UpdatePassword(r.Context(), user, body.CurrentPassword, body.NewPassword)
+	status, passwordProblems, err := m.service.UpdatePassword(r.Context(), user, body.CurrentPassword, body.NewPassword)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.UserUpdatePassword]
+		PasswordProblems []rules.PasswordProblem `json:"password_problems,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		PasswordProblems:        passwordProblems,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m module) updateEmail(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	var body struct {
+		NewEmail string `json:"new_email"`
+	}
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	status, err := m.service.UpdateEmail(r.Context(), user, body.NewEmail)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, util.BuildDefaultEndpointStatusResponse(status))
+}
+
+// securityEvents accepts the optional query parameters cursor and limit
+func (m module) securityEvents(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	query := r.URL.Query()
+
+	var limit uint64
+	if query.Has("limit") {
+		limit, err = strconv.ParseUint(query.Get("limit"), 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	status, events, nextCursor, err := m.auditService.GetUserEvents(
+		r.Context(),
+		user,
+		query.Get("cursor"),
+		uint32(limit),
+	)
+	if err != nil {
+		log.Printf("[GetUserEvents] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteResponseJSON(w, newAuditEventsResponse(status, events, nextCursor))
+}
+
+func (m module) export(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	stats, err := m.service.GetStats(r.Context(), user)
+	if err != nil {
+		log.Printf("[GetStats] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	games, err := m.gameService.ExportGames(r.Context(), user)
+	if err != nil {
+		log.Printf("[ExportGames] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	export := entities.UserExport{
+		ExportedAt: time.Now().UTC(),
+		Profile:    user.ToExportProfile(),
+		Stats:      stats,
+		Games:      games,
+	}
+
+	// Make browsers download the archive
+	filename := fmt.Sprintf("termo-export-%d.json", user.ID)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	util.WriteResponseJSON(w, export)
+}
+
+func (m module) delete(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	var body struct {
+		Password string `json:"password"`
+	}
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	status, err := m.service.DeleteAccount(r.Context(), user, body.Password)
 	if err != nil {
 		util.WriteInternalError(w)
 		return
@@ -0,0 +1,380 @@
+package module
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"log"
+	"net/http"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/modules/service"
+	"termo_back_end/internal/status_codes"
+	"termo_back_end/internal/util"
+	"time"
+)
+
+/*
+
+Endpoints:
+ - /rooms (POST): creates a room; returns its code
+ - /rooms (GET): returns the public rooms waiting for players
+ - /rooms/{code}/ws: joins a room and upgrades to a websocket the match is played through
+
+Websocket messages are JSON objects with a type and some data. Players send:
+ - START: starts the match; host only
+ - ATTEMPT: {"attempt": "..."}; makes an attempt
+ - FORFEIT: gives up the match
+
+Each of them is answered with a message of the same type and a status. The server also sends the room's events
+(ROOM, PROGRESS and FINISHED), and ERROR when a message can't be read
+
+*/
+
+type versusModule struct {
+	service  service.VersusService
+	limiter  *util.RateLimiter
+	upgrader websocket.Upgrader
+	path     string
+}
+
+func NewVersusModule(
+	config entities.Config,
+	service service.VersusService,
+	limiter *util.RateLimiter,
+) entities.Module {
+	allowedOrigins := config.Server.AllowedOrigins
+
+	return versusModule{
+		service: service,
+		limiter: limiter,
+		// Browsers let any site open websockets, so connections are only accepted from the allowed front ends
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin: func(r *http.Request) bool {
+				return util.CheckOrigin(r, allowedOrigins)
+			},
+		},
+		path: "/versus",
+	}
+}
+
+// Rate limits of versus routes
+var (
+	versusCreateRateLimit = &entities.RateLimit{
+		Requests: 10,
+		Per:      time.Minute,
+		Key:      entities.RateLimitKeyUser,
+	}
+	versusConnectRateLimit = &entities.RateLimit{
+		Requests: 20,
+		Per:      time.Minute,
+		Key:      entities.RateLimitKeyUser,
+	}
+)
+
+// Websocket connection settings
+const (
+	// versusWriteWait is how long writing a message can take. Hijacked connections aren't bound by the server's write
+	// timeout, so each write sets its own deadline
+	versusWriteWait = 10 * time.Second
+
+	// versusPongWait is how long the connection can go without a pong before being closed
+	versusPongWait = 60 * time.Second
+
+	// versusPingPeriod must be shorter than versusPongWait
+	versusPingPeriod = versusPongWait * 9 / 10
+
+	// versusMaxMessageSize is the largest message players can send, in bytes
+	versusMaxMessageSize = 1024
+
+	// versusReplyBuffer is how many replies can wait to be written
+	versusReplyBuffer = 8
+)
+
+// Types of websocket messages, besides the room events
+const (
+	versusMessageStart   = "START"
+	versusMessageAttempt = "ATTEMPT"
+	versusMessageForfeit = "FORFEIT"
+	versusMessageError   = "ERROR"
+)
+
+// versusMessage is the envelope of every websocket message
+type versusMessage struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// versusReply is the envelope of the replies to the players' messages
+type versusReply struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+func (m versusModule) Path() string {
+	return m.path
+}
+
+func (m versusModule) Setup(r *mux.Router) ([]entities.RouteDefinition, *mux.Router) {
+	defs := []entities.RouteDefinition{
+		{
+			Path:        "/rooms",
+			Handler:     m.create,
+			HttpMethods: []string{http.MethodPost},
+			RateLimit:   versusCreateRateLimit,
+		},
+		{
+			Path:        "/rooms",
+			Handler:     m.list,
+			HttpMethods: []string{http.MethodGet},
+		},
+		{
+			Path:        "/rooms/{code}/ws",
+			Handler:     m.connect,
+			HttpMethods: []string{http.MethodGet},
+			RateLimit:   versusConnectRateLimit,
+		},
+	}
+
+	for _, d := range defs {
+		handler := m.limiter.Wrap(m.path+d.Path, d.RateLimit, d.Handler)
+		handler = util.RequireRoles(d.Roles, handler)
+		r.Handle(d.Path, handler).Methods(d.HttpMethods...)
+	}
+
+	return defs, nil
+}
+
+func (m versusModule) create(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	var body entities.VersusRoomOptions
+	if !util.ReadBody(w, r, &body) {
+		return
+	}
+
+	status, code, err := m.service.CreateRoom(user, body)
+	if err != nil {
+		log.Printf("[CreateRoom] | %v", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	response := struct {
+		util.DefaultEndpointResponse[status_codes.VersusCreate]
+		Code string `json:"code,omitempty"`
+	}{
+		DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		Code:                    code,
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m versusModule) list(w http.ResponseWriter, r *http.Request) {
+	response := struct {
+		Rooms []entities.VersusRoomSummary `json:"rooms"`
+	}{
+		Rooms: m.service.ListRooms(),
+	}
+
+	util.WriteResponseJSON(w, response)
+}
+
+func (m versusModule) connect(w http.ResponseWriter, r *http.Request) {
+	user, err := util.GetUser(r)
+	if err != nil {
+		util.WriteInternalError(w)
+		return
+	}
+
+	// Join before upgrading, so that failures get a regular response
+	status, session := m.service.JoinRoom(user, mux.Vars(r)["code"])
+	if status != status_codes.VersusJoinSuccess {
+		response := struct {
+			util.DefaultEndpointResponse[status_codes.VersusJoin]
+		}{
+			DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		}
+
+		util.WriteResponseJSON(w, response)
+		return
+	}
+
+	// Upgrade writes an error response by itself
+	conn, err := m.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("[Upgrade] | %v", err)
+		session.Close()
+		return
+	}
+
+	replies := make(chan versusReply, versusReplyBuffer)
+	readerDone := make(chan struct{})
+	writerDone := make(chan struct{})
+
+	go func() {
+		defer close(writerDone)
+		m.writePump(conn, session, replies, readerDone)
+	}()
+
+	m.readPump(conn, session, replies, writerDone)
+
+	close(readerDone)
+	<-writerDone
+	session.Close()
+}
+
+// readPump reads the player's messages until the connection fails or the writer stops, queueing a reply for each
+func (m versusModule) readPump(
+	conn *websocket.Conn,
+	session *service.VersusSession,
+	replies chan<- versusReply,
+	writerDone <-chan struct{},
+) {
+	conn.SetReadLimit(versusMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(versusPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(versusPongWait))
+	})
+
+	for {
+		var message versusMessage
+		var reply versusReply
+		err := conn.ReadJSON(&message)
+		if err != nil {
+			// Malformed messages are answered, while any other error means the connection is gone
+			var syntaxErr *json.SyntaxError
+			var decodingErr *json.UnmarshalTypeError
+			if !errors.As(err, &syntaxErr) && !errors.As(err, &decodingErr) {
+				return
+			}
+
+			reply = newVersusErrorReply("Invalid JSON")
+		} else {
+			reply = m.handleMessage(session, message)
+		}
+
+		select {
+		case replies <- reply:
+		case <-writerDone:
+			return
+		}
+	}
+}
+
+// handleMessage runs a player's message, returning its reply
+func (m versusModule) handleMessage(session *service.VersusSession, message versusMessage) versusReply {
+	switch message.Type {
+	case versusMessageStart:
+		status, err := session.Start()
+		if err != nil {
+			log.Printf("[Start] | %v", err)
+			return newVersusErrorReply("internal error")
+		}
+
+		response := struct {
+			util.DefaultEndpointResponse[status_codes.VersusStart]
+		}{
+			DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(status),
+		}
+
+		return versusReply{Type: message.Type, Data: response}
+	case versusMessageAttempt:
+		var body struct {
+			Attempt string `json:"attempt"`
+		}
+		err := json.Unmarshal(message.Data, &body)
+		if err != nil {
+			return newVersusErrorReply("Invalid JSON")
+		}
+
+		data := session.Attempt(body.Attempt)
+
+		response := struct {
+			util.DefaultEndpointResponse[status_codes.VersusAttempt]
+			GameState entities.GameState   `json:"game_state,omitempty"`
+			Result    *entities.GameResult `json:"result,omitempty"`
+			Words     []string             `json:"words,omitempty"`
+		}{
+			DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(data.Status),
+			GameState:               data.GameState,
+			Result:                  data.Result,
+			Words:                   data.Words,
+		}
+
+		return versusReply{Type: message.Type, Data: response}
+	case versusMessageForfeit:
+		response := struct {
+			util.DefaultEndpointResponse[status_codes.VersusForfeit]
+		}{
+			DefaultEndpointResponse: util.BuildDefaultEndpointStatusResponse(session.Forfeit()),
+		}
+
+		return versusReply{Type: message.Type, Data: response}
+	default:
+		return newVersusErrorReply("Unknown message type")
+	}
+}
+
+// writePump writes the room's events and the replies to the player's messages, pinging the player in between. It
+// closes the connection once the session is closed, a write fails or the reader stops
+func (m versusModule) writePump(
+	conn *websocket.Conn,
+	session *service.VersusSession,
+	replies <-chan versusReply,
+	readerDone <-chan struct{},
+) {
+	ticker := time.NewTicker(versusPingPeriod)
+	defer ticker.Stop()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	for {
+		var err error
+
+		select {
+		case event, ok := <-session.Events():
+			_ = conn.SetWriteDeadline(time.Now().Add(versusWriteWait))
+			if !ok {
+				// The session was replaced by a new one or fell behind
+				_ = conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session closed"))
+				return
+			}
+
+			err = conn.WriteJSON(event)
+		case reply := <-replies:
+			_ = conn.SetWriteDeadline(time.Now().Add(versusWriteWait))
+			err = conn.WriteJSON(reply)
+		case <-ticker.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(versusWriteWait))
+			err = conn.WriteMessage(websocket.PingMessage, nil)
+		case <-readerDone:
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// newVersusErrorReply builds the reply to a message that couldn't be handled
+func newVersusErrorReply(message string) versusReply {
+	return versusReply{
+		Type: versusMessageError,
+		Data: struct {
+			Message string `json:"message"`
+		}{
+			Message: message,
+		},
+	}
+}
@@ -0,0 +1,67 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"termo_back_end/internal/entities"
+)
+
+type AdminRepository interface {
+	// GetServerStats counts users and games for an overview of the server's usage
+	//
+	// Only the database counts are filled; StartedAt and UptimeSeconds are left empty
+	GetServerStats(ctx context.Context) (*entities.ServerStats, error)
+}
+
+type adminRepo struct {
+	db *sql.DB
+}
+
+func NewAdminRepo(db *sql.DB) AdminRepository {
+	return adminRepo{
+		db: db,
+	}
+}
+
+func (r adminRepo) GetServerStats(ctx context.Context) (*entities.ServerStats, error) {
+	queryUsers := `
+	SELECT COALESCE(SUM(NOT is_guest), 0),
+	       COALESCE(SUM(is_guest), 0),
+	       COALESCE(SUM(
+	           banned_at IS NOT NULL
+	           AND (banned_until IS NULL OR banned_until > UTC_TIMESTAMP())
+	       ), 0),
+	       COALESCE(SUM(created_at >= UTC_DATE()), 0)
+	FROM user
+	`
+
+	var stats entities.ServerStats
+	err := r.db.QueryRowContext(ctx, queryUsers).Scan(
+		&stats.Users,
+		&stats.Guests,
+		&stats.BannedUsers,
+		&stats.UsersToday,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("[QueryRowContext] | %v", err)
+	}
+
+	queryGames := `
+	SELECT COUNT(*),
+	       COALESCE(SUM(result IS NULL), 0),
+	       COALESCE(SUM(started_at >= UTC_DATE()), 0)
+	FROM game
+	`
+
+	err = r.db.QueryRowContext(ctx, queryGames).Scan(
+		&stats.Games,
+		&stats.ActiveGames,
+		&stats.GamesToday,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("[QueryRowContext] | %v", err)
+	}
+
+	return &stats, nil
+}
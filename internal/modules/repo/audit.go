@@ -0,0 +1,152 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/util"
+)
+
+type AuditRepository interface {
+	// InsertEvent appends an event to the audit log
+	InsertEvent(ctx context.Context, event entities.AuditEvent) error
+
+	// GetEvents returns up to limit events matching the filter, from the newest to the oldest. If beforeID is not 0,
+	// only events older than the one with that ID are returned
+	GetEvents(
+		ctx context.Context,
+		filter entities.AuditFilter,
+		beforeID int64,
+		limit uint32,
+	) ([]entities.AuditEvent, error)
+}
+
+type auditRepo struct {
+	db *sql.DB
+}
+
+func NewAuditRepo(db *sql.DB) AuditRepository {
+	return auditRepo{
+		db: db,
+	}
+}
+
+func (r auditRepo) InsertEvent(ctx context.Context, event entities.AuditEvent) error {
+	query := `
+	INSERT INTO audit_event (
+		id_user,
+		id_actor,
+		event,
+		ip,
+		user_agent,
+		details,
+		created_at
+	) VALUES (?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())
+	`
+
+	var details *string
+	if len(event.Details) > 0 {
+		encoded, err := json.Marshal(event.Details)
+		if err != nil {
+			return fmt.Errorf("[json.Marshal] | %v", err)
+		}
+
+		detailsString := string(encoded)
+		details = &detailsString
+	}
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		event.UserID,
+		event.ActorID,
+		event.Type,
+		event.IP,
+		event.UserAgent,
+		details,
+	)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	return nil
+}
+
+func (r auditRepo) GetEvents(
+	ctx context.Context,
+	filter entities.AuditFilter,
+	beforeID int64,
+	limit uint32,
+) ([]entities.AuditEvent, error) {
+	query := `
+	SELECT id,
+	       id_user,
+	       id_actor,
+	       event,
+	       ip,
+	       user_agent,
+	       details,
+	       created_at
+	FROM audit_event
+	WHERE (? IS NULL OR id_user = ?)
+	  AND (? = '' OR event = ?)
+	  AND (? = 0 OR id < ?)
+	ORDER BY id DESC
+	LIMIT ?
+	`
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		query,
+		filter.UserID, filter.UserID,
+		filter.Type, filter.Type,
+		beforeID, beforeID,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("[QueryContext] | %v", err)
+	}
+	defer util.DeferRowsClose(rows)
+
+	events := make([]entities.AuditEvent, 0)
+	for rows.Next() {
+		var (
+			event   entities.AuditEvent
+			userID  sql.NullInt64
+			actorID sql.NullInt64
+			details sql.NullString
+		)
+		err = rows.Scan(
+			&event.ID,
+			&userID,
+			&actorID,
+			&event.Type,
+			&event.IP,
+			&event.UserAgent,
+			&details,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("[Scan] | %v", err)
+		}
+
+		if userID.Valid {
+			event.UserID = &userID.Int64
+		}
+		if actorID.Valid {
+			event.ActorID = &actorID.Int64
+		}
+		if details.Valid {
+			err = json.Unmarshal([]byte(details.String), &event.Details)
+			if err != nil {
+				return nil, fmt.Errorf("[json.Unmarshal] | %v", err)
+			}
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,376 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/util"
+	"time"
+)
+
+type AuthRepository interface {
+	// CreateRefreshToken registers a refresh token for the provided user
+	//
+	// Token is expected to be already hashed; will be inserted as is
+	CreateRefreshToken(
+		ctx context.Context,
+		userID int64,
+		tokenHash string,
+		family string,
+		expiresAt time.Time,
+	) error
+
+	// GetRefreshToken attempts to find a refresh token by its hash; returns nil if not found
+	GetRefreshToken(ctx context.Context, tokenHash string) (*entities.RefreshToken, error)
+
+	// RevokeRefreshToken marks a refresh token as revoked, given its ID. Returns whether it was still valid, so that a
+	// token can't be used twice by concurrent requests
+	RevokeRefreshToken(ctx context.Context, id int64) (bool, error)
+
+	// RevokeRefreshTokenFamily marks every refresh token in a family as revoked
+	RevokeRefreshTokenFamily(ctx context.Context, family string) error
+
+	// RevokeUserRefreshTokens marks every refresh token of a user as revoked, given their ID
+	RevokeUserRefreshTokens(ctx context.Context, userID int64) error
+
+	// RevokeAccessToken adds an auth token identifier to the revocation list until it expires
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+
+	// IsAccessTokenRevoked checks whether an auth token identifier is in the revocation list
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+
+	// UseSingleUseToken adds the identifier of a token that can only be used once, such as a challenge token or a
+	// stream ticket, to the revocation list until it expires. Returns whether it wasn't already there, so that
+	// concurrent requests can't both use it
+	UseSingleUseToken(ctx context.Context, jti string, expiresAt time.Time) (bool, error)
+
+	// CreatePasswordResetToken registers a password reset token for the provided user. Every unused reset token
+	// previously issued to the user is invalidated
+	//
+	// Token is expected to be already hashed; will be inserted as is
+	CreatePasswordResetToken(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) error
+
+	// GetPasswordResetToken attempts to find a password reset token by its hash; returns nil if not found
+	GetPasswordResetToken(ctx context.Context, tokenHash string) (*entities.PasswordResetToken, error)
+
+	// UsePasswordResetToken marks a password reset token as used, given its ID. Returns whether it was still unused, so
+	// that a token can't be used twice by concurrent requests
+	UsePasswordResetToken(ctx context.Context, id int64) (bool, error)
+
+	// UseRecoveryCode marks an unused recovery code of a user as used, given its hash. Returns whether it was found
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error)
+}
+
+type authRepo struct {
+	db *sql.DB
+}
+
+func NewAuthRepo(db *sql.DB) AuthRepository {
+	return authRepo{
+		db: db,
+	}
+}
+
+func (r authRepo) CreateRefreshToken(
+	ctx context.Context,
+	userID int64,
+	tokenHash string,
+	family string,
+	expiresAt time.Time,
+) error {
+	query := `
+	INSERT INTO refresh_token (
+		id_user,
+		token_hash,
+		family,
+		created_at,
+		expires_at
+	) VALUES (?, ?, ?, UTC_TIMESTAMP(), ?)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID, tokenHash, family, expiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	return nil
+}
+
+func (r authRepo) GetRefreshToken(ctx context.Context, tokenHash string) (*entities.RefreshToken, error) {
+	query := `
+	SELECT id,
+	       id_user,
+	       family,
+	       expires_at,
+	       revoked_at
+	FROM refresh_token
+	WHERE token_hash = ?
+	`
+
+	var (
+		token     entities.RefreshToken
+		revokedAt sql.NullTime
+	)
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Family,
+		&token.ExpiresAt,
+		&revokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("[QueryRowContext] | %v", err)
+	}
+
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return &token, nil
+}
+
+func (r authRepo) RevokeRefreshToken(ctx context.Context, id int64) (bool, error) {
+	query := `
+	UPDATE refresh_token
+	SET revoked_at = UTC_TIMESTAMP()
+	WHERE id = ?
+	  AND revoked_at IS NULL
+	`
+
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("[RowsAffected] | %v", err)
+	}
+
+	return affected > 0, nil
+}
+
+func (r authRepo) RevokeRefreshTokenFamily(ctx context.Context, family string) error {
+	query := `
+	UPDATE refresh_token
+	SET revoked_at = UTC_TIMESTAMP()
+	WHERE family = ?
+	  AND revoked_at IS NULL
+	`
+
+	_, err := r.db.ExecContext(ctx, query, family)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	return nil
+}
+
+func (r authRepo) RevokeUserRefreshTokens(ctx context.Context, userID int64) error {
+	query := `
+	UPDATE refresh_token
+	SET revoked_at = UTC_TIMESTAMP()
+	WHERE id_user = ?
+	  AND revoked_at IS NULL
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	return nil
+}
+
+func (r authRepo) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	// Clean up tokens that already expired, since they are rejected anyway
+	queryCleanup := `
+	DELETE FROM revoked_token
+	WHERE expires_at < UTC_TIMESTAMP()
+	`
+
+	_, err := r.db.ExecContext(ctx, queryCleanup)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	query := `
+	INSERT IGNORE INTO revoked_token (
+		jti,
+		expires_at
+	) VALUES (?, ?)
+	`
+
+	_, err = r.db.ExecContext(ctx, query, jti, expiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	return nil
+}
+
+func (r authRepo) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	query := `
+	SELECT COUNT(*)
+	FROM revoked_token
+	WHERE jti = ?
+	`
+
+	var count int64
+	err := r.db.QueryRowContext(ctx, query, jti).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("[QueryRowContext] | %v", err)
+	}
+
+	return count > 0, nil
+}
+
+func (r authRepo) UseSingleUseToken(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	query := `
+	INSERT IGNORE INTO revoked_token (
+		jti,
+		expires_at
+	) VALUES (?, ?)
+	`
+
+	res, err := r.db.ExecContext(ctx, query, jti, expiresAt.UTC())
+	if err != nil {
+		return false, fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("[RowsAffected] | %v", err)
+	}
+
+	return affected > 0, nil
+}
+
+func (r authRepo) CreatePasswordResetToken(
+	ctx context.Context,
+	userID int64,
+	tokenHash string,
+	expiresAt time.Time,
+) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("[BeginTx] | %v", err)
+	}
+	defer util.DeferTxRollback(tx)
+
+	// Only the latest token can be used
+	queryInvalidate := `
+	UPDATE password_reset_token
+	SET used_at = UTC_TIMESTAMP()
+	WHERE id_user = ?
+	  AND used_at IS NULL
+	`
+
+	_, err = tx.ExecContext(ctx, queryInvalidate, userID)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	query := `
+	INSERT INTO password_reset_token (
+		id_user,
+		token_hash,
+		created_at,
+		expires_at
+	) VALUES (?, ?, UTC_TIMESTAMP(), ?)
+	`
+
+	_, err = tx.ExecContext(ctx, query, userID, tokenHash, expiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("[Commit] | %v", err)
+	}
+
+	return nil
+}
+
+func (r authRepo) GetPasswordResetToken(ctx context.Context, tokenHash string) (*entities.PasswordResetToken, error) {
+	query := `
+	SELECT id,
+	       id_user,
+	       expires_at,
+	       used_at
+	FROM password_reset_token
+	WHERE token_hash = ?
+	`
+
+	var (
+		token  entities.PasswordResetToken
+		usedAt sql.NullTime
+	)
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.ExpiresAt,
+		&usedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("[QueryRowContext] | %v", err)
+	}
+
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+
+	return &token, nil
+}
+
+func (r authRepo) UsePasswordResetToken(ctx context.Context, id int64) (bool, error) {
+	query := `
+	UPDATE password_reset_token
+	SET used_at = UTC_TIMESTAMP()
+	WHERE id = ?
+	  AND used_at IS NULL
+	`
+
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("[RowsAffected] | %v", err)
+	}
+
+	return affected > 0, nil
+}
+
+func (r authRepo) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	query := `
+	UPDATE recovery_code
+	SET used_at = UTC_TIMESTAMP()
+	WHERE id_user = ?
+	  AND code_hash = ?
+	  AND used_at IS NULL
+	`
+
+	res, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("[RowsAffected] | %v", err)
+	}
+
+	return affected > 0, nil
+}
@@ -0,0 +1,243 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/util"
+)
+
+type ChallengeRepository interface {
+	// CreateChallenge registers a new challenge along with its words. Returns the ID of the challenge
+	CreateChallenge(ctx context.Context, challenge entities.Challenge) (int64, error)
+
+	// GetChallengeByCode attempts to find the challenge with the provided code, along with its words; returns nil if
+	// not found
+	GetChallengeByCode(ctx context.Context, code string) (*entities.Challenge, error)
+
+	// GetChallengeResults returns how each player did on the provided challenge. Winners come first, ranked by their
+	// attempt count and then by who finished first, followed by everyone else from the first to start
+	GetChallengeResults(ctx context.Context, challengeID int64, limit uint32) ([]entities.ChallengeResult, error)
+}
+
+type challengeRepo struct {
+	db *sql.DB
+}
+
+func NewChallengeRepo(db *sql.DB) ChallengeRepository {
+	return challengeRepo{
+		db: db,
+	}
+}
+
+func (r challengeRepo) CreateChallenge(ctx context.Context, challenge entities.Challenge) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("[BeginTx] | %v", err)
+	}
+	defer util.DeferTxRollback(tx)
+
+	query := `
+	INSERT INTO challenge (
+		code,
+		id_user,
+		word_length,
+		word_count,
+		custom_words,
+		hard_mode,
+		created_at
+	) VALUES (?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())
+	`
+
+	res, err := tx.ExecContext(
+		ctx,
+		query,
+		challenge.Code,
+		challenge.CreatorID,
+		challenge.GetWordLength(),
+		challenge.GetWordCount(),
+		challenge.CustomWords,
+		challenge.HardMode,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	challengeID, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("[LastInsertId] | %v", err)
+	}
+
+	var (
+		placeholders []string
+		args         []any
+	)
+	for i, word := range challenge.Words {
+		placeholders = append(placeholders, "(?, ?, ?)")
+		args = append(args, challengeID, word, i)
+	}
+
+	query = `
+	INSERT INTO challenge_word (
+		id_challenge,
+		word,
+		idx
+	) VALUES
+	` + strings.Join(placeholders, ",\n")
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("[Commit] | %v", err)
+	}
+
+	return challengeID, nil
+}
+
+func (r challengeRepo) GetChallengeByCode(ctx context.Context, code string) (*entities.Challenge, error) {
+	query := `
+	SELECT c.id,
+	       c.code,
+	       c.id_user,
+	       u.name,
+	       c.custom_words,
+	       c.hard_mode,
+	       c.created_at
+	FROM challenge c
+	JOIN user u ON u.id = c.id_user
+	WHERE c.code = ?
+	`
+
+	var challenge entities.Challenge
+	err := r.db.QueryRowContext(ctx, query, code).Scan(
+		&challenge.ID,
+		&challenge.Code,
+		&challenge.CreatorID,
+		&challenge.CreatorName,
+		&challenge.CustomWords,
+		&challenge.HardMode,
+		&challenge.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("[QueryRowContext] | %v", err)
+	}
+
+	challenge.Words, err = r.getChallengeWords(ctx, challenge.ID)
+	if err != nil {
+		return nil, fmt.Errorf("[getChallengeWords] | %v", err)
+	}
+
+	return &challenge, nil
+}
+
+func (r challengeRepo) getChallengeWords(ctx context.Context, challengeID int64) ([]string, error) {
+	query := `
+	SELECT word
+	FROM challenge_word
+	WHERE id_challenge = ?
+	ORDER BY idx
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, challengeID)
+	if err != nil {
+		return nil, fmt.Errorf("[QueryContext] | %v", err)
+	}
+	defer util.DeferRowsClose(rows)
+
+	var words []string
+	for rows.Next() {
+		var word string
+		err := rows.Scan(&word)
+		if err != nil {
+			return nil, fmt.Errorf("[Scan] | %v", err)
+		}
+
+		words = append(words, word)
+	}
+
+	return words, nil
+}
+
+func (r challengeRepo) GetChallengeResults(
+	ctx context.Context,
+	challengeID int64,
+	limit uint32,
+) ([]entities.ChallengeResult, error) {
+	query := `
+	SELECT name,
+	       attempt_count,
+	       result,
+	       started_at,
+	       finished_at
+	FROM (SELECT g.id,
+	             u.name,
+	             (SELECT COUNT(*) FROM game_attempt a WHERE a.id_game = g.id) AS attempt_count,
+	             g.result,
+	             g.started_at,
+	             g.finished_at
+	      FROM game g
+	      JOIN user u ON u.id = g.id_user
+	      WHERE g.id_challenge = ?) results
+	ORDER BY result = ? DESC,
+	         CASE WHEN result = ? THEN attempt_count END,
+	         CASE WHEN result = ? THEN finished_at END,
+	         started_at,
+	         id
+	LIMIT ?
+	`
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		query,
+		challengeID,
+		entities.GameResultWon,
+		entities.GameResultWon,
+		entities.GameResultWon,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("[QueryContext] | %v", err)
+	}
+	defer util.DeferRowsClose(rows)
+
+	results := make([]entities.ChallengeResult, 0)
+	for rows.Next() {
+		var (
+			result     entities.ChallengeResult
+			rawResult  sql.NullString
+			finishedAt sql.NullTime
+		)
+		err := rows.Scan(
+			&result.UserName,
+			&result.AttemptCount,
+			&rawResult,
+			&result.StartedAt,
+			&finishedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("[Scan] | %v", err)
+		}
+
+		if rawResult.Valid {
+			gameResult := entities.GameResult(rawResult.String)
+			result.Result = &gameResult
+		}
+		if finishedAt.Valid {
+			result.FinishedAt = &finishedAt.Time
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -5,23 +5,69 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/go-sql-driver/mysql"
 	"strings"
 	"termo_back_end/internal/entities"
 	"termo_back_end/internal/util"
+	"time"
 )
 
+// ErrDailyAlreadyPlayed is returned when a user tries to start a daily game variant they already played that day
+var ErrDailyAlreadyPlayed = errors.New("gameRepo: daily game already played")
+
+// ErrChallengeAlreadyPlayed is returned when a user tries to start a game for a challenge they already played
+var ErrChallengeAlreadyPlayed = errors.New("gameRepo: challenge already played")
+
+// mysqlErrDuplicateEntry is the MySQL error number for unique key violations
+const mysqlErrDuplicateEntry = 1062
+
 type GameRepository interface {
 	// StartGame attempts to register a new game in the database for the provided user
-	StartGame(ctx context.Context, userID int64, words []string) error
+	//
+	// If the options describe a daily game, returns ErrDailyAlreadyPlayed if the user already has a game for that day
+	// with the same word count. If they describe a challenge game, returns ErrChallengeAlreadyPlayed if the user
+	// already has a game for that challenge
+	StartGame(ctx context.Context, userID int64, words []string, options entities.GameOptions) error
 
 	// RegisterAttempt attempts to register an attempt on the provided game
-	RegisterAttempt(ctx context.Context, gameID int64, attempt string, idx uint32, finish bool) error
-
-	// FinishGame marks a game as finished/inactive
-	FinishGame(ctx context.Context, gameID int64) error
+	//
+	// If result is not nil, the game is also finished with it. Returns false, without registering anything, if the game
+	// was already finished or another attempt was registered at the same index, such as by a concurrent request
+	RegisterAttempt(
+		ctx context.Context,
+		gameID int64,
+		attempt string,
+		idx uint32,
+		result *entities.GameResult,
+	) (bool, error)
+
+	// FinishGame marks a game as finished/inactive with the provided result. Returns whether this call finished it;
+	// false if the game was already finished
+	FinishGame(ctx context.Context, gameID int64, result entities.GameResult) (bool, error)
 
 	// GetUserActiveGame attempts to find the provided user's active game; returns nil if no active game
 	GetUserActiveGame(ctx context.Context, userID int64) (*entities.Game, error)
+
+	// GetUserGame attempts to find a game with the provided ID that belongs to the provided user; returns nil if not
+	// found
+	GetUserGame(ctx context.Context, userID int64, gameID int64) (*entities.Game, error)
+
+	// GetUserFinishedGames returns a page of the provided user's finished games, from newest to oldest
+	//
+	// Only games with an ID lower than beforeID are returned, unless it is 0
+	GetUserFinishedGames(
+		ctx context.Context,
+		userID int64,
+		beforeID int64,
+		limit uint32,
+	) ([]entities.GameSummary, error)
+
+	// GetUserGames returns every game of the provided user, along with their words and attempts, from oldest to newest
+	GetUserGames(ctx context.Context, userID int64) ([]entities.Game, error)
+
+	// SaveVersusMatch stores a finished versus match along with the finished game of each player, so that they show
+	// up in the players' game histories. Returns the ID of the match
+	SaveVersusMatch(ctx context.Context, match entities.VersusMatch) (int64, error)
 }
 
 type gameRepo struct {
@@ -34,20 +80,83 @@ func NewGameRepo(db *sql.DB) GameRepository {
 	}
 }
 
-func (r gameRepo) StartGame(ctx context.Context, userID int64, words []string) error {
+func (r gameRepo) StartGame(
+	ctx context.Context,
+	userID int64,
+	words []string,
+	options entities.GameOptions,
+) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("[BeginTx] | %v", err)
 	}
 	defer util.DeferTxRollback(tx)
 
+	game := entities.Game{Words: words}
+
+	var dailyDate *string
+	if options.DailyDate != nil {
+		date := options.DailyDate.Format(entities.DailyDateFormat)
+		dailyDate = &date
+
+		// Only one game per daily variant is allowed; the unique key also enforces this for concurrent requests
+		played, err := r.hasDailyGame(ctx, tx, userID, date, game.GetWordCount())
+		if err != nil {
+			return fmt.Errorf("[hasDailyGame] | %v", err)
+		}
+
+		if played {
+			return ErrDailyAlreadyPlayed
+		}
+	}
+
+	// Only one game per challenge is allowed; the unique key also enforces this for concurrent requests
+	if options.ChallengeID != nil {
+		played, err := r.hasChallengeGame(ctx, tx, userID, *options.ChallengeID)
+		if err != nil {
+			return fmt.Errorf("[hasChallengeGame] | %v", err)
+		}
+
+		if played {
+			return ErrChallengeAlreadyPlayed
+		}
+	}
+
 	// Insert game
 	query := `
-	INSERT INTO game (id_user) VALUES (?)
+	INSERT INTO game (
+		id_user,
+		word_length,
+		word_count,
+		daily_date,
+		allow_free_guesses,
+		unranked,
+		hard_mode,
+		id_challenge,
+		started_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())
 	`
 
-	res, err := tx.ExecContext(ctx, query, userID)
+	res, err := tx.ExecContext(
+		ctx,
+		query,
+		userID,
+		game.GetWordLength(),
+		game.GetWordCount(),
+		dailyDate,
+		options.AllowFreeGuesses,
+		options.Unranked,
+		options.HardMode,
+		options.ChallengeID,
+	)
 	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry {
+			if options.ChallengeID != nil {
+				return ErrChallengeAlreadyPlayed
+			}
+			return ErrDailyAlreadyPlayed
+		}
 		return fmt.Errorf("[ExecContext] | %v", err)
 	}
 
@@ -56,7 +165,21 @@ func (r gameRepo) StartGame(ctx context.Context, userID int64, words []string) e
 		return fmt.Errorf("[LastInsertId] | %v", err)
 	}
 
-	// Insert words
+	err = insertGameWords(ctx, tx, gameID, words)
+	if err != nil {
+		return fmt.Errorf("[insertGameWords] | %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("[Commit] | %v", err)
+	}
+
+	return nil
+}
+
+// insertGameWords inserts the words of a game, within the provided transaction
+func insertGameWords(ctx context.Context, tx *sql.Tx, gameID int64, words []string) error {
 	var (
 		placeholders []string
 		args         []any
@@ -66,7 +189,7 @@ func (r gameRepo) StartGame(ctx context.Context, userID int64, words []string) e
 		args = append(args, gameID, word, i)
 	}
 
-	queryWord := `
+	query := `
 	INSERT INTO game_word (
 		id_game,
 		word,
@@ -74,17 +197,58 @@ func (r gameRepo) StartGame(ctx context.Context, userID int64, words []string) e
 	) VALUES
 	` + strings.Join(placeholders, ",\n")
 
-	_, err = tx.ExecContext(ctx, queryWord, args...)
+	_, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("[ExecContext] | %v", err)
 	}
 
-	err = tx.Commit()
+	return nil
+}
+
+func (r gameRepo) hasDailyGame(
+	ctx context.Context,
+	tx *sql.Tx,
+	userID int64,
+	date string,
+	wordCount uint32,
+) (bool, error) {
+	query := `
+	SELECT COUNT(*)
+	FROM game
+	WHERE id_user = ?
+	  AND daily_date = ?
+	  AND word_count = ?
+	`
+
+	var count int64
+	err := tx.QueryRowContext(ctx, query, userID, date, wordCount).Scan(&count)
 	if err != nil {
-		return fmt.Errorf("[Commit] | %v", err)
+		return false, fmt.Errorf("[QueryRowContext] | %v", err)
 	}
 
-	return nil
+	return count > 0, nil
+}
+
+func (r gameRepo) hasChallengeGame(
+	ctx context.Context,
+	tx *sql.Tx,
+	userID int64,
+	challengeID int64,
+) (bool, error) {
+	query := `
+	SELECT COUNT(*)
+	FROM game
+	WHERE id_user = ?
+	  AND id_challenge = ?
+	`
+
+	var count int64
+	err := tx.QueryRowContext(ctx, query, userID, challengeID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("[QueryRowContext] | %v", err)
+	}
+
+	return count > 0, nil
 }
 
 func (r gameRepo) RegisterAttempt(
@@ -92,80 +256,475 @@ func (r gameRepo) RegisterAttempt(
 	gameID int64,
 	attempt string,
 	idx uint32,
-	finish bool,
-) error {
+	result *entities.GameResult,
+) (bool, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("[BeginTx] | %v", err)
+		return false, fmt.Errorf("[BeginTx] | %v", err)
 	}
 	defer util.DeferTxRollback(tx)
 
-	// Insert the attempt
+	// Insert the attempt, as long as the game is still active
 	queryAttempt := `
 	INSERT INTO game_attempt (
 		id_game,
 		attempt,
-		idx
-	) VALUES (?, ?, ?)
+		idx,
+		created_at
+	)
+	SELECT id, ?, ?, UTC_TIMESTAMP()
+	FROM game
+	WHERE id = ?
+	  AND result IS NULL
 	`
 
-	_, err = tx.ExecContext(ctx, queryAttempt, gameID, attempt, idx)
+	res, err := tx.ExecContext(ctx, queryAttempt, attempt, idx, gameID)
 	if err != nil {
-		return fmt.Errorf("[ExecContext] | %v", err)
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry {
+			return false, nil
+		}
+		return false, fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("[RowsAffected] | %v", err)
 	}
 
-	if finish {
+	if inserted == 0 {
+		return false, nil
+	}
+
+	if result != nil {
 		// Finish the game
 		queryFinish := `
 		UPDATE game
-		SET is_active = FALSE
+		SET result = ?,
+		    finished_at = UTC_TIMESTAMP()
 		WHERE id = ?
+		  AND result IS NULL
 		`
 
-		_, err = tx.ExecContext(ctx, queryFinish, gameID)
+		res, err = tx.ExecContext(ctx, queryFinish, *result, gameID)
 		if err != nil {
-			return fmt.Errorf("[ExecContext] | %v", err)
+			return false, fmt.Errorf("[ExecContext] | %v", err)
+		}
+
+		finished, err := res.RowsAffected()
+		if err != nil {
+			return false, fmt.Errorf("[RowsAffected] | %v", err)
+		}
+
+		if finished == 0 {
+			return false, nil
 		}
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("[Commit] | %v", err)
+		return false, fmt.Errorf("[Commit] | %v", err)
 	}
 
-	return nil
+	return true, nil
 }
 
-func (r gameRepo) FinishGame(ctx context.Context, gameID int64) error {
+func (r gameRepo) FinishGame(ctx context.Context, gameID int64, result entities.GameResult) (bool, error) {
 	query := `
 	UPDATE game
-	SET is_active = FALSE
+	SET result = ?,
+	    finished_at = UTC_TIMESTAMP()
 	WHERE id = ?
+	  AND result IS NULL
 	`
 
-	_, err := r.db.ExecContext(ctx, query, gameID)
+	res, err := r.db.ExecContext(ctx, query, result, gameID)
 	if err != nil {
-		return fmt.Errorf("[ExecContext] | %v", err)
+		return false, fmt.Errorf("[ExecContext] | %v", err)
 	}
 
-	return nil
+	finished, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("[RowsAffected] | %v", err)
+	}
+
+	return finished > 0, nil
 }
 
 func (r gameRepo) GetUserActiveGame(ctx context.Context, userID int64) (*entities.Game, error) {
+	game, err := r.getGame(ctx, "id_user = ? AND result IS NULL", userID)
+	if err != nil {
+		return nil, fmt.Errorf("[getGame] | %v", err)
+	}
+
+	return game, nil
+}
+
+func (r gameRepo) GetUserGame(ctx context.Context, userID int64, gameID int64) (*entities.Game, error) {
+	game, err := r.getGame(ctx, "id = ? AND id_user = ?", gameID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("[getGame] | %v", err)
+	}
+
+	return game, nil
+}
+
+func (r gameRepo) GetUserFinishedGames(
+	ctx context.Context,
+	userID int64,
+	beforeID int64,
+	limit uint32,
+) ([]entities.GameSummary, error) {
 	query := `
-	SELECT id
+	SELECT g.id,
+	       g.word_length,
+	       g.word_count,
+	       (SELECT COUNT(*) FROM game_attempt a WHERE a.id_game = g.id),
+	       g.result,
+	       g.daily_date,
+	       g.hard_mode,
+	       g.id_versus_match,
+	       g.id_challenge,
+	       g.started_at,
+	       g.finished_at
+	FROM game g
+	WHERE g.id_user = ?
+	  AND g.result IS NOT NULL
+	  AND (? = 0 OR g.id < ?)
+	ORDER BY g.id DESC
+	LIMIT ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, beforeID, beforeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("[QueryContext] | %v", err)
+	}
+	defer util.DeferRowsClose(rows)
+
+	games := make([]entities.GameSummary, 0)
+	for rows.Next() {
+		var (
+			game          entities.GameSummary
+			dailyDate     sql.NullTime
+			versusMatchID sql.NullInt64
+			challengeID   sql.NullInt64
+		)
+		err := rows.Scan(
+			&game.ID,
+			&game.WordLength,
+			&game.WordCount,
+			&game.AttemptCount,
+			&game.Result,
+			&dailyDate,
+			&game.HardMode,
+			&versusMatchID,
+			&challengeID,
+			&game.StartedAt,
+			&game.FinishedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("[Scan] | %v", err)
+		}
+
+		if dailyDate.Valid {
+			game.DailyDate = dailyDate.Time.Format(entities.DailyDateFormat)
+		}
+		if versusMatchID.Valid {
+			game.VersusMatchID = &versusMatchID.Int64
+		}
+		if challengeID.Valid {
+			game.ChallengeID = &challengeID.Int64
+		}
+
+		games = append(games, game)
+	}
+
+	return games, nil
+}
+
+func (r gameRepo) GetUserGames(ctx context.Context, userID int64) ([]entities.Game, error) {
+	query := `
+	SELECT ` + gameColumns + `
 	FROM game
 	WHERE id_user = ?
-	  AND is_active = TRUE
+	ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("[QueryContext] | %v", err)
+	}
+	defer util.DeferRowsClose(rows)
+
+	var games []entities.Game
+	gameIndexes := make(map[int64]int)
+	for rows.Next() {
+		game, err := scanGame(rows)
+		if err != nil {
+			return nil, fmt.Errorf("[scanGame] | %v", err)
+		}
+
+		gameIndexes[game.ID] = len(games)
+		games = append(games, *game)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("[Rows] | %v", err)
+	}
+
+	// The words and attempts of every game are loaded at once, instead of once per game
+	queryWords := `
+	SELECT id_game,
+	       word
+	FROM game_word
+	WHERE id_game IN (SELECT id FROM game WHERE id_user = ?)
+	ORDER BY id_game, idx
+	`
+
+	wordRows, err := r.db.QueryContext(ctx, queryWords, userID)
+	if err != nil {
+		return nil, fmt.Errorf("[QueryContext] | %v", err)
+	}
+	defer util.DeferRowsClose(wordRows)
+
+	for wordRows.Next() {
+		var (
+			gameID int64
+			word   string
+		)
+		err := wordRows.Scan(&gameID, &word)
+		if err != nil {
+			return nil, fmt.Errorf("[Scan] | %v", err)
+		}
+
+		// Games started after the first query are left out
+		if i, ok := gameIndexes[gameID]; ok {
+			games[i].Words = append(games[i].Words, word)
+		}
+	}
+	if err = wordRows.Err(); err != nil {
+		return nil, fmt.Errorf("[Rows] | %v", err)
+	}
+
+	queryAttempts := `
+	SELECT id_game,
+	       attempt,
+	       created_at
+	FROM game_attempt
+	WHERE id_game IN (SELECT id FROM game WHERE id_user = ?)
+	ORDER BY id_game, idx
+	`
+
+	attemptRows, err := r.db.QueryContext(ctx, queryAttempts, userID)
+	if err != nil {
+		return nil, fmt.Errorf("[QueryContext] | %v", err)
+	}
+	defer util.DeferRowsClose(attemptRows)
+
+	for attemptRows.Next() {
+		var (
+			gameID    int64
+			attempt   string
+			createdAt time.Time
+		)
+		err := attemptRows.Scan(&gameID, &attempt, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("[Scan] | %v", err)
+		}
+
+		if i, ok := gameIndexes[gameID]; ok {
+			games[i].Attempts = append(games[i].Attempts, attempt)
+			games[i].AttemptTimes = append(games[i].AttemptTimes, createdAt)
+		}
+	}
+	if err = attemptRows.Err(); err != nil {
+		return nil, fmt.Errorf("[Rows] | %v", err)
+	}
+
+	return games, nil
+}
+
+func (r gameRepo) SaveVersusMatch(ctx context.Context, match entities.VersusMatch) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("[BeginTx] | %v", err)
+	}
+	defer util.DeferTxRollback(tx)
+
+	// Insert match
+	queryMatch := `
+	INSERT INTO versus_match (
+		player_count,
+		started_at,
+		finished_at
+	) VALUES (?, ?, ?)
 	`
 
-	var game entities.Game
-	err := r.db.QueryRowContext(ctx, query, userID).Scan(&game.ID)
+	res, err := tx.ExecContext(ctx, queryMatch, len(match.Games), match.StartedAt.UTC(), match.FinishedAt.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	matchID, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("[LastInsertId] | %v", err)
+	}
+
+	// Insert the game of each player, already finished
+	queryGame := `
+	INSERT INTO game (
+		id_user,
+		word_length,
+		word_count,
+		allow_free_guesses,
+		hard_mode,
+		result,
+		started_at,
+		finished_at,
+		id_versus_match
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	for _, game := range match.Games {
+		res, err := tx.ExecContext(
+			ctx,
+			queryGame,
+			game.UserID,
+			game.GetWordLength(),
+			game.GetWordCount(),
+			game.AllowFreeGuesses,
+			game.HardMode,
+			game.Result,
+			match.StartedAt.UTC(),
+			match.FinishedAt.UTC(),
+			matchID,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("[ExecContext] | %v", err)
+		}
+
+		gameID, err := res.LastInsertId()
+		if err != nil {
+			return 0, fmt.Errorf("[LastInsertId] | %v", err)
+		}
+
+		err = insertGameWords(ctx, tx, gameID, game.Words)
+		if err != nil {
+			return 0, fmt.Errorf("[insertGameWords] | %v", err)
+		}
+
+		if len(game.Attempts) == 0 {
+			continue
+		}
+
+		var (
+			placeholders []string
+			args         []any
+		)
+		for i, attempt := range game.Attempts {
+			placeholders = append(placeholders, "(?, ?, ?, ?)")
+			args = append(args, gameID, attempt, i, game.AttemptTimes[i].UTC())
+		}
+
+		queryAttempts := `
+		INSERT INTO game_attempt (
+			id_game,
+			attempt,
+			idx,
+			created_at
+		) VALUES
+		` + strings.Join(placeholders, ",\n")
+
+		_, err = tx.ExecContext(ctx, queryAttempts, args...)
+		if err != nil {
+			return 0, fmt.Errorf("[ExecContext] | %v", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("[Commit] | %v", err)
+	}
+
+	return matchID, nil
+}
+
+// gameColumns are the columns read by scanGame, in order
+const gameColumns = `
+	       id,
+	       id_user,
+	       daily_date,
+	       allow_free_guesses,
+	       unranked,
+	       hard_mode,
+	       result,
+	       started_at,
+	       finished_at,
+	       id_versus_match,
+	       id_challenge
+`
+
+// scanGame reads a game, without its words and attempts, from a row with the gameColumns
+func scanGame(row interface{ Scan(dest ...any) error }) (*entities.Game, error) {
+	var (
+		game          entities.Game
+		dailyDate     sql.NullTime
+		result        sql.NullString
+		finishedAt    sql.NullTime
+		versusMatchID sql.NullInt64
+		challengeID   sql.NullInt64
+	)
+	err := row.Scan(
+		&game.ID,
+		&game.UserID,
+		&dailyDate,
+		&game.AllowFreeGuesses,
+		&game.Unranked,
+		&game.HardMode,
+		&result,
+		&game.StartedAt,
+		&finishedAt,
+		&versusMatchID,
+		&challengeID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	game.IsActive = !result.Valid
+	if dailyDate.Valid {
+		game.DailyDate = &dailyDate.Time
+	}
+	if result.Valid {
+		gameResult := entities.GameResult(result.String)
+		game.Result = &gameResult
+	}
+	if finishedAt.Valid {
+		game.FinishedAt = &finishedAt.Time
+	}
+	if versusMatchID.Valid {
+		game.VersusMatchID = &versusMatchID.Int64
+	}
+	if challengeID.Valid {
+		game.ChallengeID = &challengeID.Int64
+	}
+
+	return &game, nil
+}
+
+// getGame finds a single game matching the provided WHERE condition, along with its words and attempts; returns nil
+// if not found
+func (r gameRepo) getGame(ctx context.Context, condition string, args ...any) (*entities.Game, error) {
+	query := `
+	SELECT ` + gameColumns + `
+	FROM game
+	WHERE ` + condition
+
+	game, err := scanGame(r.db.QueryRowContext(ctx, query, args...))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("[ExecContext] | %v", err)
+		return nil, fmt.Errorf("[scanGame] | %v", err)
 	}
 
 	// Get game words
@@ -174,12 +733,12 @@ func (r gameRepo) GetUserActiveGame(ctx context.Context, userID int64) (*entitie
 		return nil, fmt.Errorf("[getGameWords] | %v", err)
 	}
 
-	game.Attempts, err = r.getGameAttempts(ctx, game.ID)
+	game.Attempts, game.AttemptTimes, err = r.getGameAttempts(ctx, game.ID)
 	if err != nil {
 		return nil, fmt.Errorf("[getGameAttempts] | %v", err)
 	}
 
-	return &game, nil
+	return game, nil
 }
 
 func (r gameRepo) getGameWords(ctx context.Context, gameID int64) ([]string, error) {
@@ -210,9 +769,10 @@ func (r gameRepo) getGameWords(ctx context.Context, gameID int64) ([]string, err
 	return words, nil
 }
 
-func (r gameRepo) getGameAttempts(ctx context.Context, gameID int64) ([]string, error) {
+func (r gameRepo) getGameAttempts(ctx context.Context, gameID int64) ([]string, []time.Time, error) {
 	query := `
-	SELECT attempt
+	SELECT attempt,
+	       created_at
 	FROM game_attempt
 	WHERE id_game = ?
 	ORDER BY idx
@@ -220,20 +780,27 @@ func (r gameRepo) getGameAttempts(ctx context.Context, gameID int64) ([]string,
 
 	rows, err := r.db.QueryContext(ctx, query, gameID)
 	if err != nil {
-		return nil, fmt.Errorf("[QueryContext] | %v", err)
+		return nil, nil, fmt.Errorf("[QueryContext] | %v", err)
 	}
 	defer util.DeferRowsClose(rows)
 
-	var attempts []string
+	var (
+		attempts []string
+		times    []time.Time
+	)
 	for rows.Next() {
-		var attempt string
-		err := rows.Scan(&attempt)
+		var (
+			attempt   string
+			createdAt time.Time
+		)
+		err := rows.Scan(&attempt, &createdAt)
 		if err != nil {
-			return nil, fmt.Errorf("[Scan] | %v", err)
+			return nil, nil, fmt.Errorf("[Scan] | %v", err)
 		}
 
 		attempts = append(attempts, attempt)
+		times = append(times, createdAt)
 	}
 
-	return attempts, nil
+	return attempts, times, nil
 }
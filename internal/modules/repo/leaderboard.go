@@ -0,0 +1,220 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/util"
+)
+
+// ErrInvalidLeaderboardKind is returned when an unknown leaderboard kind is requested
+var ErrInvalidLeaderboardKind = errors.New("leaderboardRepo: invalid leaderboard kind")
+
+type LeaderboardRepository interface {
+	// GetLeaderboard returns a page of the leaderboard of the provided kind, counting only games that match the filter.
+	// Games are counted from the users' stats, so practice games, which are left out of the stats, are never counted
+	//
+	// Also returns the entry of the provided user, or nil if they are not ranked, and how many users are ranked
+	GetLeaderboard(
+		ctx context.Context,
+		kind entities.LeaderboardKind,
+		filter entities.LeaderboardFilter,
+		userID int64,
+		limit uint32,
+		offset uint64,
+	) ([]entities.LeaderboardEntry, *entities.LeaderboardEntry, uint32, error)
+}
+
+type leaderboardRepo struct {
+	db *sql.DB
+}
+
+func NewLeaderboardRepo(db *sql.DB) LeaderboardRepository {
+	return leaderboardRepo{
+		db: db,
+	}
+}
+
+// leaderboardOrders maps each leaderboard kind to how users are ordered and which users are ranked
+var leaderboardOrders = map[entities.LeaderboardKind]struct {
+	orderBy   string
+	condition string
+}{
+	entities.LeaderboardKindScore: {
+		orderBy:   "games_won DESC",
+		condition: "games_won > 0",
+	},
+	entities.LeaderboardKindWinRate: {
+		orderBy:   "games_won / games_played DESC, games_played DESC",
+		condition: "games_played >= ?",
+	},
+	entities.LeaderboardKindAverageAttempts: {
+		orderBy:   "average_attempts ASC, games_won DESC",
+		condition: "games_won > 0 AND games_played >= ?",
+	},
+}
+
+func (r leaderboardRepo) GetLeaderboard(
+	ctx context.Context,
+	kind entities.LeaderboardKind,
+	filter entities.LeaderboardFilter,
+	userID int64,
+	limit uint32,
+	offset uint64,
+) ([]entities.LeaderboardEntry, *entities.LeaderboardEntry, uint32, error) {
+	order, ok := leaderboardOrders[kind]
+	if !ok {
+		return nil, nil, 0, ErrInvalidLeaderboardKind
+	}
+
+	// Count each user's games from their stats. Games of every time are in the stats of each mode, while the games of
+	// a time window are in the stats of each day
+	var (
+		counted string
+		args    []any
+	)
+	if filter.DailyDate == nil && filter.FinishedAfter.IsZero() {
+		counted = `
+		WITH won_attempts AS (
+			SELECT id_user,
+			       SUM(attempts * games) AS attempts
+			FROM user_stats_guess
+			WHERE word_length > 0
+			  AND (? = 0 OR word_length = ?)
+			  AND (? = 0 OR word_count = ?)
+			GROUP BY id_user
+		), counted AS (
+			SELECT s.id_user,
+			       SUM(s.games_played) AS games_played,
+			       SUM(s.games_won) AS games_won,
+			       COALESCE(MAX(w.attempts) / SUM(s.games_won), 0) AS average_attempts
+			FROM user_stats s
+			LEFT JOIN won_attempts w ON w.id_user = s.id_user
+			WHERE s.word_length > 0
+			  AND (? = 0 OR s.word_length = ?)
+			  AND (? = 0 OR s.word_count = ?)
+			GROUP BY s.id_user
+		)
+		`
+		args = []any{
+			filter.WordLength, filter.WordLength,
+			filter.WordCount, filter.WordCount,
+			filter.WordLength, filter.WordLength,
+			filter.WordCount, filter.WordCount,
+		}
+	} else {
+		// The daily window only counts the daily games of its day
+		since, daily := filter.FinishedAfter, false
+		if filter.DailyDate != nil {
+			since, daily = *filter.DailyDate, true
+		}
+
+		counted = `
+		WITH counted AS (
+			SELECT id_user,
+			       SUM(games_played) AS games_played,
+			       SUM(games_won) AS games_won,
+			       COALESCE(SUM(attempts_won) / SUM(games_won), 0) AS average_attempts
+			FROM user_stats_day
+			WHERE day >= ?
+			  AND (NOT ? OR daily)
+			  AND (? = 0 OR word_length = ?)
+			  AND (? = 0 OR word_count = ?)
+			GROUP BY id_user
+		)
+		`
+		args = []any{
+			since.Format(entities.DailyDateFormat),
+			daily,
+			filter.WordLength, filter.WordLength,
+			filter.WordCount, filter.WordCount,
+		}
+	}
+
+	// Rank the counted users. Guests are not ranked
+	ranking := counted + `
+	, ranked AS (
+		SELECT c.id_user,
+		       u.name,
+		       c.games_played,
+		       c.games_won,
+		       c.average_attempts,
+		       RANK() OVER (ORDER BY ` + order.orderBy + `) AS position,
+		       ROW_NUMBER() OVER (ORDER BY ` + order.orderBy + `, u.name) AS row_num
+		FROM counted c
+		JOIN user u ON u.id = c.id_user
+		WHERE NOT u.is_guest
+		  AND ` + order.condition + `
+	)
+	`
+	if kind != entities.LeaderboardKindScore {
+		args = append(args, filter.MinGames)
+	}
+
+	// Get the requested page along with the requesting user's entry and how many users are ranked at once. The count
+	// is joined with the entries, so that it's returned even if there are none
+	query := ranking + `
+	SELECT t.total,
+	       r.row_num,
+	       COALESCE(r.position, 0),
+	       COALESCE(r.id_user, 0),
+	       COALESCE(r.name, ''),
+	       COALESCE(r.games_played, 0),
+	       COALESCE(r.games_won, 0),
+	       COALESCE(r.average_attempts, 0)
+	FROM (SELECT COUNT(*) AS total FROM ranked) t
+	LEFT JOIN ranked r ON (r.row_num > ? AND r.row_num <= ?) OR r.id_user = ?
+	ORDER BY r.row_num
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, append(args, offset, offset+uint64(limit), userID)...)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("[QueryContext] | %v", err)
+	}
+	defer util.DeferRowsClose(rows)
+
+	var (
+		entries = make([]entities.LeaderboardEntry, 0)
+		own     *entities.LeaderboardEntry
+		total   uint32
+	)
+	for rows.Next() {
+		var (
+			entry  entities.LeaderboardEntry
+			rowNum sql.NullInt64
+		)
+		err := rows.Scan(
+			&total,
+			&rowNum,
+			&entry.Position,
+			&entry.UserID,
+			&entry.Name,
+			&entry.GamesPlayed,
+			&entry.GamesWon,
+			&entry.AverageAttempts,
+		)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("[Scan] | %v", err)
+		}
+
+		// No user is in the page nor is the requesting user ranked
+		if !rowNum.Valid {
+			continue
+		}
+
+		if entry.GamesPlayed > 0 {
+			entry.WinPercentage = float64(entry.GamesWon) / float64(entry.GamesPlayed) * 100
+		}
+
+		if uint64(rowNum.Int64) > offset && uint64(rowNum.Int64) <= offset+uint64(limit) {
+			entries = append(entries, entry)
+		}
+		if entry.UserID == userID {
+			own = &entry
+		}
+	}
+
+	return entries, own, total, nil
+}
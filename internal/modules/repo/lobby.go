@@ -0,0 +1,278 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/util"
+	"time"
+)
+
+// LobbyRepository keeps the lobbies in the database, so that every server instance shares them. It implements
+// util.LobbyStore
+type LobbyRepository interface {
+	// GetLobby returns the lobby with the provided code; returns nil if not found
+	GetLobby(ctx context.Context, code string) (*entities.Lobby, error)
+
+	// CreateLobby stores a new lobby at version 1; returns util.ErrLobbyExists if its code is taken
+	CreateLobby(ctx context.Context, lobby entities.Lobby) error
+
+	// UpdateLobby replaces a lobby with the provided one, incrementing its version; returns util.ErrLobbyConflict if
+	// the stored lobby is not at the provided one's version
+	UpdateLobby(ctx context.Context, lobby entities.Lobby) error
+
+	// DeleteLobby deletes a lobby; returns util.ErrLobbyConflict if the stored lobby is not at the provided version
+	DeleteLobby(ctx context.Context, code string, version int64) error
+
+	// GetChangedLobbies returns the lobbies that were changed since the provided time
+	GetChangedLobbies(ctx context.Context, since time.Time) ([]entities.Lobby, error)
+
+	// GetExpiredRounds returns the codes of the lobbies whose round is being played but ended before the provided time
+	GetExpiredRounds(ctx context.Context, before time.Time) ([]string, error)
+
+	// DeleteIdleLobbies deletes every lobby that wasn't changed since the provided time. Returns how many were deleted
+	DeleteIdleLobbies(ctx context.Context, before time.Time) (int64, error)
+}
+
+type lobbyRepo struct {
+	db *sql.DB
+}
+
+func NewLobbyRepo(db *sql.DB) LobbyRepository {
+	return lobbyRepo{
+		db: db,
+	}
+}
+
+func (r lobbyRepo) GetLobby(ctx context.Context, code string) (*entities.Lobby, error) {
+	query := `
+	SELECT data,
+	       version
+	FROM lobby
+	WHERE code = ?
+	`
+
+	var (
+		data    []byte
+		version int64
+	)
+	err := r.db.QueryRowContext(ctx, query, code).Scan(&data, &version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("[QueryRowContext] | %v", err)
+	}
+
+	lobby, err := decodeLobby(data, version)
+	if err != nil {
+		return nil, fmt.Errorf("[decodeLobby] | %v", err)
+	}
+
+	return lobby, nil
+}
+
+func (r lobbyRepo) CreateLobby(ctx context.Context, lobby entities.Lobby) error {
+	data, err := json.Marshal(lobby)
+	if err != nil {
+		return fmt.Errorf("[json.Marshal] | %v", err)
+	}
+
+	query := `
+	INSERT INTO lobby (
+		code,
+		data,
+		version,
+		round_ends_at,
+		updated_at
+	) VALUES (?, ?, 1, ?, ?)
+	`
+
+	_, err = r.db.ExecContext(ctx, query, lobby.Code, data, getRoundEndsAt(lobby), lobby.UpdatedAt.UTC())
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry {
+			return util.ErrLobbyExists
+		}
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	return nil
+}
+
+func (r lobbyRepo) UpdateLobby(ctx context.Context, lobby entities.Lobby) error {
+	data, err := json.Marshal(lobby)
+	if err != nil {
+		return fmt.Errorf("[json.Marshal] | %v", err)
+	}
+
+	query := `
+	UPDATE lobby
+	SET data          = ?,
+	    version       = version + 1,
+	    round_ends_at = ?,
+	    updated_at    = ?
+	WHERE code = ?
+	  AND version = ?
+	`
+
+	res, err := r.db.ExecContext(
+		ctx,
+		query,
+		data,
+		getRoundEndsAt(lobby),
+		lobby.UpdatedAt.UTC(),
+		lobby.Code,
+		lobby.Version,
+	)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("[RowsAffected] | %v", err)
+	}
+
+	if affected == 0 {
+		return util.ErrLobbyConflict
+	}
+
+	return nil
+}
+
+func (r lobbyRepo) DeleteLobby(ctx context.Context, code string, version int64) error {
+	query := `
+	DELETE FROM lobby
+	WHERE code = ?
+	  AND version = ?
+	`
+
+	res, err := r.db.ExecContext(ctx, query, code, version)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("[RowsAffected] | %v", err)
+	}
+
+	if affected == 0 {
+		return util.ErrLobbyConflict
+	}
+
+	return nil
+}
+
+func (r lobbyRepo) GetChangedLobbies(ctx context.Context, since time.Time) ([]entities.Lobby, error) {
+	query := `
+	SELECT data,
+	       version
+	FROM lobby
+	WHERE updated_at >= ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("[QueryContext] | %v", err)
+	}
+	defer util.DeferRowsClose(rows)
+
+	var lobbies []entities.Lobby
+	for rows.Next() {
+		var (
+			data    []byte
+			version int64
+		)
+		err := rows.Scan(&data, &version)
+		if err != nil {
+			return nil, fmt.Errorf("[Scan] | %v", err)
+		}
+
+		lobby, err := decodeLobby(data, version)
+		if err != nil {
+			return nil, fmt.Errorf("[decodeLobby] | %v", err)
+		}
+
+		lobbies = append(lobbies, *lobby)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("[Rows] | %v", err)
+	}
+
+	return lobbies, nil
+}
+
+func (r lobbyRepo) GetExpiredRounds(ctx context.Context, before time.Time) ([]string, error) {
+	query := `
+	SELECT code
+	FROM lobby
+	WHERE round_ends_at < ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, before.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("[QueryContext] | %v", err)
+	}
+	defer util.DeferRowsClose(rows)
+
+	var codes []string
+	for rows.Next() {
+		var code string
+		err := rows.Scan(&code)
+		if err != nil {
+			return nil, fmt.Errorf("[Scan] | %v", err)
+		}
+
+		codes = append(codes, code)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("[Rows] | %v", err)
+	}
+
+	return codes, nil
+}
+
+func (r lobbyRepo) DeleteIdleLobbies(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+	DELETE FROM lobby
+	WHERE updated_at < ?
+	`
+
+	res, err := r.db.ExecContext(ctx, query, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("[RowsAffected] | %v", err)
+	}
+
+	return deleted, nil
+}
+
+// decodeLobby reads a lobby stored as JSON, at the provided version
+func decodeLobby(data []byte, version int64) (*entities.Lobby, error) {
+	var lobby entities.Lobby
+	err := json.Unmarshal(data, &lobby)
+	if err != nil {
+		return nil, fmt.Errorf("[json.Unmarshal] | %v", err)
+	}
+	lobby.Version = version
+
+	return &lobby, nil
+}
+
+// getRoundEndsAt returns when the lobby's round ends, which is only stored while the round is being played
+func getRoundEndsAt(lobby entities.Lobby) sql.NullTime {
+	return sql.NullTime{
+		Time:  lobby.RoundEndsAt.UTC(),
+		Valid: lobby.State == entities.LobbyStatePlaying,
+	}
+}
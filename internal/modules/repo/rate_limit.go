@@ -0,0 +1,127 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/util"
+	"time"
+)
+
+// RateLimitRepository keeps the rate limiter token buckets in the database, so that every server instance shares them.
+// It implements util.RateLimitStore
+type RateLimitRepository interface {
+	// Take attempts to take a request from the bucket with the provided key, creating a full one if it doesn't exist
+	//
+	// Returns whether the request is allowed and, if not, how long until it would be
+	Take(ctx context.Context, key string, limit entities.RateLimit) (bool, time.Duration, error)
+
+	// DeleteFullBuckets deletes the buckets that are already full, since they are the same as missing ones
+	//
+	// Returns how many buckets were deleted
+	DeleteFullBuckets(ctx context.Context) (int64, error)
+}
+
+// rateLimitDeleteBatch is how many buckets are deleted at once, so that a cleanup doesn't lock too many rows
+const rateLimitDeleteBatch = 1000
+
+type rateLimitRepo struct {
+	db *sql.DB
+}
+
+func NewRateLimitRepo(db *sql.DB) RateLimitRepository {
+	return rateLimitRepo{
+		db: db,
+	}
+}
+
+func (r rateLimitRepo) Take(ctx context.Context, key string, limit entities.RateLimit) (bool, time.Duration, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, fmt.Errorf("[BeginTx] | %v", err)
+	}
+	defer util.DeferTxRollback(tx)
+
+	// Make sure the bucket exists, so that it can be locked
+	queryCreate := `
+	INSERT IGNORE INTO rate_limit_bucket (
+		bucket_key,
+		tokens,
+		updated_at,
+		full_at
+	) VALUES (?, ?, UTC_TIMESTAMP(6), UTC_TIMESTAMP(6))
+	`
+
+	_, err = tx.ExecContext(ctx, queryCreate, key, limit.Capacity())
+	if err != nil {
+		return false, 0, fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	// The database clock is used, so that instances with different clocks agree
+	query := `
+	SELECT tokens,
+	       TIMESTAMPDIFF(MICROSECOND, updated_at, UTC_TIMESTAMP(6))
+	FROM rate_limit_bucket
+	WHERE bucket_key = ?
+	FOR UPDATE
+	`
+
+	var (
+		tokens  float64
+		elapsed int64
+	)
+	err = tx.QueryRowContext(ctx, query, key).Scan(&tokens, &elapsed)
+	if err != nil {
+		return false, 0, fmt.Errorf("[QueryRowContext] | %v", err)
+	}
+
+	tokens, allowed, wait := util.TakeRateLimitToken(limit, tokens, time.Duration(elapsed)*time.Microsecond)
+
+	queryUpdate := `
+	UPDATE rate_limit_bucket
+	SET tokens = ?,
+	    updated_at = UTC_TIMESTAMP(6),
+	    full_at = UTC_TIMESTAMP(6) + INTERVAL ? MICROSECOND
+	WHERE bucket_key = ?
+	`
+
+	fullAfter := util.RateLimitFullAfter(limit, tokens).Microseconds()
+	_, err = tx.ExecContext(ctx, queryUpdate, tokens, fullAfter, key)
+	if err != nil {
+		return false, 0, fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, 0, fmt.Errorf("[Commit] | %v", err)
+	}
+
+	return allowed, wait, nil
+}
+
+func (r rateLimitRepo) DeleteFullBuckets(ctx context.Context) (int64, error) {
+	query := `
+	DELETE FROM rate_limit_bucket
+	WHERE full_at < UTC_TIMESTAMP(6)
+	LIMIT ?
+	`
+
+	var total int64
+	for {
+		result, err := r.db.ExecContext(ctx, query, rateLimitDeleteBatch)
+		if err != nil {
+			return total, fmt.Errorf("[ExecContext] | %v", err)
+		}
+
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return total, fmt.Errorf("[RowsAffected] | %v", err)
+		}
+
+		total += deleted
+		if deleted < rateLimitDeleteBatch {
+			return total, nil
+		}
+	}
+}
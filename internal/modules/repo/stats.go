@@ -0,0 +1,243 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/util"
+	"time"
+)
+
+type StatsRepository interface {
+	// RecordGame updates the provided user's stats with a finished game, both for the game's mode and overall, and
+	// the stats of the day it was finished on, or of its daily date for daily games
+	//
+	// attempts is the number of attempts made in the game; it's only used if the game was won
+	RecordGame(
+		ctx context.Context,
+		userID int64,
+		wordLength uint32,
+		wordCount uint32,
+		result entities.GameResult,
+		attempts uint32,
+		hardMode bool,
+		dailyDate *time.Time,
+	) error
+
+	// GetUserStats returns the provided user's stats for every game mode played, plus the overall stats
+	GetUserStats(ctx context.Context, userID int64) ([]entities.UserStats, error)
+}
+
+type statsRepo struct {
+	db *sql.DB
+}
+
+func NewStatsRepo(db *sql.DB) StatsRepository {
+	return statsRepo{
+		db: db,
+	}
+}
+
+func (r statsRepo) RecordGame(
+	ctx context.Context,
+	userID int64,
+	wordLength uint32,
+	wordCount uint32,
+	result entities.GameResult,
+	attempts uint32,
+	hardMode bool,
+	dailyDate *time.Time,
+) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("[BeginTx] | %v", err)
+	}
+	defer util.DeferTxRollback(tx)
+
+	won := result == entities.GameResultWon
+	abandoned := result == entities.GameResultAbandoned
+
+	// Update the game's mode and the overall stats
+	modes := [][2]uint32{{wordLength, wordCount}, {0, 0}}
+	for _, mode := range modes {
+		// The max streak is updated before the current streak, so it still reads the previous value
+		query := `
+		INSERT INTO user_stats (
+			id_user,
+			word_length,
+			word_count,
+			games_played,
+			games_won,
+			games_abandoned,
+			hard_mode_won,
+			current_streak,
+			max_streak
+		) VALUES (?, ?, ?, 1, IF(?, 1, 0), IF(?, 1, 0), IF(?, 1, 0), IF(?, 1, 0), IF(?, 1, 0))
+		ON DUPLICATE KEY UPDATE
+			games_played = games_played + 1,
+			games_won = games_won + IF(?, 1, 0),
+			games_abandoned = games_abandoned + IF(?, 1, 0),
+			hard_mode_won = hard_mode_won + IF(?, 1, 0),
+			max_streak = GREATEST(max_streak, IF(?, current_streak + 1, 0)),
+			current_streak = IF(?, current_streak + 1, 0)
+		`
+
+		wonHardMode := won && hardMode
+		_, err = tx.ExecContext(
+			ctx,
+			query,
+			userID, mode[0], mode[1], won, abandoned, wonHardMode, won, won,
+			won, abandoned, wonHardMode, won, won,
+		)
+		if err != nil {
+			return fmt.Errorf("[ExecContext] | %v", err)
+		}
+
+		if !won {
+			continue
+		}
+
+		queryGuess := `
+		INSERT INTO user_stats_guess (
+			id_user,
+			word_length,
+			word_count,
+			attempts,
+			games
+		) VALUES (?, ?, ?, ?, 1)
+		ON DUPLICATE KEY UPDATE
+			games = games + 1
+		`
+
+		_, err = tx.ExecContext(ctx, queryGuess, userID, mode[0], mode[1], attempts)
+		if err != nil {
+			return fmt.Errorf("[ExecContext] | %v", err)
+		}
+	}
+
+	var day *string
+	if dailyDate != nil {
+		date := dailyDate.Format(entities.DailyDateFormat)
+		day = &date
+	}
+
+	queryDay := `
+	INSERT INTO user_stats_day (
+		id_user,
+		day,
+		word_length,
+		word_count,
+		daily,
+		games_played,
+		games_won,
+		attempts_won
+	) VALUES (?, COALESCE(?, UTC_DATE()), ?, ?, ?, 1, IF(?, 1, 0), IF(?, ?, 0))
+	ON DUPLICATE KEY UPDATE
+		games_played = games_played + 1,
+		games_won = games_won + IF(?, 1, 0),
+		attempts_won = attempts_won + IF(?, ?, 0)
+	`
+
+	_, err = tx.ExecContext(
+		ctx,
+		queryDay,
+		userID, day, wordLength, wordCount, dailyDate != nil, won, won, attempts,
+		won, won, attempts,
+	)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("[Commit] | %v", err)
+	}
+
+	return nil
+}
+
+func (r statsRepo) GetUserStats(ctx context.Context, userID int64) ([]entities.UserStats, error) {
+	query := `
+	SELECT word_length,
+	       word_count,
+	       games_played,
+	       games_won,
+	       games_abandoned,
+	       hard_mode_won,
+	       current_streak,
+	       max_streak
+	FROM user_stats
+	WHERE id_user = ?
+	ORDER BY word_length, word_count
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("[QueryContext] | %v", err)
+	}
+	defer util.DeferRowsClose(rows)
+
+	var stats []entities.UserStats
+	for rows.Next() {
+		s := entities.UserStats{
+			GuessDistribution: make(map[uint32]uint32),
+		}
+		err := rows.Scan(
+			&s.WordLength,
+			&s.WordCount,
+			&s.GamesPlayed,
+			&s.GamesWon,
+			&s.GamesAbandoned,
+			&s.HardModeWon,
+			&s.CurrentStreak,
+			&s.MaxStreak,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("[Scan] | %v", err)
+		}
+
+		stats = append(stats, s)
+	}
+
+	err = r.fillGuessDistributions(ctx, userID, stats)
+	if err != nil {
+		return nil, fmt.Errorf("[fillGuessDistributions] | %v", err)
+	}
+
+	return stats, nil
+}
+
+func (r statsRepo) fillGuessDistributions(ctx context.Context, userID int64, stats []entities.UserStats) error {
+	query := `
+	SELECT word_length,
+	       word_count,
+	       attempts,
+	       games
+	FROM user_stats_guess
+	WHERE id_user = ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("[QueryContext] | %v", err)
+	}
+	defer util.DeferRowsClose(rows)
+
+	for rows.Next() {
+		var wordLength, wordCount, attempts, games uint32
+		err := rows.Scan(&wordLength, &wordCount, &attempts, &games)
+		if err != nil {
+			return fmt.Errorf("[Scan] | %v", err)
+		}
+
+		for i := range stats {
+			if stats[i].WordLength == wordLength && stats[i].WordCount == wordCount {
+				stats[i].GuessDistribution[attempts] = games
+				break
+			}
+		}
+	}
+
+	return nil
+}
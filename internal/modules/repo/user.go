@@ -5,16 +5,65 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/go-sql-driver/mysql"
 	"log"
+	"strings"
 	"termo_back_end/internal/entities"
+	"termo_back_end/internal/util"
+	"time"
 )
 
+// ErrNameTaken is returned when a user tries to take a name another user already has, ignoring upper/lowercase letters
+var ErrNameTaken = errors.New("userRepo: name taken")
+
+// ErrEmailTaken is returned when a user tries to take an email address another user already has
+var ErrEmailTaken = errors.New("userRepo: email taken")
+
+// isNameTakenError checks whether an error is a unique key violation of the name, or of the lowercase name
+func isNameTakenError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlErrDuplicateEntry {
+		return false
+	}
+
+	// The message ends with the violated key, such as "for key 'user.name_key'"
+	return strings.HasSuffix(mysqlErr.Message, "name'") || strings.HasSuffix(mysqlErr.Message, "name_key'")
+}
+
+// isEmailTakenError checks whether an error is a unique key violation of the email address
+func isEmailTakenError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlErrDuplicateEntry {
+		return false
+	}
+
+	return strings.HasSuffix(mysqlErr.Message, "email'")
+}
+
 type UserRepository interface {
 	// RegisterUser inserts a user into the database with given credentials; returns it if succeeded
 	//
-	// Password is expected to be already hashed; will be inserted as is
+	// Password is expected to be already hashed; will be inserted as is. Returns ErrNameTaken if another user has the
+	// name, ignoring upper/lowercase letters, and ErrEmailTaken if another user has the email address
 	RegisterUser(ctx context.Context, credentials entities.UserCredentials) (*entities.User, error)
 
+	// RegisterGuest inserts a guest user into the database with the provided name; returns it if succeeded
+	RegisterGuest(ctx context.Context, name string) (*entities.User, error)
+
+	// UpgradeGuest turns a guest user into a registered user with given credentials, given their ID. Returns false if
+	// the user is not a guest
+	//
+	// Password is expected to be already hashed; will be inserted as is. Returns ErrNameTaken and ErrEmailTaken as
+	// RegisterUser does
+	UpgradeGuest(ctx context.Context, userID int64, credentials entities.UserCredentials) (bool, error)
+
+	// DeleteStaleGuests deletes up to limit guest users, along with all their data, that were created, were last active
+	// and last started a game before the provided moment. Returns how many were deleted
+	DeleteStaleGuests(ctx context.Context, inactiveSince time.Time, limit uint32) (int64, error)
+
+	// DeleteUser deletes a user along with all their data, given their ID
+	DeleteUser(ctx context.Context, userID int64) error
+
 	// GetUserByID attempts to find a user with the provided ID; returns nil if not found
 	GetUserByID(ctx context.Context, id int64) (*entities.User, error)
 
@@ -23,16 +72,85 @@ type UserRepository interface {
 	// The name must be an exact match, meaning upper/lowercase letters won't match
 	GetUserByName(ctx context.Context, name string) (*entities.User, error)
 
-	// UpdateName updates a user's name, given their ID
+	// GetUserByEmail attempts to find a user with the provided email address; returns nil if not found
+	//
+	// Upper/lowercase letters are ignored
+	GetUserByEmail(ctx context.Context, email string) (*entities.User, error)
+
+	// IsNameTaken checks whether a user other than the one with the provided ID has the provided name, ignoring
+	// upper/lowercase letters. An exceptUserID of 0 checks every user
+	IsNameTaken(ctx context.Context, name string, exceptUserID int64) (bool, error)
+
+	// UpdateName updates a user's name, given their ID, and stores their previous name in their name history
+	//
+	// Returns ErrNameTaken if another user has the name, ignoring upper/lowercase letters
 	UpdateName(ctx context.Context, userID int64, name string) error
 
+	// GetNameHistory returns the previous names of a user, from the most to the least recent, given their ID
+	GetNameHistory(ctx context.Context, userID int64) ([]entities.NameChange, error)
+
+	// GetLastNameChange returns when a user last changed their name, given their ID; returns nil if they never did
+	GetLastNameChange(ctx context.Context, userID int64) (*time.Time, error)
+
 	// UpdatePassword updates a user's password, given their ID
 	//
 	// Password is expected to be already hashed; will be inserted as is
 	UpdatePassword(ctx context.Context, userID int64, password string) error
 
+	// UpdateEmail updates a user's email address, given their ID; an empty email removes it. Returns ErrEmailTaken if
+	// another user has the email address
+	UpdateEmail(ctx context.Context, userID int64, email string) error
+
 	// IncrementScore increments a user's score, given their ID
 	IncrementScore(ctx context.Context, userID int64) error
+
+	// UpdateLastActive sets when a user was last active to now, given their ID
+	UpdateLastActive(ctx context.Context, userID int64) error
+
+	// RevokeTokens makes every auth token issued to a user until now invalid, given their ID
+	RevokeTokens(ctx context.Context, userID int64) error
+
+	// RegisterLoginFailure counts a failed login of a user, given their ID. Once maxFailures failures in a row are
+	// reached, the user is locked for lockoutMinutes and the count restarts; a maxFailures of 0 never locks the user
+	RegisterLoginFailure(ctx context.Context, userID int64, maxFailures uint32, lockoutMinutes uint32) error
+
+	// ResetLoginFailures clears the failed login count and lockout of a user, given their ID
+	ResetLoginFailures(ctx context.Context, userID int64) error
+
+	// SetTOTPSecret starts a user's two-factor authentication enrollment with the provided secret, given their ID.
+	// Two-factor authentication stays disabled until EnableTOTP is called
+	SetTOTPSecret(ctx context.Context, userID int64, secret string) error
+
+	// EnableTOTP enables two-factor authentication for a user, given their ID, replacing their recovery codes with the
+	// provided ones in the same transaction
+	//
+	// Codes are expected to be already hashed; will be inserted as is
+	EnableTOTP(ctx context.Context, userID int64, recoveryCodeHashes []string) error
+
+	// DisableTOTP disables two-factor authentication for a user and removes their secret and recovery codes, given
+	// their ID
+	DisableTOTP(ctx context.Context, userID int64) error
+
+	// UseTOTPStep registers that a user used the code of a TOTP time step, given their ID. Returns false if a code of
+	// the same or a later step was already used, so that codes can't be replayed
+	UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error)
+
+	// SearchUsers returns a page of the users whose name or email address contains the provided query, ordered by ID,
+	// and how many users match it. An empty query matches every user
+	SearchUsers(ctx context.Context, query string, limit uint32, offset uint32) ([]entities.User, uint32, error)
+
+	// BanUser bans a user until the provided moment, given their ID; a nil until bans them permanently
+	BanUser(ctx context.Context, userID int64, until *time.Time, reason string) error
+
+	// UnbanUser lifts a user's ban, given their ID
+	UnbanUser(ctx context.Context, userID int64) error
+
+	// ResetScore sets a user's score back to 0 and deletes their stats, which the leaderboards are ranked by, given
+	// their ID. Their game history is kept
+	ResetScore(ctx context.Context, userID int64) error
+
+	// SetRole updates a user's role, given their ID
+	SetRole(ctx context.Context, userID int64, role entities.Role) error
 }
 
 type userRepo struct {
@@ -52,12 +170,20 @@ func (r userRepo) RegisterUser(
 	query := `
 	INSERT INTO user (
 		name,
-		password
-	) VALUES (?, ?)
+		password,
+		email,
+		created_at
+	) VALUES (?, ?, NULLIF(?, ''), UTC_TIMESTAMP())
 	`
 
-	res, err := r.db.ExecContext(ctx, query, credentials.Name, credentials.Password)
+	res, err := r.db.ExecContext(ctx, query, credentials.Name, credentials.Password, credentials.Email)
 	if err != nil {
+		if isNameTakenError(err) {
+			return nil, ErrNameTaken
+		}
+		if isEmailTakenError(err) {
+			return nil, ErrEmailTaken
+		}
 		return nil, fmt.Errorf("[ExecContext] | %v", err)
 	}
 
@@ -70,77 +196,463 @@ func (r userRepo) RegisterUser(
 	return r.GetUserByID(ctx, id)
 }
 
-func (r userRepo) GetUserByID(ctx context.Context, id int64) (*entities.User, error) {
+func (r userRepo) RegisterGuest(ctx context.Context, name string) (*entities.User, error) {
 	query := `
-	SELECT id,
-	       name,
-	       password,
-	       score
-	FROM user
+	INSERT INTO user (
+		name,
+		password,
+		is_guest,
+		created_at
+	) VALUES (?, '', TRUE, UTC_TIMESTAMP())
+	`
+
+	res, err := r.db.ExecContext(ctx, query, name)
+	if err != nil {
+		return nil, fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("[LastInsertId] | %v", err)
+	}
+
+	return r.GetUserByID(ctx, id)
+}
+
+func (r userRepo) UpgradeGuest(
+	ctx context.Context,
+	userID int64,
+	credentials entities.UserCredentials,
+) (bool, error) {
+	query := `
+	UPDATE user
+	SET name = ?,
+	    password = ?,
+	    email = NULLIF(?, ''),
+	    is_guest = FALSE
 	WHERE id = ?
+	  AND is_guest
 	`
 
-	var user entities.User
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&user.ID,
-		&user.Name,
-		&user.Password,
-		&user.Score,
-	)
+	res, err := r.db.ExecContext(ctx, query, credentials.Name, credentials.Password, credentials.Email, userID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
+		if isNameTakenError(err) {
+			return false, ErrNameTaken
 		}
-		log.Printf("[QueryRowContext] | %v", err)
-		return nil, fmt.Errorf("[QueryRowContext] | %v", err)
+		if isEmailTakenError(err) {
+			return false, ErrEmailTaken
+		}
+		return false, fmt.Errorf("[ExecContext] | %v", err)
 	}
 
-	return &user, nil
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("[RowsAffected] | %v", err)
+	}
+
+	return affected > 0, nil
 }
 
-func (r userRepo) GetUserByName(ctx context.Context, name string) (*entities.User, error) {
+func (r userRepo) DeleteStaleGuests(ctx context.Context, inactiveSince time.Time, limit uint32) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("[BeginTx] | %v", err)
+	}
+	defer util.DeferTxRollback(tx)
+
 	query := `
-	SELECT id,
+	SELECT u.id
+	FROM user u
+	WHERE u.is_guest
+	  AND COALESCE(u.last_active_at, u.created_at) < ?
+	  AND NOT EXISTS (
+	      SELECT 1
+	      FROM game g
+	      WHERE g.id_user = u.id
+	        AND g.started_at >= ?
+	  )
+	LIMIT ?
+	FOR UPDATE
+	`
+
+	rows, err := tx.QueryContext(ctx, query, inactiveSince.UTC(), inactiveSince.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("[QueryContext] | %v", err)
+	}
+	defer util.DeferRowsClose(rows)
+
+	var userIDs []any
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return 0, fmt.Errorf("[Scan] | %v", err)
+		}
+		userIDs = append(userIDs, id)
+	}
+
+	if len(userIDs) == 0 {
+		return 0, nil
+	}
+
+	err = deleteUsers(ctx, tx, userIDs)
+	if err != nil {
+		return 0, fmt.Errorf("[deleteUsers] | %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("[Commit] | %v", err)
+	}
+
+	return int64(len(userIDs)), nil
+}
+
+func (r userRepo) DeleteUser(ctx context.Context, userID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("[BeginTx] | %v", err)
+	}
+	defer util.DeferTxRollback(tx)
+
+	err = deleteUsers(ctx, tx, []any{userID})
+	if err != nil {
+		return fmt.Errorf("[deleteUsers] | %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("[Commit] | %v", err)
+	}
+
+	return nil
+}
+
+// userDataDeletes deletes every row referencing the users with the IDs in the (%s) placeholder, in an order that
+// respects foreign keys. The user rows themselves are deleted last, while games of other users played through their
+// challenges are only detached from them
+var userDataDeletes = []string{
+	`UPDATE game g JOIN challenge c ON c.id = g.id_challenge SET g.id_challenge = NULL WHERE c.id_user IN (%s)`,
+	`DELETE w FROM challenge_word w JOIN challenge c ON c.id = w.id_challenge WHERE c.id_user IN (%s)`,
+	`DELETE FROM challenge WHERE id_user IN (%s)`,
+	`DELETE a FROM game_attempt a JOIN game g ON g.id = a.id_game WHERE g.id_user IN (%s)`,
+	`DELETE w FROM game_word w JOIN game g ON g.id = w.id_game WHERE g.id_user IN (%s)`,
+	`DELETE FROM game WHERE id_user IN (%s)`,
+	`DELETE FROM user_stats_guess WHERE id_user IN (%s)`,
+	`DELETE FROM user_stats_day WHERE id_user IN (%s)`,
+	`DELETE FROM user_stats WHERE id_user IN (%s)`,
+	`DELETE FROM refresh_token WHERE id_user IN (%s)`,
+	`DELETE FROM password_reset_token WHERE id_user IN (%s)`,
+	`DELETE FROM recovery_code WHERE id_user IN (%s)`,
+	`DELETE FROM audit_event WHERE id_user IN (%s)`,
+	`DELETE FROM user_name_history WHERE id_user IN (%s)`,
+	`DELETE FROM user WHERE id IN (%s)`,
+}
+
+// deleteUsers deletes the users with the provided IDs and all their data, within the provided transaction
+func deleteUsers(ctx context.Context, tx *sql.Tx, userIDs []any) error {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(userIDs)), ", ")
+
+	for _, query := range userDataDeletes {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(query, placeholders), userIDs...)
+		if err != nil {
+			return fmt.Errorf("[ExecContext] | %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (r userRepo) GetUserByID(ctx context.Context, id int64) (*entities.User, error) {
+	return r.getUser(ctx, "id = ?", id)
+}
+
+func (r userRepo) GetUserByName(ctx context.Context, name string) (*entities.User, error) {
+	return r.getUser(ctx, "name = ?", name)
+}
+
+func (r userRepo) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
+	return r.getUser(ctx, "email = ?", email)
+}
+
+// userColumns are the columns read by scanUser, in order
+const userColumns = `
+	       id,
 	       name,
 	       password,
-	       score
+	       email,
+	       score,
+	       is_guest,
+	       created_at,
+	       last_active_at,
+	       tokens_valid_after,
+	       locked_until,
+	       totp_secret,
+	       totp_enabled,
+	       role,
+	       banned_at,
+	       banned_until,
+	       ban_reason
+`
+
+// getUser returns the first user matching the provided condition; returns nil if not found
+func (r userRepo) getUser(ctx context.Context, condition string, args ...any) (*entities.User, error) {
+	query := `
+	SELECT ` + userColumns + `
 	FROM user
-	WHERE name = ?
+	WHERE ` + condition + `
+	LIMIT 1
 	`
 
-	var user entities.User
-	err := r.db.QueryRowContext(ctx, query, name).Scan(
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		log.Printf("[scanUser] | %v", err)
+		return nil, fmt.Errorf("[scanUser] | %v", err)
+	}
+
+	return user, nil
+}
+
+// scanUser reads a user from a row with the userColumns
+func scanUser(row interface{ Scan(dest ...any) error }) (*entities.User, error) {
+	var (
+		user             entities.User
+		email            sql.NullString
+		createdAt        sql.NullTime
+		lastActiveAt     sql.NullTime
+		tokensValidAfter sql.NullTime
+		lockedUntil      sql.NullTime
+		totpSecret       sql.NullString
+		bannedAt         sql.NullTime
+		bannedUntil      sql.NullTime
+		banReason        sql.NullString
+	)
+	err := row.Scan(
 		&user.ID,
 		&user.Name,
 		&user.Password,
+		&email,
 		&user.Score,
+		&user.IsGuest,
+		&createdAt,
+		&lastActiveAt,
+		&tokensValidAfter,
+		&lockedUntil,
+		&totpSecret,
+		&user.TOTPEnabled,
+		&user.Role,
+		&bannedAt,
+		&bannedUntil,
+		&banReason,
 	)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
-		}
-		log.Printf("[QueryRowContext] | %v", err)
-		return nil, fmt.Errorf("[QueryRowContext] | %v", err)
+		return nil, err
+	}
+
+	if email.Valid {
+		user.Email = &email.String
+	}
+	if createdAt.Valid {
+		user.CreatedAt = &createdAt.Time
+	}
+	if lastActiveAt.Valid {
+		user.LastActiveAt = &lastActiveAt.Time
+	}
+	if tokensValidAfter.Valid {
+		user.TokensValidAfter = &tokensValidAfter.Time
+	}
+	if lockedUntil.Valid {
+		user.LockedUntil = &lockedUntil.Time
+	}
+	if totpSecret.Valid {
+		user.TOTPSecret = &totpSecret.String
+	}
+	if bannedAt.Valid {
+		user.BannedAt = &bannedAt.Time
+	}
+	if bannedUntil.Valid {
+		user.BannedUntil = &bannedUntil.Time
+	}
+	if banReason.Valid {
+		user.BanReason = &banReason.String
 	}
 
 	return &user, nil
 }
 
+func (r userRepo) SearchUsers(
+	ctx context.Context,
+	query string,
+	limit uint32,
+	offset uint32,
+) ([]entities.User, uint32, error) {
+	// Escape LIKE wildcards, so that the query is matched literally
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+
+	condition := `
+	WHERE name LIKE ?
+	   OR email LIKE ?
+	`
+
+	queryPage := `
+	SELECT ` + userColumns + `
+	FROM user
+	` + condition + `
+	ORDER BY id
+	LIMIT ? OFFSET ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, queryPage, pattern, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("[QueryContext] | %v", err)
+	}
+	defer util.DeferRowsClose(rows)
+
+	users := make([]entities.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("[scanUser] | %v", err)
+		}
+		users = append(users, *user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("[Rows] | %v", err)
+	}
+
+	queryTotal := `
+	SELECT COUNT(*)
+	FROM user
+	` + condition
+
+	var total uint32
+	err = r.db.QueryRowContext(ctx, queryTotal, pattern, pattern).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("[QueryRowContext] | %v", err)
+	}
+
+	return users, total, nil
+}
+
+// likeEscaper escapes the characters with a special meaning in LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (r userRepo) IsNameTaken(ctx context.Context, name string, exceptUserID int64) (bool, error) {
+	query := `
+	SELECT EXISTS (
+	    SELECT 1
+	    FROM user
+	    WHERE name_key = LOWER(?)
+	      AND id <> ?
+	)
+	`
+
+	var taken bool
+	err := r.db.QueryRowContext(ctx, query, name, exceptUserID).Scan(&taken)
+	if err != nil {
+		return false, fmt.Errorf("[QueryRowContext] | %v", err)
+	}
+
+	return taken, nil
+}
+
 func (r userRepo) UpdateName(ctx context.Context, userID int64, name string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("[BeginTx] | %v", err)
+	}
+	defer util.DeferTxRollback(tx)
+
+	queryHistory := `
+	INSERT INTO user_name_history (
+		id_user,
+		name,
+		changed_at
+	)
+	SELECT id, name, UTC_TIMESTAMP()
+	FROM user
+	WHERE id = ?
+	`
+
+	_, err = tx.ExecContext(ctx, queryHistory, userID)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
 	query := `
 	UPDATE user
 	SET name = ?
 	WHERE id = ?
 	`
 
-	_, err := r.db.ExecContext(ctx, query, name, userID)
+	_, err = tx.ExecContext(ctx, query, name, userID)
 	if err != nil {
+		if isNameTakenError(err) {
+			return ErrNameTaken
+		}
 		return fmt.Errorf("[ExecContext] | %v", err)
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("[Commit] | %v", err)
+	}
+
 	return nil
 }
 
+func (r userRepo) GetNameHistory(ctx context.Context, userID int64) ([]entities.NameChange, error) {
+	query := `
+	SELECT name,
+	       changed_at
+	FROM user_name_history
+	WHERE id_user = ?
+	ORDER BY changed_at DESC, id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("[QueryContext] | %v", err)
+	}
+	defer util.DeferRowsClose(rows)
+
+	history := make([]entities.NameChange, 0)
+	for rows.Next() {
+		var change entities.NameChange
+		err = rows.Scan(&change.Name, &change.ChangedAt)
+		if err != nil {
+			return nil, fmt.Errorf("[Scan] | %v", err)
+		}
+		history = append(history, change)
+	}
+
+	return history, nil
+}
+
+func (r userRepo) GetLastNameChange(ctx context.Context, userID int64) (*time.Time, error) {
+	query := `
+	SELECT MAX(changed_at)
+	FROM user_name_history
+	WHERE id_user = ?
+	`
+
+	var changedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&changedAt)
+	if err != nil {
+		return nil, fmt.Errorf("[QueryRowContext] | %v", err)
+	}
+
+	if !changedAt.Valid {
+		return nil, nil
+	}
+
+	return &changedAt.Time, nil
+}
+
 func (r userRepo) UpdatePassword(ctx context.Context, userID int64, password string) error {
 	query := `
 	UPDATE user
@@ -156,6 +668,24 @@ func (r userRepo) UpdatePassword(ctx context.Context, userID int64, password str
 	return nil
 }
 
+func (r userRepo) UpdateEmail(ctx context.Context, userID int64, email string) error {
+	query := `
+	UPDATE user
+	SET email = NULLIF(?, '')
+	WHERE id = ?
+	`
+
+	_, err := r.db.ExecContext(ctx, query, email, userID)
+	if err != nil {
+		if isEmailTakenError(err) {
+			return ErrEmailTaken
+		}
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	return nil
+}
+
 func (r userRepo) IncrementScore(ctx context.Context, userID int64) error {
 	query := `
 	UPDATE user
@@ -170,3 +700,293 @@ func (r userRepo) IncrementScore(ctx context.Context, userID int64) error {
 
 	return nil
 }
+
+func (r userRepo) UpdateLastActive(ctx context.Context, userID int64) error {
+	query := `
+	UPDATE user
+	SET last_active_at = UTC_TIMESTAMP()
+	WHERE id = ?
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	return nil
+}
+
+func (r userRepo) RevokeTokens(ctx context.Context, userID int64) error {
+	query := `
+	UPDATE user
+	SET tokens_valid_after = UTC_TIMESTAMP()
+	WHERE id = ?
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	return nil
+}
+
+func (r userRepo) RegisterLoginFailure(
+	ctx context.Context,
+	userID int64,
+	maxFailures uint32,
+	lockoutMinutes uint32,
+) error {
+	// locked_until is updated before failed_logins, so it still reads the previous count
+	query := `
+	UPDATE user
+	SET locked_until = IF(
+	        ? > 0 AND failed_logins + 1 >= ?,
+	        UTC_TIMESTAMP() + INTERVAL ? MINUTE,
+	        locked_until
+	    ),
+	    failed_logins = IF(? > 0 AND failed_logins + 1 >= ?, 0, failed_logins + 1)
+	WHERE id = ?
+	`
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		maxFailures, maxFailures, lockoutMinutes,
+		maxFailures, maxFailures,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	return nil
+}
+
+func (r userRepo) ResetLoginFailures(ctx context.Context, userID int64) error {
+	query := `
+	UPDATE user
+	SET failed_logins = 0,
+	    locked_until = NULL
+	WHERE id = ?
+	  AND (failed_logins > 0 OR locked_until IS NOT NULL)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	return nil
+}
+
+func (r userRepo) SetTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	query := `
+	UPDATE user
+	SET totp_secret = ?,
+	    totp_enabled = FALSE,
+	    totp_last_step = NULL
+	WHERE id = ?
+	`
+
+	_, err := r.db.ExecContext(ctx, query, secret, userID)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	return nil
+}
+
+func (r userRepo) EnableTOTP(ctx context.Context, userID int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("[BeginTx] | %v", err)
+	}
+	defer util.DeferTxRollback(tx)
+
+	queryDelete := `
+	DELETE FROM recovery_code
+	WHERE id_user = ?
+	`
+
+	_, err = tx.ExecContext(ctx, queryDelete, userID)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	queryInsert := `
+	INSERT INTO recovery_code (
+		id_user,
+		code_hash
+	) VALUES (?, ?)
+	`
+
+	for _, codeHash := range recoveryCodeHashes {
+		_, err = tx.ExecContext(ctx, queryInsert, userID, codeHash)
+		if err != nil {
+			return fmt.Errorf("[ExecContext] | %v", err)
+		}
+	}
+
+	query := `
+	UPDATE user
+	SET totp_enabled = TRUE
+	WHERE id = ?
+	`
+
+	_, err = tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("[Commit] | %v", err)
+	}
+
+	return nil
+}
+
+func (r userRepo) DisableTOTP(ctx context.Context, userID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("[BeginTx] | %v", err)
+	}
+	defer util.DeferTxRollback(tx)
+
+	query := `
+	UPDATE user
+	SET totp_secret = NULL,
+	    totp_enabled = FALSE,
+	    totp_last_step = NULL
+	WHERE id = ?
+	`
+
+	_, err = tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	queryDelete := `
+	DELETE FROM recovery_code
+	WHERE id_user = ?
+	`
+
+	_, err = tx.ExecContext(ctx, queryDelete, userID)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("[Commit] | %v", err)
+	}
+
+	return nil
+}
+
+func (r userRepo) UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	query := `
+	UPDATE user
+	SET totp_last_step = ?
+	WHERE id = ?
+	  AND (totp_last_step IS NULL OR totp_last_step < ?)
+	`
+
+	res, err := r.db.ExecContext(ctx, query, step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("[RowsAffected] | %v", err)
+	}
+
+	return affected > 0, nil
+}
+
+func (r userRepo) BanUser(ctx context.Context, userID int64, until *time.Time, reason string) error {
+	query := `
+	UPDATE user
+	SET banned_at = UTC_TIMESTAMP(),
+	    banned_until = ?,
+	    ban_reason = NULLIF(?, '')
+	WHERE id = ?
+	`
+
+	var bannedUntil *time.Time
+	if until != nil {
+		utc := until.UTC()
+		bannedUntil = &utc
+	}
+
+	_, err := r.db.ExecContext(ctx, query, bannedUntil, reason, userID)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	return nil
+}
+
+func (r userRepo) UnbanUser(ctx context.Context, userID int64) error {
+	query := `
+	UPDATE user
+	SET banned_at = NULL,
+	    banned_until = NULL,
+	    ban_reason = NULL
+	WHERE id = ?
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	return nil
+}
+
+// scoreResets clear a user's score and stats, given their ID
+var scoreResets = []string{
+	`UPDATE user SET score = 0 WHERE id = ?`,
+	`DELETE FROM user_stats_guess WHERE id_user = ?`,
+	`DELETE FROM user_stats_day WHERE id_user = ?`,
+	`DELETE FROM user_stats WHERE id_user = ?`,
+}
+
+func (r userRepo) ResetScore(ctx context.Context, userID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("[BeginTx] | %v", err)
+	}
+	defer util.DeferTxRollback(tx)
+
+	for _, query := range scoreResets {
+		_, err = tx.ExecContext(ctx, query, userID)
+		if err != nil {
+			return fmt.Errorf("[ExecContext] | %v", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("[Commit] | %v", err)
+	}
+
+	return nil
+}
+
+func (r userRepo) SetRole(ctx context.Context, userID int64, role entities.Role) error {
+	query := `
+	UPDATE user
+	SET role = ?
+	WHERE id = ?
+	`
+
+	_, err := r.db.ExecContext(ctx, query, role, userID)
+	if err != nil {
+		return fmt.Errorf("[ExecContext] | %v", err)
+	}
+
+	return nil
+}
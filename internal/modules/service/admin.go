@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/modules/repo"
+	"termo_back_end/internal/status_codes"
+	"time"
+)
+
+// Admin user list page sizes
+const (
+	adminUsersDefaultLimit = 20
+	adminUsersMaxLimit     = 100
+)
+
+type AdminService interface {
+	// ListUsers returns a page of the users whose name or email address contains the provided query
+	ListUsers(ctx context.Context, query string, page uint32, limit uint32) (*entities.AdminUserList, error)
+
+	// BanUser bans the user with the provided ID for the provided duration, or permanently if 0, and logs them out of
+	// every session. Admins can't be banned
+	//
+	// Every action is recorded in the audit log as done by the provided admin
+	BanUser(
+		ctx context.Context,
+		admin *entities.User,
+		userID int64,
+		duration time.Duration,
+		reason string,
+	) (status_codes.AdminBan, error)
+
+	// UnbanUser lifts the ban of the user with the provided ID
+	UnbanUser(ctx context.Context, admin *entities.User, userID int64) (status_codes.AdminUnban, error)
+
+	// ResetScore sets the score of the user with the provided ID back to 0 and clears their stats, so that they drop
+	// off every leaderboard until they play again
+	ResetScore(ctx context.Context, admin *entities.User, userID int64) (status_codes.AdminResetScore, error)
+
+	// FinishGame finishes the active game of the user with the provided ID as abandoned
+	FinishGame(ctx context.Context, admin *entities.User, userID int64) (status_codes.AdminFinishGame, error)
+
+	// SetRole updates the role of the user with the provided ID. Admins can't change their own role, so that there's
+	// always an admin left
+	SetRole(
+		ctx context.Context,
+		admin *entities.User,
+		userID int64,
+		role entities.Role,
+	) (status_codes.AdminSetRole, error)
+
+	// GetNameHistory returns the previous names of the user with the provided ID, from the most to the least recent
+	GetNameHistory(ctx context.Context, userID int64) (status_codes.AdminNameHistory, []entities.NameChange, error)
+
+	// GetServerStats returns an overview of the server's usage
+	GetServerStats(ctx context.Context) (*entities.ServerStats, error)
+}
+
+type adminService struct {
+	startedAt   time.Time
+	repo        repo.AdminRepository
+	userRepo    repo.UserRepository
+	authRepo    repo.AuthRepository
+	gameService GameService
+	audit       AuditService
+}
+
+func NewAdminService(
+	repo repo.AdminRepository,
+	userRepo repo.UserRepository,
+	authRepo repo.AuthRepository,
+	gameService GameService,
+	audit AuditService,
+) AdminService {
+	return adminService{
+		startedAt:   time.Now(),
+		repo:        repo,
+		userRepo:    userRepo,
+		authRepo:    authRepo,
+		gameService: gameService,
+		audit:       audit,
+	}
+}
+
+func (s adminService) ListUsers(
+	ctx context.Context,
+	query string,
+	page uint32,
+	limit uint32,
+) (*entities.AdminUserList, error) {
+	if limit == 0 {
+		limit = adminUsersDefaultLimit
+	}
+	limit = min(limit, adminUsersMaxLimit)
+
+	var offset uint32
+	if page > 0 {
+		offset = (page - 1) * limit
+	}
+
+	users, total, err := s.userRepo.SearchUsers(ctx, strings.TrimSpace(query), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("[SearchUsers] | %v", err)
+	}
+
+	list := entities.AdminUserList{
+		Users: make([]entities.AdminUserResponse, len(users)),
+		Total: total,
+	}
+	for i, user := range users {
+		list.Users[i] = user.ToAdminResponse()
+	}
+
+	return &list, nil
+}
+
+func (s adminService) BanUser(
+	ctx context.Context,
+	admin *entities.User,
+	userID int64,
+	duration time.Duration,
+	reason string,
+) (status_codes.AdminBan, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return -1, fmt.Errorf("[GetUserByID] | %v", err)
+	}
+
+	if user == nil {
+		return status_codes.AdminBanNotFound, nil
+	}
+
+	if user.HasRole(entities.RoleAdmin) {
+		return status_codes.AdminBanIsAdmin, nil
+	}
+
+	var until *time.Time
+	if duration > 0 {
+		bannedUntil := time.Now().Add(duration)
+		until = &bannedUntil
+	}
+
+	reason = strings.TrimSpace(reason)
+	err = s.userRepo.BanUser(ctx, user.ID, until, reason)
+	if err != nil {
+		return -1, fmt.Errorf("[BanUser] | %v", err)
+	}
+
+	// Log the user out of every session, so that they can't refresh their tokens once the ban ends
+	err = s.userRepo.RevokeTokens(ctx, user.ID)
+	if err != nil {
+		return -1, fmt.Errorf("[RevokeTokens] | %v", err)
+	}
+
+	err = s.authRepo.RevokeUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return -1, fmt.Errorf("[RevokeUserRefreshTokens] | %v", err)
+	}
+
+	details := map[string]string{"reason": reason}
+	if until != nil {
+		details["until"] = until.UTC().Format(time.RFC3339)
+	}
+	s.recordAction(ctx, admin, user, entities.AuditEventAdminBan, details)
+
+	return status_codes.AdminBanSuccess, nil
+}
+
+func (s adminService) UnbanUser(
+	ctx context.Context,
+	admin *entities.User,
+	userID int64,
+) (status_codes.AdminUnban, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return -1, fmt.Errorf("[GetUserByID] | %v", err)
+	}
+
+	if user == nil {
+		return status_codes.AdminUnbanNotFound, nil
+	}
+
+	if !user.IsBanned(time.Now()) {
+		return status_codes.AdminUnbanNotBanned, nil
+	}
+
+	err = s.userRepo.UnbanUser(ctx, user.ID)
+	if err != nil {
+		return -1, fmt.Errorf("[UnbanUser] | %v", err)
+	}
+
+	s.recordAction(ctx, admin, user, entities.AuditEventAdminUnban, nil)
+
+	return status_codes.AdminUnbanSuccess, nil
+}
+
+func (s adminService) ResetScore(
+	ctx context.Context,
+	admin *entities.User,
+	userID int64,
+) (status_codes.AdminResetScore, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return -1, fmt.Errorf("[GetUserByID] | %v", err)
+	}
+
+	if user == nil {
+		return status_codes.AdminResetScoreNotFound, nil
+	}
+
+	err = s.userRepo.ResetScore(ctx, user.ID)
+	if err != nil {
+		return -1, fmt.Errorf("[ResetScore] | %v", err)
+	}
+
+	s.recordAction(ctx, admin, user, entities.AuditEventAdminResetScore, map[string]string{
+		"old_score": strconv.FormatUint(uint64(user.Score), 10),
+	})
+
+	return status_codes.AdminResetScoreSuccess, nil
+}
+
+func (s adminService) FinishGame(
+	ctx context.Context,
+	admin *entities.User,
+	userID int64,
+) (status_codes.AdminFinishGame, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return -1, fmt.Errorf("[GetUserByID] | %v", err)
+	}
+
+	if user == nil {
+		return status_codes.AdminFinishGameNotFound, nil
+	}
+
+	// Finishing a game for the user is the same as them forfeiting it
+	status, _, err := s.gameService.ForfeitGame(ctx, user)
+	if err != nil {
+		return -1, fmt.Errorf("[ForfeitGame] | %v", err)
+	}
+
+	if status == status_codes.GameForfeitNoActiveGame {
+		return status_codes.AdminFinishGameNoActiveGame, nil
+	}
+
+	s.recordAction(ctx, admin, user, entities.AuditEventAdminFinishGame, nil)
+
+	return status_codes.AdminFinishGameSuccess, nil
+}
+
+func (s adminService) SetRole(
+	ctx context.Context,
+	admin *entities.User,
+	userID int64,
+	role entities.Role,
+) (status_codes.AdminSetRole, error) {
+	if role != entities.RoleUser && role != entities.RoleAdmin {
+		return status_codes.AdminSetRoleInvalidRole, nil
+	}
+
+	if userID == admin.ID {
+		return status_codes.AdminSetRoleOwnRole, nil
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return -1, fmt.Errorf("[GetUserByID] | %v", err)
+	}
+
+	if user == nil {
+		return status_codes.AdminSetRoleNotFound, nil
+	}
+
+	err = s.userRepo.SetRole(ctx, user.ID, role)
+	if err != nil {
+		return -1, fmt.Errorf("[SetRole] | %v", err)
+	}
+
+	s.recordAction(ctx, admin, user, entities.AuditEventAdminSetRole, map[string]string{
+		"old_role": string(user.Role),
+		"new_role": string(role),
+	})
+
+	return status_codes.AdminSetRoleSuccess, nil
+}
+
+func (s adminService) GetNameHistory(
+	ctx context.Context,
+	userID int64,
+) (status_codes.AdminNameHistory, []entities.NameChange, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[GetUserByID] | %v", err)
+	}
+
+	if user == nil {
+		return status_codes.AdminNameHistoryNotFound, nil, nil
+	}
+
+	history, err := s.userRepo.GetNameHistory(ctx, user.ID)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[GetNameHistory] | %v", err)
+	}
+
+	return status_codes.AdminNameHistorySuccess, history, nil
+}
+
+func (s adminService) GetServerStats(ctx context.Context) (*entities.ServerStats, error) {
+	stats, err := s.repo.GetServerStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[GetServerStats] | %v", err)
+	}
+
+	stats.StartedAt = s.startedAt.UTC()
+	stats.UptimeSeconds = int64(time.Since(s.startedAt).Seconds())
+
+	return stats, nil
+}
+
+// recordAction records in the audit log an action of an admin on a user
+func (s adminService) recordAction(
+	ctx context.Context,
+	admin *entities.User,
+	user *entities.User,
+	eventType entities.AuditEventType,
+	details map[string]string,
+) {
+	s.audit.Record(ctx, entities.AuditEvent{
+		UserID:  &user.ID,
+		ActorID: &admin.ID,
+		Type:    eventType,
+		Details: details,
+	})
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/modules/repo"
+	"termo_back_end/internal/status_codes"
+	"termo_back_end/internal/util"
+)
+
+// Audit log page sizes
+const (
+	auditEventsDefaultLimit = 20
+	auditEventsMaxLimit     = 100
+)
+
+// Maximum lengths of the client data stored with each event, as defined in the audit_event table
+const (
+	auditMaxIPLength        = 45
+	auditMaxUserAgentLength = 255
+)
+
+type AuditService interface {
+	// Record appends an event to the audit log, filling the client data from the context if not set. Failures are only
+	// logged, so that the action being audited isn't interrupted
+	Record(ctx context.Context, event entities.AuditEvent)
+
+	// RecordAnonymous appends an event of the provided type to the audit log without any user or client data, for
+	// events that must not leave personal data behind, such as account deletions
+	RecordAnonymous(ctx context.Context, eventType entities.AuditEventType)
+
+	// GetEvents returns a page of the events matching the filter, from the newest to the oldest, and the cursor of
+	// the next page, which is empty if there are no more events
+	GetEvents(
+		ctx context.Context,
+		filter entities.AuditFilter,
+		cursor string,
+		limit uint32,
+	) (status_codes.AuditEventsGet, []entities.AuditEvent, string, error)
+
+	// GetUserEvents returns a page of the events about the provided user, like GetEvents. The admins who acted on the
+	// user are not disclosed
+	GetUserEvents(
+		ctx context.Context,
+		user *entities.User,
+		cursor string,
+		limit uint32,
+	) (status_codes.AuditEventsGet, []entities.AuditEvent, string, error)
+}
+
+type auditService struct {
+	repo repo.AuditRepository
+}
+
+func NewAuditService(repo repo.AuditRepository) AuditService {
+	return auditService{
+		repo: repo,
+	}
+}
+
+func (s auditService) Record(ctx context.Context, event entities.AuditEvent) {
+	if event.IP == "" && event.UserAgent == "" {
+		client := util.GetContextClientInfo(ctx)
+		event.IP = client.IP
+		event.UserAgent = client.UserAgent
+	}
+	event.IP = util.TruncateRunes(event.IP, auditMaxIPLength)
+	event.UserAgent = util.TruncateRunes(event.UserAgent, auditMaxUserAgentLength)
+
+	// The event is recorded even if the request was canceled, since the action may already be done
+	err := s.repo.InsertEvent(context.WithoutCancel(ctx), event)
+	if err != nil {
+		log.Printf("[InsertEvent] | %s event: %v", event.Type, err)
+	}
+}
+
+func (s auditService) RecordAnonymous(ctx context.Context, eventType entities.AuditEventType) {
+	err := s.repo.InsertEvent(context.WithoutCancel(ctx), entities.AuditEvent{Type: eventType})
+	if err != nil {
+		log.Printf("[InsertEvent] | %s event: %v", eventType, err)
+	}
+}
+
+func (s auditService) GetEvents(
+	ctx context.Context,
+	filter entities.AuditFilter,
+	cursor string,
+	limit uint32,
+) (status_codes.AuditEventsGet, []entities.AuditEvent, string, error) {
+	var beforeID int64
+	if cursor != "" {
+		var ok bool
+		beforeID, ok = decodeIDCursor(cursor)
+		if !ok {
+			return status_codes.AuditEventsGetInvalidCursor, nil, "", nil
+		}
+	}
+
+	if limit == 0 {
+		limit = auditEventsDefaultLimit
+	}
+	limit = min(limit, auditEventsMaxLimit)
+
+	// Get one more event than requested to know whether there is a next page
+	events, err := s.repo.GetEvents(ctx, filter, beforeID, limit+1)
+	if err != nil {
+		return -1, nil, "", fmt.Errorf("[GetEvents] | %v", err)
+	}
+
+	var nextCursor string
+	if uint32(len(events)) > limit {
+		events = events[:limit]
+		nextCursor = encodeIDCursor(events[len(events)-1].ID)
+	}
+
+	return status_codes.AuditEventsGetSuccess, events, nextCursor, nil
+}
+
+func (s auditService) GetUserEvents(
+	ctx context.Context,
+	user *entities.User,
+	cursor string,
+	limit uint32,
+) (status_codes.AuditEventsGet, []entities.AuditEvent, string, error) {
+	status, events, nextCursor, err := s.GetEvents(ctx, entities.AuditFilter{UserID: &user.ID}, cursor, limit)
+	if err != nil {
+		return -1, nil, "", fmt.Errorf("[GetEvents] | %v", err)
+	}
+
+	for i := range events {
+		events[i].ActorID = nil
+	}
+
+	return status, events, nextCursor, nil
+}
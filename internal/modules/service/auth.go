@@ -1,160 +1,1334 @@
 package service
 
 import (
-	"aidanwoods.dev/go-paseto"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"strings"
 	"termo_back_end/internal/entities"
 	"termo_back_end/internal/modules/repo"
 	"termo_back_end/internal/rules"
 	"termo_back_end/internal/status_codes"
 	"termo_back_end/internal/util"
+	"time"
+)
+
+type RegisterData struct {
+	Status status_codes.UserRegister
+	Tokens *entities.AuthTokens
+
+	// RetryAfter is how long the client must wait before registering again; only set if the status is
+	// TOO_MANY_ATTEMPTS
+	RetryAfter time.Duration
+
+	// PasswordProblems are the rules of the password policy the password breaks; only set if the status is
+	// INVALID_PASSWORD
+	PasswordProblems []rules.PasswordProblem
+}
+
+type LoginData struct {
+	Status status_codes.UserLogin
+	Tokens *entities.AuthTokens
+
+	// ChallengeToken is exchanged for the auth tokens along with a second factor code; only set if the status is
+	// SECOND_FACTOR_REQUIRED
+	ChallengeToken string
+
+	// RetryAfter is how long the client must wait before logging in again; only set if the status is TOO_MANY_ATTEMPTS
+	RetryAfter time.Duration
+
+	// BannedUntil is when the user's ban ends, or nil if it's permanent, and BanReason is why they were banned; only
+	// set if the status is BANNED
+	BannedUntil *time.Time
+	BanReason   *string
+}
+
+type LoginSecondFactorData struct {
+	Status status_codes.LoginSecondFactor
+	Tokens *entities.AuthTokens
+
+	// RetryAfter is how long the client must wait before trying again; only set if the status is TOO_MANY_ATTEMPTS
+	RetryAfter time.Duration
+}
+
+type TwoFactorEnrollment struct {
+	// Secret is the TOTP secret, for users who can't scan the URI
+	Secret string
+
+	// URI is the otpauth URI added to authenticator apps, usually shown as a QR code
+	URI string
+}
+
+// Guest settings
+const (
+	guestNameLength             = 10
+	guestNameAlphabet           = "abcdefghijklmnopqrstuvwxyz0123456789"
+	defaultGuestMaxInactiveDays = 30
+
+	// guestPurgeBatch is how many guests are deleted at once, so that a purge doesn't lock too many rows
+	guestPurgeBatch = 500
+)
+
+// lastActiveUpdateInterval is how often the moment a user was last active is updated, so that it isn't written on every
+// request
+const lastActiveUpdateInterval = 1 * time.Hour
+
+// totpIssuer is the name shown for the account in authenticator apps
+const totpIssuer = "Termo"
+
+// recoveryCodeCount is how many recovery codes a user gets when enabling two-factor authentication
+const recoveryCodeCount = 10
+
+// Login protection defaults, used when not set in the config
+const (
+	defaultLoginBackoffSeconds    = 1
+	defaultLoginMaxBackoffSeconds = 15 * 60
+	defaultLoginLockoutMinutes    = 15
 )
 
 type AuthService interface {
 	// RegisterUser attempts to register a user with the provided credentials
 	//
-	// Returns the auth token string if succeeded
+	// Returns the auth tokens if succeeded. The number of registrations from the same client IP is limited
+	//
+	// If another user already has the email address, the user is registered without it and its owner is told by email,
+	// so that addresses can't be enumerated
 	RegisterUser(
 		ctx context.Context,
 		credentials entities.UserCredentials,
-	) (status_codes.UserRegister, string, error)
+		client entities.ClientInfo,
+	) (*RegisterData, error)
+
+	// RegisterGuest creates a guest user with a generated name, who can play without registering
+	//
+	// Returns the auth tokens if succeeded. Guests count towards the registration limit of the client IP
+	RegisterGuest(ctx context.Context, client entities.ClientInfo) (*RegisterData, error)
 
-	// LoginUser checks if the login credentials are valid and returns an auth token
+	// UpgradeGuest turns the provided guest user into a registered user with the provided credentials, keeping their
+	// games and stats
+	//
+	// Also returns the rules of the password policy the password breaks if the status is INVALID_PASSWORD. An email
+	// address another user already has is handled as in RegisterUser
+	UpgradeGuest(
+		ctx context.Context,
+		user *entities.User,
+		credentials entities.UserCredentials,
+	) (status_codes.UserUpgrade, []rules.PasswordProblem, error)
+
+	// PurgeStaleGuests deletes the guest users that haven't been active for too long. Returns how many were deleted
+	PurgeStaleGuests(ctx context.Context) (int64, error)
+
+	// LoginUser checks if the login credentials are valid and returns the auth tokens
+	//
+	// Failed logins make both the client IP and the account wait exponentially longer between attempts, and lock the
+	// account after too many failures in a row
+	//
+	// If the user has two-factor authentication enabled, returns a challenge token instead, to be sent along with a
+	// second factor code to LoginSecondFactor. Banned users can't log in
 	LoginUser(
 		ctx context.Context,
 		credentials entities.UserCredentials,
-	) (status_codes.UserLogin, string, error)
+		client entities.ClientInfo,
+	) (*LoginData, error)
+
+	// LoginSecondFactor exchanges a challenge token and a second factor code, either a TOTP code or a recovery code,
+	// for the auth tokens. Failures count as failed logins, and a challenge token can only be exchanged once
+	LoginSecondFactor(
+		ctx context.Context,
+		challengeToken string,
+		code string,
+		client entities.ClientInfo,
+	) (*LoginSecondFactorData, error)
+
+	// EnrollTwoFactor generates a new TOTP secret for the user. Two-factor authentication is only enabled once a code
+	// is sent to ConfirmTwoFactor
+	EnrollTwoFactor(
+		ctx context.Context,
+		user *entities.User,
+	) (status_codes.TwoFactorEnroll, *TwoFactorEnrollment, error)
+
+	// ConfirmTwoFactor enables two-factor authentication if the code matches the secret from EnrollTwoFactor
+	//
+	// Returns the recovery codes, which are only shown once
+	ConfirmTwoFactor(
+		ctx context.Context,
+		user *entities.User,
+		code string,
+	) (status_codes.TwoFactorConfirm, []string, error)
+
+	// DisableTwoFactor disables two-factor authentication, given the user's password and a second factor code
+	DisableTwoFactor(
+		ctx context.Context,
+		user *entities.User,
+		password string,
+		code string,
+	) (status_codes.TwoFactorDisable, error)
+
+	// RefreshTokens exchanges a refresh token for new auth tokens. The used refresh token is revoked
+	//
+	// If an already used refresh token is presented, it may have been stolen, so every token created from the same
+	// login is revoked
+	RefreshTokens(ctx context.Context, refreshToken string) (status_codes.TokenRefresh, *entities.AuthTokens, error)
+
+	// Logout revokes the provided auth token and, if not empty, the provided refresh token
+	Logout(ctx context.Context, token *entities.AuthToken, refreshToken string) error
+
+	// LogoutAll revokes every auth and refresh token issued to the provided user
+	LogoutAll(ctx context.Context, user *entities.User) error
+
+	// RequestPasswordReset emails a password reset token to the user with the provided email address, if any
+	//
+	// Succeeds even if no user has the email address, so that addresses can't be enumerated
+	RequestPasswordReset(ctx context.Context, email string) (status_codes.PasswordResetRequest, error)
+
+	// ConfirmPasswordReset sets a new password for the user a password reset token was issued to. The token can only
+	// be used once, and every session of the user is ended
+	//
+	// Also returns the rules of the password policy the new password breaks if the status is INVALID_PASSWORD
+	ConfirmPasswordReset(
+		ctx context.Context,
+		token string,
+		newPassword string,
+	) (status_codes.PasswordResetConfirm, []rules.PasswordProblem, error)
+
+	// GetUserFromToken attempts to get a user from a token string; returns nil if the token was revoked
+	//
+	// Also returns the token claims. Updates when the user was last active
+	GetUserFromToken(ctx context.Context, token string) (*entities.User, *entities.AuthToken, error)
+
+	// CreateStreamTicket issues a ticket for the provided user to open a websocket or event stream with, which can only
+	// be used once and within a few seconds
+	CreateStreamTicket(ctx context.Context, user *entities.User) (string, error)
 
-	// GetUserFromToken attempts to get a user from a token string
-	GetUserFromToken(ctx context.Context, token string) (*entities.User, error)
+	// GetUserFromStreamTicket attempts to get a user from a stream ticket, using it up; returns nil if the ticket was
+	// already used or the user logged out of every session since it was issued
+	//
+	// Also returns the ticket claims
+	GetUserFromStreamTicket(ctx context.Context, ticket string) (*entities.User, *entities.AuthToken, error)
 }
 
 type authService struct {
-	publicKey  paseto.V4AsymmetricPublicKey
-	privateKey paseto.V4AsymmetricSecretKey
-	userRepo   repo.UserRepository
+	keyring  *util.AuthKeyring
+	userRepo repo.UserRepository
+	repo     repo.AuthRepository
+
+	// ipBackoff and accountBackoff track failed logins by client IP and by lowercase account name
+	ipBackoff      *util.Backoff
+	accountBackoff *util.Backoff
+
+	// registerLimit tracks registrations by client IP; nil if registrations aren't limited
+	registerLimit *util.Backoff
+
+	lockoutFailures uint32
+	lockoutMinutes  uint32
+	collapseErrors  bool
+
+	// dummyHash is compared against when logging in with an unknown name, so that it takes as long as a wrong password
+	dummyHash string
+
+	namePolicy rules.NamePolicy
+
+	hasher           util.PasswordHasher
+	passwordPolicy   rules.PasswordPolicy
+	mailer           util.Mailer
+	passwordResetURL string
+
+	guestMaxInactive time.Duration
+
+	audit AuditService
 }
 
-func NewAuthService(config entities.Config, userRepo repo.UserRepository) AuthService {
-	publicKey, err := paseto.NewV4AsymmetricPublicKeyFromHex(config.Auth.PublicKey)
+func NewAuthService(
+	config entities.Config,
+	userRepo repo.UserRepository,
+	repo repo.AuthRepository,
+	hasher util.PasswordHasher,
+	passwordPolicy rules.PasswordPolicy,
+	mailer util.Mailer,
+	audit AuditService,
+) AuthService {
+	keyring, err := util.NewAuthKeyring(config)
 	if err != nil {
-		log.Printf("[NewV4AsymmetricPublicKeyFromHex] | %v", err)
+		log.Printf("[NewAuthKeyring] | %v", err)
 		panic(err)
 	}
 
-	privateKey, err := paseto.NewV4AsymmetricSecretKeyFromHex(config.Auth.PrivateKey)
+	dummyHash, err := hasher.Hash("dummy password")
 	if err != nil {
-		log.Printf("[NewV4AsymmetricSecretKeyFromHex] | %v", err)
+		log.Printf("[Hash] | %v", err)
 		panic(err)
 	}
 
+	guestMaxInactiveDays := config.Guest.MaxInactiveDays
+	if guestMaxInactiveDays == 0 {
+		guestMaxInactiveDays = defaultGuestMaxInactiveDays
+	}
+
+	protection := config.Auth.LoginProtection
+	if protection.BackoffSeconds == 0 {
+		protection.BackoffSeconds = defaultLoginBackoffSeconds
+	}
+	if protection.MaxBackoffSeconds == 0 {
+		protection.MaxBackoffSeconds = defaultLoginMaxBackoffSeconds
+	}
+	if protection.LockoutMinutes == 0 {
+		protection.LockoutMinutes = defaultLoginLockoutMinutes
+	}
+
+	backoff := time.Duration(protection.BackoffSeconds) * time.Second
+	maxBackoff := time.Duration(protection.MaxBackoffSeconds) * time.Second
+
+	// Registrations are limited by making every one past the limit wait a whole hour since the last one
+	var registerLimit *util.Backoff
+	if protection.RegistrationsPerHour > 0 {
+		registerLimit = util.NewBackoff(protection.RegistrationsPerHour-1, time.Hour, time.Hour)
+	}
+
 	return authService{
-		publicKey:  publicKey,
-		privateKey: privateKey,
-		userRepo:   userRepo,
+		keyring:          keyring,
+		userRepo:         userRepo,
+		repo:             repo,
+		ipBackoff:        util.NewBackoff(protection.FreeFailures, backoff, maxBackoff),
+		accountBackoff:   util.NewBackoff(protection.FreeFailures, backoff, maxBackoff),
+		registerLimit:    registerLimit,
+		lockoutFailures:  protection.LockoutFailures,
+		lockoutMinutes:   protection.LockoutMinutes,
+		collapseErrors:   protection.CollapseErrors,
+		dummyHash:        dummyHash,
+		namePolicy:       rules.NewNamePolicy(config.Names.Reserved, config.Names.BlockedWords),
+		hasher:           hasher,
+		passwordPolicy:   passwordPolicy,
+		mailer:           mailer,
+		passwordResetURL: config.Mail.PasswordResetURL,
+		guestMaxInactive: time.Duration(guestMaxInactiveDays) * 24 * time.Hour,
+		audit:            audit,
 	}
 }
 
 func (s authService) RegisterUser(
 	ctx context.Context,
 	credentials entities.UserCredentials,
-) (status_codes.UserRegister, string, error) {
+	client entities.ClientInfo,
+) (*RegisterData, error) {
+	// Check if the client registered too many users recently
+	if s.registerLimit != nil {
+		wait := s.registerLimit.Wait(client.IP)
+		if wait > 0 {
+			return &RegisterData{Status: status_codes.UserRegisterTooManyAttempts, RetryAfter: wait}, nil
+		}
+	}
+
 	// Clean fields
 	credentials.Name = strings.TrimSpace(credentials.Name)
+	credentials.Email = strings.TrimSpace(credentials.Email)
 
 	// Validate fields
 	if !rules.IsValidUserName(credentials.Name) {
-		return status_codes.UserRegisterInvalidName, "", nil
+		return &RegisterData{Status: status_codes.UserRegisterInvalidName}, nil
+	}
+	if !s.namePolicy.IsAllowed(credentials.Name) {
+		return &RegisterData{Status: status_codes.UserRegisterNameNotAllowed}, nil
 	}
-	if !rules.IsValidUserPassword(credentials.Password) {
-		return status_codes.UserRegisterInvalidPassword, "", nil
+	passwordProblems := s.passwordPolicy.Check(credentials.Password, credentials.Name)
+	if len(passwordProblems) > 0 {
+		return &RegisterData{
+			Status:           status_codes.UserRegisterInvalidPassword,
+			PasswordProblems: passwordProblems,
+		}, nil
+	}
+	if credentials.Email != "" && !rules.IsValidUserEmail(credentials.Email) {
+		return &RegisterData{Status: status_codes.UserRegisterInvalidEmail}, nil
 	}
 
-	// Check if a user with this name already exists
-	user, err := s.userRepo.GetUserByName(ctx, credentials.Name)
+	// Check if a user with this name already exists, ignoring upper/lowercase letters
+	taken, err := s.userRepo.IsNameTaken(ctx, credentials.Name, 0)
 	if err != nil {
-		log.Printf("[GetUserByName] | %v", err)
-		return -1, "", fmt.Errorf("[GetUserByName] | %v", err)
+		log.Printf("[IsNameTaken] | %v", err)
+		return nil, fmt.Errorf("[IsNameTaken] | %v", err)
 	}
 
-	if user != nil {
-		return status_codes.UserRegisterAlreadyRegistered, "", nil
+	if taken {
+		return &RegisterData{Status: status_codes.UserRegisterAlreadyRegistered}, nil
+	}
+
+	// Check if another user already has this email address
+	if credentials.Email != "" {
+		emailTaken, err := notifyEmailTaken(ctx, s.userRepo, s.mailer, credentials.Email, 0)
+		if err != nil {
+			log.Printf("[notifyEmailTaken] | %v", err)
+			return nil, fmt.Errorf("[notifyEmailTaken] | %v", err)
+		}
+
+		if emailTaken {
+			credentials.Email = ""
+		}
 	}
 
 	// Hash the password
-	credentials.Password, err = util.HashPassword(credentials.Password)
+	credentials.Password, err = s.hasher.Hash(credentials.Password)
 	if err != nil {
-		log.Printf("[HashPassword] | %v", err)
-		return -1, "", fmt.Errorf("[HashPassword] | %v", err)
+		log.Printf("[Hash] | %v", err)
+		return nil, fmt.Errorf("[Hash] | %v", err)
 	}
 
 	newUser, err := s.userRepo.RegisterUser(ctx, credentials)
+
+	// The email address may have been taken since it was checked, in which case the user is registered without it
+	if errors.Is(err, repo.ErrEmailTaken) {
+		_, err = notifyEmailTaken(ctx, s.userRepo, s.mailer, credentials.Email, 0)
+		if err != nil {
+			log.Printf("[notifyEmailTaken] | %v", err)
+			return nil, fmt.Errorf("[notifyEmailTaken] | %v", err)
+		}
+
+		credentials.Email = ""
+		newUser, err = s.userRepo.RegisterUser(ctx, credentials)
+	}
 	if err != nil {
+		// The name may have been taken since it was checked
+		if errors.Is(err, repo.ErrNameTaken) {
+			return &RegisterData{Status: status_codes.UserRegisterAlreadyRegistered}, nil
+		}
 		log.Printf("[RegisterUser] | %v", err)
-		return -1, "", fmt.Errorf("[RegisterUser] | %v", err)
+		return nil, fmt.Errorf("[RegisterUser] | %v", err)
+	}
+
+	if s.registerLimit != nil {
+		s.registerLimit.AddFailure(client.IP)
+	}
+
+	s.audit.Record(ctx, entities.AuditEvent{UserID: &newUser.ID, Type: entities.AuditEventRegister})
+
+	// Generate auth tokens
+	tokens, err := s.generateTokens(ctx, newUser.ID, "")
+	if err != nil {
+		log.Printf("[generateTokens] | %v", err)
+		return nil, fmt.Errorf("[generateTokens] | %v", err)
+	}
+
+	return &RegisterData{Status: status_codes.UserRegisterSuccess, Tokens: tokens}, nil
+}
+
+func (s authService) RegisterGuest(ctx context.Context, client entities.ClientInfo) (*RegisterData, error) {
+	// Check if the client registered too many users recently
+	if s.registerLimit != nil {
+		wait := s.registerLimit.Wait(client.IP)
+		if wait > 0 {
+			return &RegisterData{Status: status_codes.UserRegisterTooManyAttempts, RetryAfter: wait}, nil
+		}
+	}
+
+	suffix, err := util.GenerateRandomCode(guestNameLength, guestNameAlphabet)
+	if err != nil {
+		return nil, fmt.Errorf("[GenerateRandomCode] | %v", err)
+	}
+
+	newUser, err := s.userRepo.RegisterGuest(ctx, rules.GuestNamePrefix+suffix)
+	if err != nil {
+		return nil, fmt.Errorf("[RegisterGuest] | %v", err)
+	}
+
+	if s.registerLimit != nil {
+		s.registerLimit.AddFailure(client.IP)
 	}
 
-	// Generate auth token
-	token, err := util.GenerateAuthToken(newUser.ID, s.privateKey)
+	s.audit.Record(ctx, entities.AuditEvent{
+		UserID:  &newUser.ID,
+		Type:    entities.AuditEventRegister,
+		Details: map[string]string{"guest": "true"},
+	})
+
+	tokens, err := s.generateTokens(ctx, newUser.ID, "")
 	if err != nil {
-		log.Printf("[GenerateAuthToken] | %v", err)
-		return -1, "", fmt.Errorf("[GenerateAuthToken] | %v", err)
+		return nil, fmt.Errorf("[generateTokens] | %v", err)
 	}
 
-	return status_codes.UserRegisterSuccess, token, nil
+	return &RegisterData{Status: status_codes.UserRegisterSuccess, Tokens: tokens}, nil
+}
+
+func (s authService) UpgradeGuest(
+	ctx context.Context,
+	user *entities.User,
+	credentials entities.UserCredentials,
+) (status_codes.UserUpgrade, []rules.PasswordProblem, error) {
+	if !user.IsGuest {
+		return status_codes.UserUpgradeNotGuest, nil, nil
+	}
+
+	// Clean fields
+	credentials.Name = strings.TrimSpace(credentials.Name)
+	credentials.Email = strings.TrimSpace(credentials.Email)
+
+	// Validate fields
+	if !rules.IsValidUserName(credentials.Name) {
+		return status_codes.UserUpgradeInvalidName, nil, nil
+	}
+	if !s.namePolicy.IsAllowed(credentials.Name) {
+		return status_codes.UserUpgradeNameNotAllowed, nil, nil
+	}
+	passwordProblems := s.passwordPolicy.Check(credentials.Password, credentials.Name)
+	if len(passwordProblems) > 0 {
+		return status_codes.UserUpgradeInvalidPassword, passwordProblems, nil
+	}
+	if credentials.Email != "" && !rules.IsValidUserEmail(credentials.Email) {
+		return status_codes.UserUpgradeInvalidEmail, nil, nil
+	}
+
+	// Check if another user already has this name, ignoring upper/lowercase letters, or email address
+	taken, err := s.userRepo.IsNameTaken(ctx, credentials.Name, user.ID)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[IsNameTaken] | %v", err)
+	}
+
+	if taken {
+		return status_codes.UserUpgradeAlreadyRegistered, nil, nil
+	}
+
+	if credentials.Email != "" {
+		emailTaken, err := notifyEmailTaken(ctx, s.userRepo, s.mailer, credentials.Email, user.ID)
+		if err != nil {
+			return -1, nil, fmt.Errorf("[notifyEmailTaken] | %v", err)
+		}
+
+		if emailTaken {
+			credentials.Email = ""
+		}
+	}
+
+	// Hash the password
+	credentials.Password, err = s.hasher.Hash(credentials.Password)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[Hash] | %v", err)
+	}
+
+	upgraded, err := s.userRepo.UpgradeGuest(ctx, user.ID, credentials)
+
+	// The email address may have been taken since it was checked, in which case the guest is upgraded without it
+	if errors.Is(err, repo.ErrEmailTaken) {
+		_, err = notifyEmailTaken(ctx, s.userRepo, s.mailer, credentials.Email, user.ID)
+		if err != nil {
+			return -1, nil, fmt.Errorf("[notifyEmailTaken] | %v", err)
+		}
+
+		credentials.Email = ""
+		upgraded, err = s.userRepo.UpgradeGuest(ctx, user.ID, credentials)
+	}
+	if err != nil {
+		if errors.Is(err, repo.ErrNameTaken) {
+			return status_codes.UserUpgradeAlreadyRegistered, nil, nil
+		}
+		return -1, nil, fmt.Errorf("[UpgradeGuest] | %v", err)
+	}
+
+	if !upgraded {
+		return status_codes.UserUpgradeNotGuest, nil, nil
+	}
+
+	s.audit.Record(ctx, entities.AuditEvent{
+		UserID:  &user.ID,
+		Type:    entities.AuditEventGuestUpgrade,
+		Details: map[string]string{"name": credentials.Name},
+	})
+
+	return status_codes.UserUpgradeSuccess, nil, nil
+}
+
+func (s authService) PurgeStaleGuests(ctx context.Context) (int64, error) {
+	inactiveSince := time.Now().Add(-s.guestMaxInactive)
+
+	var total int64
+	for {
+		deleted, err := s.userRepo.DeleteStaleGuests(ctx, inactiveSince, guestPurgeBatch)
+		if err != nil {
+			return total, fmt.Errorf("[DeleteStaleGuests] | %v", err)
+		}
+
+		total += deleted
+		if deleted < guestPurgeBatch {
+			return total, nil
+		}
+	}
 }
 
 func (s authService) LoginUser(
 	ctx context.Context,
 	credentials entities.UserCredentials,
-) (status_codes.UserLogin, string, error) {
+	client entities.ClientInfo,
+) (*LoginData, error) {
 	// Clean fields
 	credentials.Name = strings.TrimSpace(credentials.Name)
+	accountKey := strings.ToLower(credentials.Name)
+
+	// Check if the client or the account failed too many times recently
+	wait := max(s.ipBackoff.Wait(client.IP), s.accountBackoff.Wait(accountKey))
+	if wait > 0 {
+		return &LoginData{Status: status_codes.UserLoginTooManyAttempts, RetryAfter: wait}, nil
+	}
 
 	user, err := s.userRepo.GetUserByName(ctx, credentials.Name)
 	if err != nil {
 		log.Printf("[GetUserByName] | %v", err)
-		return -1, "", fmt.Errorf("[GetUserByName] | %v", err)
+		return nil, fmt.Errorf("[GetUserByName] | %v", err)
 	}
 
 	// Check if user exists
 	if user == nil {
-		return status_codes.UserLoginNotFound, "", nil
+		// Still check a password, so that unknown names can't be told apart by response time
+		s.hasher.Verify(credentials.Password, s.dummyHash)
+		s.addLoginFailure(client.IP, accountKey)
+		s.audit.Record(ctx, entities.AuditEvent{
+			Type: entities.AuditEventLoginFailure,
+			Details: map[string]string{
+				"name":   credentials.Name,
+				"reason": entities.AuditLoginFailureNotFound,
+			},
+		})
+
+		if s.collapseErrors {
+			return &LoginData{Status: status_codes.UserLoginInvalidCredentials}, nil
+		}
+		return &LoginData{Status: status_codes.UserLoginNotFound}, nil
+	}
+
+	// Check if the account is locked
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		// Unknown names are never locked, so a locked account is answered like one when names can't be enumerated
+		if s.collapseErrors {
+			s.hasher.Verify(credentials.Password, s.dummyHash)
+			s.addLoginFailure(client.IP, accountKey)
+			return &LoginData{Status: status_codes.UserLoginInvalidCredentials}, nil
+		}
+
+		return &LoginData{
+			Status:     status_codes.UserLoginTooManyAttempts,
+			RetryAfter: time.Until(*user.LockedUntil),
+		}, nil
 	}
 
 	// Check if the password matches
-	if !util.CheckPasswordHash(credentials.Password, user.Password) {
-		return status_codes.UserLoginWrongPassword, "", nil
+	ok, needsRehash := s.hasher.Verify(credentials.Password, user.Password)
+	if !ok {
+		err = s.failLogin(ctx, user, client.IP, entities.AuditLoginFailureWrongPassword)
+		if err != nil {
+			log.Printf("[failLogin] | %v", err)
+			return nil, fmt.Errorf("[failLogin] | %v", err)
+		}
+
+		if s.collapseErrors {
+			return &LoginData{Status: status_codes.UserLoginInvalidCredentials}, nil
+		}
+		return &LoginData{Status: status_codes.UserLoginWrongPassword}, nil
+	}
+
+	// Hashes made by an older algorithm or with older parameters are replaced while the plain password is known
+	if needsRehash {
+		s.rehashPassword(ctx, user, credentials.Password)
+	}
+
+	// Only tell the user they are banned once they proved who they are
+	if user.IsBanned(time.Now()) {
+		s.recordBannedLogin(ctx, user)
+		return &LoginData{
+			Status:      status_codes.UserLoginBanned,
+			BannedUntil: user.BannedUntil,
+			BanReason:   user.BanReason,
+		}, nil
+	}
+
+	// Users with two-factor authentication still have to send a code
+	if user.TOTPEnabled {
+		challengeToken, err := util.GenerateChallengeToken(user.ID, s.keyring)
+		if err != nil {
+			log.Printf("[GenerateChallengeToken] | %v", err)
+			return nil, fmt.Errorf("[GenerateChallengeToken] | %v", err)
+		}
+
+		return &LoginData{Status: status_codes.UserLoginSecondFactorRequired, ChallengeToken: challengeToken}, nil
 	}
 
-	// Generate auth token
-	token, err := util.GenerateAuthToken(user.ID, s.privateKey)
+	tokens, err := s.completeLogin(ctx, user, client.IP)
 	if err != nil {
-		log.Printf("[GenerateAuthToken] | %v", err)
-		return -1, "", fmt.Errorf("[GenerateAuthToken] | %v", err)
+		log.Printf("[completeLogin] | %v", err)
+		return nil, fmt.Errorf("[completeLogin] | %v", err)
 	}
 
-	return status_codes.UserLoginSuccess, token, nil
+	return &LoginData{Status: status_codes.UserLoginSuccess, Tokens: tokens}, nil
 }
 
-func (s authService) GetUserFromToken(
+func (s authService) LoginSecondFactor(
+	ctx context.Context,
+	challengeToken string,
+	code string,
+	client entities.ClientInfo,
+) (*LoginSecondFactorData, error) {
+	challenge, err := util.ParseChallengeToken(challengeToken, s.keyring)
+	if err != nil {
+		return &LoginSecondFactorData{Status: status_codes.LoginSecondFactorInvalidChallenge}, nil
+	}
+
+	// Challenges can only be exchanged once
+	used, err := s.repo.IsAccessTokenRevoked(ctx, challenge.ID)
+	if err != nil {
+		return nil, fmt.Errorf("[IsAccessTokenRevoked] | %v", err)
+	}
+
+	if used {
+		return &LoginSecondFactorData{Status: status_codes.LoginSecondFactorInvalidChallenge}, nil
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("[GetUserByID] | %v", err)
+	}
+
+	if user == nil || !user.TOTPEnabled {
+		return &LoginSecondFactorData{Status: status_codes.LoginSecondFactorInvalidChallenge}, nil
+	}
+
+	// Codes are guessed like passwords, so they share the login limits
+	wait := max(s.ipBackoff.Wait(client.IP), s.accountBackoff.Wait(strings.ToLower(user.Name)))
+	if user.LockedUntil != nil {
+		wait = max(wait, time.Until(*user.LockedUntil))
+	}
+	if wait > 0 {
+		return &LoginSecondFactorData{Status: status_codes.LoginSecondFactorTooManyAttempts, RetryAfter: wait}, nil
+	}
+
+	valid, err := s.checkSecondFactor(ctx, user, code)
+	if err != nil {
+		return nil, fmt.Errorf("[checkSecondFactor] | %v", err)
+	}
+
+	if !valid {
+		err = s.failLogin(ctx, user, client.IP, entities.AuditLoginFailureInvalidCode)
+		if err != nil {
+			return nil, fmt.Errorf("[failLogin] | %v", err)
+		}
+
+		return &LoginSecondFactorData{Status: status_codes.LoginSecondFactorInvalidCode}, nil
+	}
+
+	// Only now the challenge is used up, so that a mistyped code can be sent again
+	unused, err := s.repo.UseSingleUseToken(ctx, challenge.ID, challenge.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("[UseSingleUseToken] | %v", err)
+	}
+
+	if !unused {
+		return &LoginSecondFactorData{Status: status_codes.LoginSecondFactorInvalidChallenge}, nil
+	}
+
+	// The user may have been banned after getting the challenge
+	if user.IsBanned(time.Now()) {
+		s.recordBannedLogin(ctx, user)
+		return &LoginSecondFactorData{Status: status_codes.LoginSecondFactorBanned}, nil
+	}
+
+	tokens, err := s.completeLogin(ctx, user, client.IP)
+	if err != nil {
+		return nil, fmt.Errorf("[completeLogin] | %v", err)
+	}
+
+	return &LoginSecondFactorData{Status: status_codes.LoginSecondFactorSuccess, Tokens: tokens}, nil
+}
+
+func (s authService) EnrollTwoFactor(
+	ctx context.Context,
+	user *entities.User,
+) (status_codes.TwoFactorEnroll, *TwoFactorEnrollment, error) {
+	if user.TOTPEnabled {
+		return status_codes.TwoFactorEnrollAlreadyEnabled, nil, nil
+	}
+
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		return -1, nil, fmt.Errorf("[GenerateTOTPSecret] | %v", err)
+	}
+
+	err = s.userRepo.SetTOTPSecret(ctx, user.ID, secret)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[SetTOTPSecret] | %v", err)
+	}
+
+	return status_codes.TwoFactorEnrollSuccess, &TwoFactorEnrollment{
+		Secret: secret,
+		URI:    util.BuildTOTPURI(totpIssuer, user.Name, secret),
+	}, nil
+}
+
+func (s authService) ConfirmTwoFactor(
+	ctx context.Context,
+	user *entities.User,
+	code string,
+) (status_codes.TwoFactorConfirm, []string, error) {
+	if user.TOTPEnabled {
+		return status_codes.TwoFactorConfirmAlreadyEnabled, nil, nil
+	}
+	if user.TOTPSecret == nil {
+		return status_codes.TwoFactorConfirmNotEnrolled, nil, nil
+	}
+
+	// Only a TOTP code proves the authenticator app was set up
+	valid, err := s.checkTOTP(ctx, user, code)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[checkTOTP] | %v", err)
+	}
+
+	if !valid {
+		return status_codes.TwoFactorConfirmInvalidCode, nil, nil
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	codeHashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = util.GenerateRecoveryCode()
+		if err != nil {
+			return -1, nil, fmt.Errorf("[GenerateRecoveryCode] | %v", err)
+		}
+		codeHashes[i] = util.HashToken(codes[i])
+	}
+
+	err = s.userRepo.EnableTOTP(ctx, user.ID, codeHashes)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[EnableTOTP] | %v", err)
+	}
+
+	s.audit.Record(ctx, entities.AuditEvent{UserID: &user.ID, Type: entities.AuditEventTwoFactorEnable})
+
+	return status_codes.TwoFactorConfirmSuccess, codes, nil
+}
+
+func (s authService) DisableTwoFactor(
+	ctx context.Context,
+	user *entities.User,
+	password string,
+	code string,
+) (status_codes.TwoFactorDisable, error) {
+	if !user.TOTPEnabled {
+		return status_codes.TwoFactorDisableNotEnabled, nil
+	}
+
+	if ok, _ := s.hasher.Verify(password, user.Password); !ok {
+		return status_codes.TwoFactorDisableWrongPassword, nil
+	}
+
+	valid, err := s.checkSecondFactor(ctx, user, code)
+	if err != nil {
+		return -1, fmt.Errorf("[checkSecondFactor] | %v", err)
+	}
+
+	if !valid {
+		return status_codes.TwoFactorDisableInvalidCode, nil
+	}
+
+	err = s.userRepo.DisableTOTP(ctx, user.ID)
+	if err != nil {
+		return -1, fmt.Errorf("[DisableTOTP] | %v", err)
+	}
+
+	s.audit.Record(ctx, entities.AuditEvent{UserID: &user.ID, Type: entities.AuditEventTwoFactorDisable})
+
+	return status_codes.TwoFactorDisableSuccess, nil
+}
+
+func (s authService) RefreshTokens(
+	ctx context.Context,
+	refreshToken string,
+) (status_codes.TokenRefresh, *entities.AuthTokens, error) {
+	token, err := s.repo.GetRefreshToken(ctx, util.HashToken(refreshToken))
+	if err != nil {
+		return -1, nil, fmt.Errorf("[GetRefreshToken] | %v", err)
+	}
+
+	if token == nil || time.Now().After(token.ExpiresAt) {
+		return status_codes.TokenRefreshInvalidToken, nil, nil
+	}
+
+	// Revoke the used token; if it was already used, revoke every token from the same login
+	valid, err := s.repo.RevokeRefreshToken(ctx, token.ID)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[RevokeRefreshToken] | %v", err)
+	}
+
+	if !valid {
+		log.Printf("refresh token reused for user %d; revoking family", token.UserID)
+		err = s.repo.RevokeRefreshTokenFamily(ctx, token.Family)
+		if err != nil {
+			return -1, nil, fmt.Errorf("[RevokeRefreshTokenFamily] | %v", err)
+		}
+
+		s.audit.Record(ctx, entities.AuditEvent{UserID: &token.UserID, Type: entities.AuditEventRefreshTokenReuse})
+
+		return status_codes.TokenRefreshInvalidToken, nil, nil
+	}
+
+	tokens, err := s.generateTokens(ctx, token.UserID, token.Family)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[generateTokens] | %v", err)
+	}
+
+	return status_codes.TokenRefreshSuccess, tokens, nil
+}
+
+func (s authService) Logout(ctx context.Context, token *entities.AuthToken, refreshToken string) error {
+	err := s.repo.RevokeAccessToken(ctx, token.ID, token.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("[RevokeAccessToken] | %v", err)
+	}
+
+	s.audit.Record(ctx, entities.AuditEvent{UserID: &token.UserID, Type: entities.AuditEventLogout})
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	storedToken, err := s.repo.GetRefreshToken(ctx, util.HashToken(refreshToken))
+	if err != nil {
+		return fmt.Errorf("[GetRefreshToken] | %v", err)
+	}
+
+	// Users can only revoke their own refresh tokens
+	if storedToken == nil || storedToken.UserID != token.UserID {
+		return nil
+	}
+
+	_, err = s.repo.RevokeRefreshToken(ctx, storedToken.ID)
+	if err != nil {
+		return fmt.Errorf("[RevokeRefreshToken] | %v", err)
+	}
+
+	return nil
+}
+
+func (s authService) LogoutAll(ctx context.Context, user *entities.User) error {
+	err := s.userRepo.RevokeTokens(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("[RevokeTokens] | %v", err)
+	}
+
+	err = s.repo.RevokeUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("[RevokeUserRefreshTokens] | %v", err)
+	}
+
+	s.audit.Record(ctx, entities.AuditEvent{UserID: &user.ID, Type: entities.AuditEventLogoutAll})
+
+	return nil
+}
+
+func (s authService) RequestPasswordReset(
+	ctx context.Context,
+	email string,
+) (status_codes.PasswordResetRequest, error) {
+	email = strings.TrimSpace(email)
+	if !rules.IsValidUserEmail(email) {
+		return status_codes.PasswordResetRequestInvalidEmail, nil
+	}
+
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return -1, fmt.Errorf("[GetUserByEmail] | %v", err)
+	}
+
+	if user == nil {
+		return status_codes.PasswordResetRequestSuccess, nil
+	}
+
+	token, err := util.GenerateRandomToken(32)
+	if err != nil {
+		return -1, fmt.Errorf("[GenerateRandomToken] | %v", err)
+	}
+
+	expiresAt := time.Now().Add(util.PasswordResetTokenDuration)
+	err = s.repo.CreatePasswordResetToken(ctx, user.ID, util.HashToken(token), expiresAt)
+	if err != nil {
+		return -1, fmt.Errorf("[CreatePasswordResetToken] | %v", err)
+	}
+
+	s.audit.Record(ctx, entities.AuditEvent{UserID: &user.ID, Type: entities.AuditEventPasswordResetRequest})
+
+	mail, err := s.buildPasswordResetMail(user, token)
+	if err != nil {
+		return -1, fmt.Errorf("[buildPasswordResetMail] | %v", err)
+	}
+
+	// The email is sent in the background, so that the response doesn't take longer when the address exists
+	go func() {
+		err := s.mailer.Send(context.Background(), mail)
+		if err != nil {
+			log.Printf("[Send] | %v", err)
+		}
+	}()
+
+	return status_codes.PasswordResetRequestSuccess, nil
+}
+
+func (s authService) ConfirmPasswordReset(
 	ctx context.Context,
 	token string,
-) (*entities.User, error) {
-	// Get ID in token
-	userID, err := util.GetIDFromToken(token, s.publicKey)
+	newPassword string,
+) (status_codes.PasswordResetConfirm, []rules.PasswordProblem, error) {
+	resetToken, err := s.repo.GetPasswordResetToken(ctx, util.HashToken(token))
+	if err != nil {
+		return -1, nil, fmt.Errorf("[GetPasswordResetToken] | %v", err)
+	}
+
+	if resetToken == nil || resetToken.UsedAt != nil || time.Now().After(resetToken.ExpiresAt) {
+		return status_codes.PasswordResetConfirmInvalidToken, nil, nil
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, resetToken.UserID)
 	if err != nil {
-		return nil, fmt.Errorf("[GetUserIDFromToken] | %v", err)
+		return -1, nil, fmt.Errorf("[GetUserByID] | %v", err)
+	}
+
+	if user == nil {
+		return status_codes.PasswordResetConfirmInvalidToken, nil, nil
+	}
+
+	// Validate the new password before using the token, so that it can be retried
+	passwordProblems := s.passwordPolicy.Check(newPassword, user.Name)
+	if len(passwordProblems) > 0 {
+		return status_codes.PasswordResetConfirmInvalidPassword, passwordProblems, nil
+	}
+
+	unused, err := s.repo.UsePasswordResetToken(ctx, resetToken.ID)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[UsePasswordResetToken] | %v", err)
+	}
+
+	if !unused {
+		return status_codes.PasswordResetConfirmInvalidToken, nil, nil
+	}
+
+	hashedPassword, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[Hash] | %v", err)
+	}
+
+	err = s.userRepo.UpdatePassword(ctx, resetToken.UserID, hashedPassword)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[UpdatePassword] | %v", err)
+	}
+
+	s.audit.Record(ctx, entities.AuditEvent{UserID: &resetToken.UserID, Type: entities.AuditEventPasswordReset})
+
+	// Whoever knew the old password is logged out, and the owner can log in right away
+	err = s.LogoutAll(ctx, &entities.User{ID: resetToken.UserID})
+	if err != nil {
+		return -1, nil, fmt.Errorf("[LogoutAll] | %v", err)
+	}
+
+	err = s.userRepo.ResetLoginFailures(ctx, resetToken.UserID)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[ResetLoginFailures] | %v", err)
+	}
+
+	return status_codes.PasswordResetConfirmSuccess, nil, nil
+}
+
+func (s authService) GetUserFromToken(
+	ctx context.Context,
+	tokenString string,
+) (*entities.User, *entities.AuthToken, error) {
+	// Verify token
+	token, err := util.ParseAuthToken(tokenString, s.keyring)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[ParseAuthToken] | %v", err)
+	}
+
+	// Check if the token was revoked
+	revoked, err := s.repo.IsAccessTokenRevoked(ctx, token.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[IsAccessTokenRevoked] | %v", err)
+	}
+
+	if revoked {
+		return nil, nil, nil
 	}
 
 	// Get user from ID
-	user, err := s.userRepo.GetUserByID(ctx, userID)
+	user, err := s.userRepo.GetUserByID(ctx, token.UserID)
 	if err != nil {
-		return nil, fmt.Errorf("[GetUserByID] | %v", err)
+		return nil, nil, fmt.Errorf("[GetUserByID] | %v", err)
+	}
+
+	// Check if the token was issued before the user logged out of every session
+	if user != nil && user.TokensValidAfter != nil && token.IssuedAt.Before(*user.TokensValidAfter) {
+		return nil, nil, nil
+	}
+
+	// Failing to update the last activity only makes the user look inactive longer, so the request still goes on
+	if user != nil && (user.LastActiveAt == nil || time.Since(*user.LastActiveAt) > lastActiveUpdateInterval) {
+		err = s.userRepo.UpdateLastActive(ctx, user.ID)
+		if err != nil {
+			log.Printf("[UpdateLastActive] | %v", err)
+		}
+	}
+
+	return user, token, nil
+}
+
+func (s authService) CreateStreamTicket(_ context.Context, user *entities.User) (string, error) {
+	ticket, err := util.GenerateStreamTicket(user.ID, s.keyring)
+	if err != nil {
+		return "", fmt.Errorf("[GenerateStreamTicket] | %v", err)
+	}
+
+	return ticket, nil
+}
+
+func (s authService) GetUserFromStreamTicket(
+	ctx context.Context,
+	ticket string,
+) (*entities.User, *entities.AuthToken, error) {
+	claims, err := util.ParseStreamTicket(ticket, s.keyring)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[ParseStreamTicket] | %v", err)
+	}
+
+	unused, err := s.repo.UseSingleUseToken(ctx, claims.ID, claims.ExpiresAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[UseSingleUseToken] | %v", err)
+	}
+
+	if !unused {
+		return nil, nil, nil
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[GetUserByID] | %v", err)
+	}
+
+	// Check if the ticket was issued before the user logged out of every session
+	if user != nil && user.TokensValidAfter != nil && claims.IssuedAt.Before(*user.TokensValidAfter) {
+		return nil, nil, nil
+	}
+
+	return user, claims, nil
+}
+
+// notifyEmailTaken checks whether the provided email address belongs to a user other than the one with the provided
+// ID, sending its owner the email taken mail if so. An exceptUserID of 0 checks every user
+func notifyEmailTaken(
+	ctx context.Context,
+	userRepo repo.UserRepository,
+	mailer util.Mailer,
+	email string,
+	exceptUserID int64,
+) (bool, error) {
+	owner, err := userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return false, fmt.Errorf("[GetUserByEmail] | %v", err)
+	}
+
+	if owner == nil || owner.ID == exceptUserID {
+		return false, nil
+	}
+
+	sendEmailTakenMail(mailer, owner)
+	return true, nil
+}
+
+// sendEmailTakenMail tells the owner of an email address, in the background, that someone tried to use it for another
+// account. Such attempts succeed without the address instead of failing, so that addresses can't be enumerated
+func sendEmailTakenMail(mailer util.Mailer, owner *entities.User) {
+	body := fmt.Sprintf(
+		"Hi %s,\n\n"+
+			"Someone tried to use this email address for another Termo account. It already belongs to your account, "+
+			"so it wasn't added to theirs.\n\n"+
+			"If it was you, log in as %s instead, resetting your password if you forgot it. Otherwise, ignore this "+
+			"email.\n",
+		owner.Name,
+		owner.Name,
+	)
+
+	mail := util.Mail{
+		To:      *owner.Email,
+		Subject: "Your email address was used on Termo",
+		Body:    body,
+	}
+
+	go func() {
+		err := mailer.Send(context.Background(), mail)
+		if err != nil {
+			log.Printf("[Send] | %v", err)
+		}
+	}()
+}
+
+// buildPasswordResetMail builds the email sent to a user with their password reset token
+func (s authService) buildPasswordResetMail(user *entities.User, token string) (util.Mail, error) {
+	var action string
+	if s.passwordResetURL != "" {
+		resetURL, err := url.Parse(s.passwordResetURL)
+		if err != nil {
+			return util.Mail{}, fmt.Errorf("[url.Parse] | %v", err)
+		}
+
+		query := resetURL.Query()
+		query.Set("token", token)
+		resetURL.RawQuery = query.Encode()
+
+		action = "To choose a new password, open the link below:\n\n" + resetURL.String()
+	} else {
+		action = "To choose a new password, use the code below:\n\n" + token
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\n"+
+			"Someone asked to reset the password of your Termo account. %s\n\n"+
+			"It expires in %d minutes. If you didn't ask for it, ignore this email; your password won't change.\n",
+		user.Name,
+		action,
+		int(util.PasswordResetTokenDuration.Minutes()),
+	)
+
+	return util.Mail{
+		To:      *user.Email,
+		Subject: "Reset your Termo password",
+		Body:    body,
+	}, nil
+}
+
+// addLoginFailure registers a failed login for both the client IP and the account
+func (s authService) addLoginFailure(ip string, accountKey string) {
+	s.ipBackoff.AddFailure(ip)
+	s.accountBackoff.AddFailure(accountKey)
+}
+
+// failLogin registers a failed login of an existing user, which may lock their account, and records it in the audit log
+// with the provided reason
+func (s authService) failLogin(ctx context.Context, user *entities.User, ip string, reason string) error {
+	s.addLoginFailure(ip, strings.ToLower(user.Name))
+	s.audit.Record(ctx, entities.AuditEvent{
+		UserID:  &user.ID,
+		Type:    entities.AuditEventLoginFailure,
+		Details: map[string]string{"reason": reason},
+	})
+
+	err := s.userRepo.RegisterLoginFailure(ctx, user.ID, s.lockoutFailures, s.lockoutMinutes)
+	if err != nil {
+		return fmt.Errorf("[RegisterLoginFailure] | %v", err)
+	}
+
+	return nil
+}
+
+// completeLogin clears the failed logins of a user who sent every required credential and issues their auth tokens
+func (s authService) completeLogin(ctx context.Context, user *entities.User, ip string) (*entities.AuthTokens, error) {
+	s.ipBackoff.Reset(ip)
+	s.accountBackoff.Reset(strings.ToLower(user.Name))
+
+	err := s.userRepo.ResetLoginFailures(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("[ResetLoginFailures] | %v", err)
+	}
+
+	tokens, err := s.generateTokens(ctx, user.ID, "")
+	if err != nil {
+		return nil, fmt.Errorf("[generateTokens] | %v", err)
+	}
+
+	s.audit.Record(ctx, entities.AuditEvent{UserID: &user.ID, Type: entities.AuditEventLogin})
+
+	return tokens, nil
+}
+
+// recordBannedLogin records in the audit log that a banned user sent valid credentials
+func (s authService) recordBannedLogin(ctx context.Context, user *entities.User) {
+	s.audit.Record(ctx, entities.AuditEvent{
+		UserID:  &user.ID,
+		Type:    entities.AuditEventLoginFailure,
+		Details: map[string]string{"reason": entities.AuditLoginFailureBanned},
+	})
+}
+
+// rehashPassword replaces the stored hash of a user's password with one made by the configured algorithm and
+// parameters. Failures are only logged, since the old hash still works
+func (s authService) rehashPassword(ctx context.Context, user *entities.User, password string) {
+	hashed, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Printf("[Hash] | %v", err)
+		return
+	}
+
+	err = s.userRepo.UpdatePassword(ctx, user.ID, hashed)
+	if err != nil {
+		log.Printf("[UpdatePassword] | %v", err)
+	}
+}
+
+// checkTOTP checks whether a code is valid for the user's TOTP secret and wasn't used before
+func (s authService) checkTOTP(ctx context.Context, user *entities.User, code string) (bool, error) {
+	if user.TOTPSecret == nil {
+		return false, nil
+	}
+
+	step, ok := util.ValidateTOTP(*user.TOTPSecret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	unused, err := s.userRepo.UseTOTPStep(ctx, user.ID, step)
+	if err != nil {
+		return false, fmt.Errorf("[UseTOTPStep] | %v", err)
+	}
+
+	return unused, nil
+}
+
+// checkSecondFactor checks whether a code is either a valid TOTP code or an unused recovery code of the user. A
+// matching recovery code is used up
+func (s authService) checkSecondFactor(ctx context.Context, user *entities.User, code string) (bool, error) {
+	valid, err := s.checkTOTP(ctx, user, code)
+	if err != nil {
+		return false, fmt.Errorf("[checkTOTP] | %v", err)
+	}
+
+	if valid {
+		return true, nil
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, user.ID, util.HashToken(util.NormalizeRecoveryCode(code)))
+	if err != nil {
+		return false, fmt.Errorf("[UseRecoveryCode] | %v", err)
+	}
+
+	return used, nil
+}
+
+// generateTokens issues a new auth token and refresh token for a user. The refresh token is added to the provided
+// family, or to a new one if empty
+func (s authService) generateTokens(ctx context.Context, userID int64, family string) (*entities.AuthTokens, error) {
+	accessToken, err := util.GenerateAuthToken(userID, s.keyring)
+	if err != nil {
+		return nil, fmt.Errorf("[GenerateAuthToken] | %v", err)
+	}
+
+	refreshToken, err := util.GenerateRandomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("[GenerateRandomToken] | %v", err)
+	}
+
+	if family == "" {
+		family, err = util.GenerateRandomToken(16)
+		if err != nil {
+			return nil, fmt.Errorf("[GenerateRandomToken] | %v", err)
+		}
+	}
+
+	expiresAt := time.Now().Add(util.RefreshTokenDuration)
+	err = s.repo.CreateRefreshToken(ctx, userID, util.HashToken(refreshToken), family, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("[CreateRefreshToken] | %v", err)
 	}
 
-	return user, nil
+	return &entities.AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
 }
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"aidanwoods.dev/go-paseto"
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/modules/repo"
+	"termo_back_end/internal/rules"
+	"termo_back_end/internal/status_codes"
+	"termo_back_end/internal/util"
+	"testing"
+	"time"
+)
+
+// Fake repositories keep their data in memory. They embed the repository interfaces, so calling a method they don't
+// implement panics
+
+type fakeUserRepo struct {
+	repo.UserRepository
+	users map[int64]*entities.User
+}
+
+func (r *fakeUserRepo) GetUserByID(_ context.Context, userID int64) (*entities.User, error) {
+	return r.users[userID], nil
+}
+
+func (r *fakeUserRepo) GetUserByEmail(_ context.Context, email string) (*entities.User, error) {
+	for _, user := range r.users {
+		if user.Email != nil && strings.EqualFold(*user.Email, email) {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepo) UpdatePassword(_ context.Context, userID int64, password string) error {
+	r.users[userID].Password = password
+	return nil
+}
+
+func (r *fakeUserRepo) RevokeTokens(_ context.Context, _ int64) error {
+	return nil
+}
+
+func (r *fakeUserRepo) ResetLoginFailures(_ context.Context, _ int64) error {
+	return nil
+}
+
+type fakeAuthRepo struct {
+	repo.AuthRepository
+	resetTokens map[string]*entities.PasswordResetToken
+}
+
+func (r *fakeAuthRepo) CreatePasswordResetToken(
+	_ context.Context,
+	userID int64,
+	tokenHash string,
+	expiresAt time.Time,
+) error {
+	r.resetTokens[tokenHash] = &entities.PasswordResetToken{
+		ID:        int64(len(r.resetTokens) + 1),
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}
+	return nil
+}
+
+func (r *fakeAuthRepo) GetPasswordResetToken(
+	_ context.Context,
+	tokenHash string,
+) (*entities.PasswordResetToken, error) {
+	return r.resetTokens[tokenHash], nil
+}
+
+func (r *fakeAuthRepo) UsePasswordResetToken(_ context.Context, id int64) (bool, error) {
+	for _, token := range r.resetTokens {
+		if token.ID == id && token.UsedAt == nil {
+			now := time.Now()
+			token.UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeAuthRepo) RevokeUserRefreshTokens(_ context.Context, _ int64) error {
+	return nil
+}
+
+type fakeAuditRepo struct {
+	repo.AuditRepository
+}
+
+func (r *fakeAuditRepo) InsertEvent(_ context.Context, _ entities.AuditEvent) error {
+	return nil
+}
+
+// waitForMail waits for the log mailer to write an email to the provided directory and returns its contents
+func waitForMail(t *testing.T, dir string) string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+		if err != nil {
+			t.Fatalf("[filepath.Glob] | %v", err)
+		}
+
+		if len(files) > 0 {
+			message, err := os.ReadFile(files[0])
+			if err != nil {
+				t.Fatalf("[os.ReadFile] | %v", err)
+			}
+			return string(message)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("no email was sent")
+	return ""
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	ctx := context.Background()
+	mailDir := t.TempDir()
+
+	secretKey := paseto.NewV4AsymmetricSecretKey()
+
+	var config entities.Config
+	config.Auth.PrivateKey = secretKey.ExportHex()
+	config.Auth.PublicKey = secretKey.Public().ExportHex()
+	config.Auth.PasswordHashing.Algorithm = entities.PasswordHashBcrypt
+	config.Mail.From = "termo@example.com"
+	config.Mail.PasswordResetURL = "https://termo.example.com/reset"
+
+	hasher, err := util.NewPasswordHasher(config)
+	if err != nil {
+		t.Fatalf("[NewPasswordHasher] | %v", err)
+	}
+
+	passwordPolicy, err := rules.NewPasswordPolicy(config, nil)
+	if err != nil {
+		t.Fatalf("[NewPasswordPolicy] | %v", err)
+	}
+
+	oldPassword, err := hasher.Hash("Old-Passw0rd!")
+	if err != nil {
+		t.Fatalf("[Hash] | %v", err)
+	}
+
+	email := "player@example.com"
+	userRepo := &fakeUserRepo{users: map[int64]*entities.User{
+		1: {ID: 1, Name: "player", Password: oldPassword, Email: &email},
+	}}
+	authRepo := &fakeAuthRepo{resetTokens: make(map[string]*entities.PasswordResetToken)}
+
+	s := NewAuthService(
+		config,
+		userRepo,
+		authRepo,
+		hasher,
+		passwordPolicy,
+		util.NewLogMailer(config.Mail.From, mailDir),
+		NewAuditService(&fakeAuditRepo{}),
+	)
+
+	// Unknown addresses get the same response, but no email
+	status, err := s.RequestPasswordReset(ctx, "nobody@example.com")
+	if err != nil {
+		t.Fatalf("[RequestPasswordReset] | %v", err)
+	}
+	if status != status_codes.PasswordResetRequestSuccess {
+		t.Fatalf("unknown address: got status %s, want %s", status, status_codes.PasswordResetRequestSuccess)
+	}
+	if len(authRepo.resetTokens) != 0 {
+		t.Fatalf("unknown address: a reset token was created")
+	}
+
+	status, err = s.RequestPasswordReset(ctx, email)
+	if err != nil {
+		t.Fatalf("[RequestPasswordReset] | %v", err)
+	}
+	if status != status_codes.PasswordResetRequestSuccess {
+		t.Fatalf("got status %s, want %s", status, status_codes.PasswordResetRequestSuccess)
+	}
+
+	message := waitForMail(t, mailDir)
+	if !strings.Contains(message, "To: "+email+"\r\n") {
+		t.Fatalf("email not sent to %s:\n%s", email, message)
+	}
+
+	var token string
+	for _, line := range strings.Split(message, "\r\n") {
+		if strings.HasPrefix(line, config.Mail.PasswordResetURL) {
+			resetURL, err := url.Parse(line)
+			if err != nil {
+				t.Fatalf("[url.Parse] | %v", err)
+			}
+			token = resetURL.Query().Get("token")
+		}
+	}
+	if token == "" {
+		t.Fatalf("no reset token in the email:\n%s", message)
+	}
+
+	// An invalid password doesn't use the token
+	confirmStatus, problems, err := s.ConfirmPasswordReset(ctx, token, "short")
+	if err != nil {
+		t.Fatalf("[ConfirmPasswordReset] | %v", err)
+	}
+	if confirmStatus != status_codes.PasswordResetConfirmInvalidPassword || len(problems) == 0 {
+		t.Fatalf(
+			"invalid password: got status %s, want %s",
+			confirmStatus,
+			status_codes.PasswordResetConfirmInvalidPassword,
+		)
+	}
+
+	confirmStatus, _, err = s.ConfirmPasswordReset(ctx, token, "New-Passw0rd!")
+	if err != nil {
+		t.Fatalf("[ConfirmPasswordReset] | %v", err)
+	}
+	if confirmStatus != status_codes.PasswordResetConfirmSuccess {
+		t.Fatalf("got status %s, want %s", confirmStatus, status_codes.PasswordResetConfirmSuccess)
+	}
+
+	if ok, _ := hasher.Verify("New-Passw0rd!", userRepo.users[1].Password); !ok {
+		t.Fatalf("the password was not changed")
+	}
+
+	// Tokens can only be used once
+	confirmStatus, _, err = s.ConfirmPasswordReset(ctx, token, "Another-Passw0rd!")
+	if err != nil {
+		t.Fatalf("[ConfirmPasswordReset] | %v", err)
+	}
+	if confirmStatus != status_codes.PasswordResetConfirmInvalidToken {
+		t.Fatalf("reused token: got status %s, want %s", confirmStatus, status_codes.PasswordResetConfirmInvalidToken)
+	}
+}
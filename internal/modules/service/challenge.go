@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/modules/repo"
+	"termo_back_end/internal/rules"
+	"termo_back_end/internal/status_codes"
+	"termo_back_end/internal/util"
+)
+
+// challengeCodeSize is how many random bytes challenge codes are made from, so that they can't be guessed
+const challengeCodeSize = 9
+
+// challengeResultsLimit is how many players are shown in a challenge's results
+const challengeResultsLimit = 100
+
+type ChallengeCreateData struct {
+	Status status_codes.ChallengeCreate
+	Code   string
+
+	// InvalidWords are the custom words that aren't in the word list, have another length or are repeated
+	InvalidWords []string
+}
+
+type ChallengeService interface {
+	// CreateChallenge creates a challenge for others to play, either with the provided custom words or with randomly
+	// chosen ones. Returns the code used to share it
+	CreateChallenge(
+		ctx context.Context,
+		user *entities.User,
+		options entities.ChallengeOptions,
+	) (*ChallengeCreateData, error)
+
+	// GetChallenge returns the challenge with the provided code, without its words
+	GetChallenge(ctx context.Context, code string) (status_codes.ChallengeGet, *entities.ChallengeResponse, error)
+
+	// GetChallengeResults returns how each player did on the challenge with the provided code. Only its creator can
+	// see them
+	GetChallengeResults(
+		ctx context.Context,
+		user *entities.User,
+		code string,
+	) (status_codes.ChallengeResults, []entities.ChallengeResult, error)
+}
+
+type challengeService struct {
+	wordMap util.WordMap
+	repo    repo.ChallengeRepository
+}
+
+func NewChallengeService(wordMap util.WordMap, repo repo.ChallengeRepository) ChallengeService {
+	return challengeService{
+		wordMap: wordMap,
+		repo:    repo,
+	}
+}
+
+func (s challengeService) CreateChallenge(
+	ctx context.Context,
+	user *entities.User,
+	options entities.ChallengeOptions,
+) (*ChallengeCreateData, error) {
+	challenge := entities.Challenge{
+		CreatorID:   user.ID,
+		CreatorName: user.Name,
+		CustomWords: len(options.Words) > 0,
+		HardMode:    options.HardMode,
+	}
+
+	if challenge.CustomWords {
+		status, words, invalidWords := s.checkCustomWords(options.Words)
+		if status != status_codes.ChallengeCreateSuccess {
+			return &ChallengeCreateData{
+				Status:       status,
+				InvalidWords: invalidWords,
+			}, nil
+		}
+
+		challenge.Words = words
+	} else {
+		if !rules.IsValidWordLength(options.WordLength) {
+			return &ChallengeCreateData{Status: status_codes.ChallengeCreateInvalidWordLength}, nil
+		}
+		if !rules.IsValidWordCount(options.WordCount) {
+			return &ChallengeCreateData{Status: status_codes.ChallengeCreateInvalidCount}, nil
+		}
+
+		words, err := s.wordMap.ChooseRandom(options.WordLength, options.WordCount)
+		if err != nil {
+			if errors.Is(err, util.ErrInvalidSize) {
+				return &ChallengeCreateData{Status: status_codes.ChallengeCreateInvalidWordLength}, nil
+			}
+			if errors.Is(err, util.ErrNotEnoughWords) {
+				return &ChallengeCreateData{Status: status_codes.ChallengeCreateInvalidCount}, nil
+			}
+			return nil, fmt.Errorf("[ChooseRandom] | %v", err)
+		}
+
+		challenge.Words = words
+	}
+
+	code, err := util.GenerateRandomToken(challengeCodeSize)
+	if err != nil {
+		return nil, fmt.Errorf("[GenerateRandomToken] | %v", err)
+	}
+	challenge.Code = code
+
+	_, err = s.repo.CreateChallenge(ctx, challenge)
+	if err != nil {
+		return nil, fmt.Errorf("[CreateChallenge] | %v", err)
+	}
+
+	return &ChallengeCreateData{
+		Status: status_codes.ChallengeCreateSuccess,
+		Code:   code,
+	}, nil
+}
+
+// checkCustomWords cleans the words typed by a challenge's creator and ensures every one of them can be played.
+// Returns the cleaned words, or the ones that can't be played as typed
+func (s challengeService) checkCustomWords(words []string) (status_codes.ChallengeCreate, []string, []string) {
+	if !rules.IsValidWordCount(uint32(len(words))) {
+		return status_codes.ChallengeCreateInvalidCount, nil, nil
+	}
+
+	cleanedWords := make([]string, len(words))
+	for i, word := range words {
+		cleanedWords[i] = s.wordMap.CleanWord(word)
+	}
+
+	wordLength := uint32(len(cleanedWords[0]))
+	if !rules.IsValidWordLength(wordLength) {
+		return status_codes.ChallengeCreateInvalidWordLength, nil, nil
+	}
+
+	var invalidWords []string
+	for i, word := range cleanedWords {
+		_, ok := s.wordMap.GetOriginalWord(word)
+		if !ok || uint32(len(word)) != wordLength || slices.Index(cleanedWords, word) != i {
+			invalidWords = append(invalidWords, words[i])
+		}
+	}
+	if len(invalidWords) > 0 {
+		return status_codes.ChallengeCreateInvalidWords, nil, invalidWords
+	}
+
+	return status_codes.ChallengeCreateSuccess, cleanedWords, nil
+}
+
+func (s challengeService) GetChallenge(
+	ctx context.Context,
+	code string,
+) (status_codes.ChallengeGet, *entities.ChallengeResponse, error) {
+	challenge, err := s.repo.GetChallengeByCode(ctx, code)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[GetChallengeByCode] | %v", err)
+	}
+
+	if challenge == nil {
+		return status_codes.ChallengeGetNotFound, nil, nil
+	}
+
+	response := challenge.ToResponse(rules.GetGameMaxAttempts(challenge.GetWordLength(), challenge.GetWordCount()))
+	return status_codes.ChallengeGetSuccess, &response, nil
+}
+
+func (s challengeService) GetChallengeResults(
+	ctx context.Context,
+	user *entities.User,
+	code string,
+) (status_codes.ChallengeResults, []entities.ChallengeResult, error) {
+	challenge, err := s.repo.GetChallengeByCode(ctx, code)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[GetChallengeByCode] | %v", err)
+	}
+
+	// Results are only shown to the challenge's creator
+	if challenge == nil || challenge.CreatorID != user.ID {
+		return status_codes.ChallengeResultsNotFound, nil, nil
+	}
+
+	results, err := s.repo.GetChallengeResults(ctx, challenge.ID, challengeResultsLimit)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[GetChallengeResults] | %v", err)
+	}
+
+	return status_codes.ChallengeResultsSuccess, results, nil
+}
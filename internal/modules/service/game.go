@@ -2,31 +2,82 @@ package service
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"strconv"
 	"termo_back_end/internal/entities"
 	"termo_back_end/internal/modules/repo"
 	"termo_back_end/internal/rules"
 	"termo_back_end/internal/status_codes"
 	"termo_back_end/internal/util"
+	"time"
 )
 
 type GameAttemptData struct {
-	Status    status_codes.GameAttempt
-	GameState []entities.GameWordState
-	Words     []string
-	Won       bool
+	Status            status_codes.GameAttempt
+	GameState         []entities.GameWordState
+	Words             []string
+	Won               bool
+	HardModeViolation *entities.HardModeViolation
 }
 
+type GameReplayData struct {
+	Status     status_codes.GameReplay
+	Game       *entities.Game
+	GameStates []entities.GameState
+	Words      []string
+}
+
+// gameAttemptRetries is how many times an attempt is checked against the game when other requests change it at the
+// same time
+const gameAttemptRetries = 3
+
+// errGameChanged is returned when the active game kept being changed by other requests while registering an attempt
+var errGameChanged = errors.New("gameService: game changed while registering the attempt")
+
+// Game history page sizes
+const (
+	gameHistoryDefaultLimit = 20
+	gameHistoryMaxLimit     = 50
+)
+
 type GameService interface {
 	// StartGame attempts to start a game for the provided user with the given configs
+	//
+	// Daily and challenge games can't be started through here, so the options' daily date and challenge are ignored
 	StartGame(
 		ctx context.Context,
 		user *entities.User,
 		wordLength uint32,
 		wordCount uint32,
+		options entities.GameOptions,
 	) (status_codes.GameStart, error)
 
+	// StartDailyGame attempts to start today's daily game variant with the given word count for the provided user
+	//
+	// Every user gets the same words for the same day and variant. Returns the day of the started game
+	StartDailyGame(
+		ctx context.Context,
+		user *entities.User,
+		wordCount uint32,
+		hardMode bool,
+	) (status_codes.GameStart, time.Time, error)
+
+	// StartChallengeGame attempts to start a game with the words of the challenge with the provided code for the
+	// provided user. Each user can only play a challenge once, and creators can't play the challenges they typed the
+	// words of
+	//
+	// Returns the challenge if succeeded
+	StartChallengeGame(
+		ctx context.Context,
+		user *entities.User,
+		code string,
+	) (status_codes.GameStart, *entities.Challenge, error)
+
 	// AttemptGame attempts to register an attempt on the current game of the provided user
 	AttemptGame(
 		ctx context.Context,
@@ -34,24 +85,76 @@ type GameService interface {
 		attempt string,
 	) (*GameAttemptData, error)
 
+	// ForfeitGame finishes the provided user's active game as abandoned
+	//
+	// Returns the game's original words if succeeded
+	ForfeitGame(
+		ctx context.Context,
+		user *entities.User,
+	) (status_codes.GameForfeit, []string, error)
+
 	// GetUserActiveGame attempts to find the provided user's active game; returns nil if no active game
 	GetUserActiveGame(
 		ctx context.Context,
 		user *entities.User,
 	) (*entities.Game, []entities.GameState, error)
+
+	// GetGameHistory returns a page of the provided user's finished games, from newest to oldest
+	//
+	// The cursor is an opaque string returned by a previous call, used to get the next page; an empty cursor returns
+	// the first page. The returned cursor is empty when there are no more pages
+	GetGameHistory(
+		ctx context.Context,
+		user *entities.User,
+		cursor string,
+		limit uint32,
+	) (status_codes.GameHistory, []entities.GameSummary, string, error)
+
+	// GetGameReplay returns every attempt of a finished game of the provided user, along with its original words
+	GetGameReplay(
+		ctx context.Context,
+		user *entities.User,
+		gameID int64,
+	) (*GameReplayData, error)
+
+	// ExportGames returns every game of the provided user for their data export
+	ExportGames(ctx context.Context, user *entities.User) ([]entities.GameExport, error)
+
+	// SubscribeEvents subscribes to the events of the provided user's games, such as the ones played on their other
+	// devices. Returns the events to be sent before the subscription's ones
+	//
+	// lastEventID is the ID of the last event the subscriber got before reconnecting, if any, so that the events they
+	// missed are returned
+	SubscribeEvents(user *entities.User, lastEventID string) (*util.EventSubscription, []entities.UserEvent)
 }
 
 type gameService struct {
-	wordMap  util.WordMap
-	repo     repo.GameRepository
-	userRepo repo.UserRepository
+	wordMap       util.WordMap
+	dailySecret   []byte
+	repo          repo.GameRepository
+	challengeRepo repo.ChallengeRepository
+	userRepo      repo.UserRepository
+	statsRepo     repo.StatsRepository
+	events        *util.EventBus
 }
 
-func NewGameService(words []string, repo repo.GameRepository, userRepo repo.UserRepository) GameService {
+func NewGameService(
+	config entities.Config,
+	wordMap util.WordMap,
+	repo repo.GameRepository,
+	challengeRepo repo.ChallengeRepository,
+	userRepo repo.UserRepository,
+	statsRepo repo.StatsRepository,
+	events *util.EventBus,
+) GameService {
 	return gameService{
-		wordMap:  util.WordMapFromList(words),
-		repo:     repo,
-		userRepo: userRepo,
+		wordMap:       wordMap,
+		dailySecret:   []byte(config.Game.DailySecret),
+		repo:          repo,
+		challengeRepo: challengeRepo,
+		userRepo:      userRepo,
+		statsRepo:     statsRepo,
+		events:        events,
 	}
 }
 
@@ -60,6 +163,7 @@ func (s gameService) StartGame(
 	user *entities.User,
 	wordLength uint32,
 	wordCount uint32,
+	options entities.GameOptions,
 ) (status_codes.GameStart, error) {
 	// Check if the user is already in a game
 	game, err := s.repo.GetUserActiveGame(ctx, user.ID)
@@ -72,11 +176,10 @@ func (s gameService) StartGame(
 	}
 
 	// Ensure valid configs
-	// TODO: Better way of handling this; hardcoded for now
-	if wordLength < 3 || wordLength > 22 {
+	if !rules.IsValidWordLength(wordLength) {
 		return status_codes.GameStartInvalidWordLength, nil
 	}
-	if wordCount == 0 || wordCount > 20 {
+	if !rules.IsValidWordCount(wordCount) {
 		return status_codes.GameStartInvalidCount, nil
 	}
 
@@ -93,14 +196,122 @@ func (s gameService) StartGame(
 	}
 
 	// Register game in the database
-	err = s.repo.StartGame(ctx, user.ID, words)
+	options.DailyDate = nil
+	options.ChallengeID = nil
+	err = s.repo.StartGame(ctx, user.ID, words, options)
 	if err != nil {
 		return -1, fmt.Errorf("[StartGame] | %v", err)
 	}
 
+	s.publishGameStarted(user.ID, words, options)
+
 	return status_codes.GameStartSuccess, nil
 }
 
+func (s gameService) StartDailyGame(
+	ctx context.Context,
+	user *entities.User,
+	wordCount uint32,
+	hardMode bool,
+) (status_codes.GameStart, time.Time, error) {
+	// Daily games follow the UTC calendar so every user shares the same day
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	if !rules.IsValidDailyWordCount(wordCount) {
+		return status_codes.GameStartInvalidCount, today, nil
+	}
+
+	// Check if the user is already in a game
+	game, err := s.repo.GetUserActiveGame(ctx, user.ID)
+	if err != nil {
+		return -1, today, fmt.Errorf("[GetUserActiveGame] | %v", err)
+	}
+
+	if game != nil {
+		return status_codes.GameStartActiveGame, today, nil
+	}
+
+	// Choose the day's words
+	words, err := s.wordMap.ChooseSeeded(rules.DailyWordLength, wordCount, s.dailySeed(today, wordCount))
+	if err != nil {
+		return -1, today, fmt.Errorf("[ChooseSeeded] | %v", err)
+	}
+
+	// Register game in the database
+	options := entities.GameOptions{
+		DailyDate: &today,
+		HardMode:  hardMode,
+	}
+	err = s.repo.StartGame(ctx, user.ID, words, options)
+	if err != nil {
+		if errors.Is(err, repo.ErrDailyAlreadyPlayed) {
+			return status_codes.GameStartDailyAlreadyPlayed, today, nil
+		}
+		return -1, today, fmt.Errorf("[StartGame] | %v", err)
+	}
+
+	s.publishGameStarted(user.ID, words, options)
+
+	return status_codes.GameStartSuccess, today, nil
+}
+
+func (s gameService) StartChallengeGame(
+	ctx context.Context,
+	user *entities.User,
+	code string,
+) (status_codes.GameStart, *entities.Challenge, error) {
+	// Check if the user is already in a game
+	game, err := s.repo.GetUserActiveGame(ctx, user.ID)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[GetUserActiveGame] | %v", err)
+	}
+
+	if game != nil {
+		return status_codes.GameStartActiveGame, nil, nil
+	}
+
+	challenge, err := s.challengeRepo.GetChallengeByCode(ctx, code)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[GetChallengeByCode] | %v", err)
+	}
+
+	if challenge == nil {
+		return status_codes.GameStartChallengeNotFound, nil, nil
+	}
+
+	// Creators already know the words they typed
+	if challenge.CustomWords && challenge.CreatorID == user.ID {
+		return status_codes.GameStartOwnChallenge, nil, nil
+	}
+
+	// Register game in the database. Whoever was given custom words can win right away, so such games don't count
+	options := entities.GameOptions{
+		Unranked:    challenge.CustomWords,
+		HardMode:    challenge.HardMode,
+		ChallengeID: &challenge.ID,
+	}
+	err = s.repo.StartGame(ctx, user.ID, challenge.Words, options)
+	if err != nil {
+		if errors.Is(err, repo.ErrChallengeAlreadyPlayed) {
+			return status_codes.GameStartChallengeAlreadyPlayed, nil, nil
+		}
+		return -1, nil, fmt.Errorf("[StartGame] | %v", err)
+	}
+
+	s.publishGameStarted(user.ID, challenge.Words, options)
+
+	return status_codes.GameStartSuccess, challenge, nil
+}
+
+// dailySeed derives the seed used to choose the words of a daily game variant from the server secret, so the words
+// can't be predicted from the date alone
+func (s gameService) dailySeed(day time.Time, wordCount uint32) int64 {
+	mac := hmac.New(sha256.New, s.dailySecret)
+	_, _ = fmt.Fprintf(mac, "%s:%d", day.Format(entities.DailyDateFormat), wordCount)
+	return int64(binary.BigEndian.Uint64(mac.Sum(nil)[:8]))
+}
+
 func (s gameService) AttemptGame(
 	ctx context.Context,
 	user *entities.User,
@@ -109,6 +320,28 @@ func (s gameService) AttemptGame(
 	// Clean attempt
 	attempt = s.wordMap.CleanWord(attempt)
 
+	// Attempts are checked against the game as it was read, so check again if another request changed it meanwhile
+	for range gameAttemptRetries {
+		data, err := s.attemptActiveGame(ctx, user, attempt)
+		if err != nil {
+			return nil, fmt.Errorf("[attemptActiveGame] | %v", err)
+		}
+
+		if data != nil {
+			return data, nil
+		}
+	}
+
+	return nil, errGameChanged
+}
+
+// attemptActiveGame registers an attempt on the active game of the provided user. Returns nil if the game changed
+// since it was read, in which case nothing was registered
+func (s gameService) attemptActiveGame(
+	ctx context.Context,
+	user *entities.User,
+	attempt string,
+) (*GameAttemptData, error) {
 	// Ensure the user is already in a game
 	game, err := s.repo.GetUserActiveGame(ctx, user.ID)
 	if err != nil {
@@ -128,43 +361,83 @@ func (s gameService) AttemptGame(
 		}, nil
 	}
 
+	// Ensure the attempt is a real word, unless the game accepts anything
+	if !game.AllowFreeGuesses {
+		if _, ok := s.wordMap.GetOriginalWord(attempt); !ok {
+			return &GameAttemptData{
+				Status: status_codes.GameAttemptNotInDictionary,
+			}, nil
+		}
+	}
+
+	// Ensure the attempt reuses every revealed hint in hard mode
+	if game.HardMode {
+		if violation := rules.CheckHardMode(*game, attempt); violation != nil {
+			return &GameAttemptData{
+				Status:            status_codes.GameAttemptHardModeViolation,
+				HardModeViolation: violation,
+			}, nil
+		}
+	}
+
 	// Check what's right and what's wrong
 	gameState := rules.CheckGameAttempt(*game, attempt)
 	currentAttempts := uint32(len(game.Attempts))
 	maxAttempts := rules.GetGameMaxAttempts(game.GetWordLength(), game.GetWordCount())
 
-	// Register attempt in database
-	err = s.repo.RegisterAttempt(ctx, game.ID, attempt, currentAttempts, currentAttempts >= maxAttempts-1)
+	// Check whether this attempt finishes the game
+	won := rules.IsGameWon(*game, attempt)
+
+	var result *entities.GameResult
+	if won {
+		result = new(entities.GameResult)
+		*result = entities.GameResultWon
+	} else if currentAttempts >= maxAttempts-1 {
+		result = new(entities.GameResult)
+		*result = entities.GameResultLost
+	}
+
+	// Register attempt in database. Only the request that registers it goes on, so that the game's result is only
+	// counted once
+	registered, err := s.repo.RegisterAttempt(ctx, game.ID, attempt, currentAttempts, result)
 	if err != nil {
 		return nil, fmt.Errorf("[RegisterAttempt] | %v", err)
 	}
 
-	// If all words are correct, increment the user's score
-	won := rules.IsGameWon(*game, attempt)
-	if won {
+	if !registered {
+		return nil, nil
+	}
+
+	// If all words are correct, increment the user's score. Practice and other unranked games don't count
+	if won && game.IsRanked() {
 		err = s.userRepo.IncrementScore(ctx, user.ID)
 		if err != nil {
 			return nil, fmt.Errorf("[IncrementScore] | %v", err)
 		}
+	}
 
-		err = s.repo.FinishGame(ctx, game.ID)
+	if result != nil {
+		err = s.recordGameStats(ctx, *game, *result, currentAttempts+1)
 		if err != nil {
-			return nil, fmt.Errorf("[FinishGame] | %v", err)
+			return nil, fmt.Errorf("[recordGameStats] | %v", err)
 		}
 	}
 
 	var words []string
-	if currentAttempts >= maxAttempts-1 || won {
+	if result != nil {
 		// Player either won or lost; show actual words
-		for _, word := range game.Words {
-			original, ok := s.wordMap.GetOriginalWord(word)
+		words = s.getOriginalWords(*game)
+	}
 
-			if ok {
-				words = append(words, original)
-			} else {
-				words = append(words, word)
-			}
-		}
+	s.events.Publish(user.ID, entities.UserEventGameAttempt, entities.GameAttemptEvent{
+		Attempt:   attempt,
+		GameState: gameState,
+	})
+	if result != nil {
+		s.events.Publish(user.ID, entities.UserEventGameFinished, entities.GameFinishedEvent{
+			Result: *result,
+			Words:  words,
+		})
 	}
 
 	return &GameAttemptData{
@@ -175,6 +448,44 @@ func (s gameService) AttemptGame(
 	}, nil
 }
 
+func (s gameService) ForfeitGame(
+	ctx context.Context,
+	user *entities.User,
+) (status_codes.GameForfeit, []string, error) {
+	// Ensure the user is in a game
+	game, err := s.repo.GetUserActiveGame(ctx, user.ID)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[GetUserActiveGame] | %v", err)
+	}
+
+	if game == nil {
+		return status_codes.GameForfeitNoActiveGame, nil, nil
+	}
+
+	// Only the request that finishes the game counts it in the stats
+	finished, err := s.repo.FinishGame(ctx, game.ID, entities.GameResultAbandoned)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[FinishGame] | %v", err)
+	}
+
+	if !finished {
+		return status_codes.GameForfeitNoActiveGame, nil, nil
+	}
+
+	err = s.recordGameStats(ctx, *game, entities.GameResultAbandoned, uint32(len(game.Attempts)))
+	if err != nil {
+		return -1, nil, fmt.Errorf("[recordGameStats] | %v", err)
+	}
+
+	words := s.getOriginalWords(*game)
+	s.events.Publish(user.ID, entities.UserEventGameFinished, entities.GameFinishedEvent{
+		Result: entities.GameResultAbandoned,
+		Words:  words,
+	})
+
+	return status_codes.GameForfeitSuccess, words, nil
+}
+
 func (s gameService) GetUserActiveGame(
 	ctx context.Context,
 	user *entities.User,
@@ -197,3 +508,207 @@ func (s gameService) GetUserActiveGame(
 
 	return game, statuses, nil
 }
+
+func (s gameService) GetGameHistory(
+	ctx context.Context,
+	user *entities.User,
+	cursor string,
+	limit uint32,
+) (status_codes.GameHistory, []entities.GameSummary, string, error) {
+	var beforeID int64
+	if cursor != "" {
+		var ok bool
+		beforeID, ok = decodeIDCursor(cursor)
+		if !ok {
+			return status_codes.GameHistoryInvalidCursor, nil, "", nil
+		}
+	}
+
+	if limit == 0 {
+		limit = gameHistoryDefaultLimit
+	}
+	limit = min(limit, gameHistoryMaxLimit)
+
+	// Get one more game than requested to know whether there is a next page
+	games, err := s.repo.GetUserFinishedGames(ctx, user.ID, beforeID, limit+1)
+	if err != nil {
+		return -1, nil, "", fmt.Errorf("[GetUserFinishedGames] | %v", err)
+	}
+
+	var nextCursor string
+	if uint32(len(games)) > limit {
+		games = games[:limit]
+		nextCursor = encodeIDCursor(games[len(games)-1].ID)
+	}
+
+	return status_codes.GameHistorySuccess, games, nextCursor, nil
+}
+
+func (s gameService) GetGameReplay(
+	ctx context.Context,
+	user *entities.User,
+	gameID int64,
+) (*GameReplayData, error) {
+	game, err := s.repo.GetUserGame(ctx, user.ID, gameID)
+	if err != nil {
+		return nil, fmt.Errorf("[GetUserGame] | %v", err)
+	}
+
+	if game == nil {
+		return &GameReplayData{
+			Status: status_codes.GameReplayNotFound,
+		}, nil
+	}
+
+	// Active games can't be replayed, otherwise their words would be revealed
+	if game.IsActive {
+		return &GameReplayData{
+			Status: status_codes.GameReplayInProgress,
+		}, nil
+	}
+
+	// Get status for each attempt
+	statuses := make([]entities.GameState, len(game.Attempts))
+	for i, attempt := range game.Attempts {
+		statuses[i] = rules.CheckGameAttempt(*game, attempt)
+	}
+
+	return &GameReplayData{
+		Status:     status_codes.GameReplaySuccess,
+		Game:       game,
+		GameStates: statuses,
+		Words:      s.getOriginalWords(*game),
+	}, nil
+}
+
+func (s gameService) ExportGames(ctx context.Context, user *entities.User) ([]entities.GameExport, error) {
+	games, err := s.repo.GetUserGames(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("[GetUserGames] | %v", err)
+	}
+
+	exports := make([]entities.GameExport, len(games))
+	for i, game := range games {
+		attempts := make([]entities.GameExportAttempt, len(game.Attempts))
+		for j, attempt := range game.Attempts {
+			attempts[j] = entities.GameExportAttempt{
+				Attempt:   attempt,
+				CreatedAt: game.AttemptTimes[j],
+			}
+		}
+
+		exports[i] = entities.GameExport{
+			ID:               game.ID,
+			WordLength:       game.GetWordLength(),
+			WordCount:        game.GetWordCount(),
+			Attempts:         attempts,
+			Result:           game.Result,
+			AllowFreeGuesses: game.AllowFreeGuesses,
+			Unranked:         game.Unranked,
+			HardMode:         game.HardMode,
+			VersusMatchID:    game.VersusMatchID,
+			ChallengeID:      game.ChallengeID,
+			StartedAt:        game.StartedAt,
+			FinishedAt:       game.FinishedAt,
+		}
+
+		// Active games are exported without their words, otherwise they would be revealed
+		if !game.IsActive {
+			exports[i].Words = s.getOriginalWords(game)
+		}
+		if game.DailyDate != nil {
+			exports[i].DailyDate = game.DailyDate.Format(entities.DailyDateFormat)
+		}
+	}
+
+	return exports, nil
+}
+
+func (s gameService) SubscribeEvents(
+	user *entities.User,
+	lastEventID string,
+) (*util.EventSubscription, []entities.UserEvent) {
+	if lastEventID == "" {
+		return s.events.Subscribe(user.ID, 0, false)
+	}
+
+	// Unreadable IDs can't be resumed from, so the subscriber is told to reload everything
+	id, _ := strconv.ParseUint(lastEventID, 10, 64)
+	return s.events.Subscribe(user.ID, id, true)
+}
+
+// publishGameStarted sends a game that was just started to the user's subscribers, like the active game endpoint
+func (s gameService) publishGameStarted(userID int64, words []string, options entities.GameOptions) {
+	game := entities.Game{
+		Words:            words,
+		Attempts:         make([]string, 0),
+		DailyDate:        options.DailyDate,
+		AllowFreeGuesses: options.AllowFreeGuesses,
+		Unranked:         options.Unranked,
+		HardMode:         options.HardMode,
+		StartedAt:        time.Now().UTC(),
+	}
+
+	maxAttempts := rules.GetGameMaxAttempts(game.GetWordLength(), game.GetWordCount())
+	s.events.Publish(userID, entities.UserEventGameStarted, game.ToResponse(make([]entities.GameState, 0), maxAttempts))
+}
+
+// encodeIDCursor builds the opaque cursor pointing to the items older than the one with the provided ID
+func encodeIDCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// decodeIDCursor extracts the ID from a cursor built by encodeIDCursor
+func decodeIDCursor(cursor string) (int64, bool) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, false
+	}
+
+	id, err := strconv.ParseInt(string(decoded), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+
+	return id, true
+}
+
+// recordGameStats updates the stats of the game's user with its result. Unranked games, such as practice games, which
+// accept any guess, are left out of the stats
+func (s gameService) recordGameStats(
+	ctx context.Context,
+	game entities.Game,
+	result entities.GameResult,
+	attempts uint32,
+) error {
+	if !game.IsRanked() {
+		return nil
+	}
+
+	return s.statsRepo.RecordGame(
+		ctx,
+		game.UserID,
+		game.GetWordLength(),
+		game.GetWordCount(),
+		result,
+		attempts,
+		game.HardMode,
+		game.DailyDate,
+	)
+}
+
+// getOriginalWords returns the game words as they are in the word list, with diacritics
+func (s gameService) getOriginalWords(game entities.Game) []string {
+	words := make([]string, len(game.Words))
+	for i, word := range game.Words {
+		original, ok := s.wordMap.GetOriginalWord(word)
+
+		if ok {
+			words[i] = original
+		} else {
+			words[i] = word
+		}
+	}
+
+	return words
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/modules/repo"
+	"termo_back_end/internal/rules"
+	"termo_back_end/internal/status_codes"
+	"time"
+)
+
+// Leaderboard page sizes
+const (
+	leaderboardDefaultLimit = 20
+	leaderboardMaxLimit     = 100
+)
+
+// leaderboardDefaultMinGames is used when the config doesn't set the minimum games to be ranked
+const leaderboardDefaultMinGames = 10
+
+type LeaderboardService interface {
+	// GetLeaderboard returns a page of the leaderboard of the provided kind, always including the provided user's entry
+	// if they are ranked
+	//
+	// Word length and word count filter the counted games when not 0. For the daily window, the word length is always
+	// the daily one
+	GetLeaderboard(
+		ctx context.Context,
+		user *entities.User,
+		kind entities.LeaderboardKind,
+		window entities.LeaderboardWindow,
+		wordLength uint32,
+		wordCount uint32,
+		page uint32,
+		limit uint32,
+	) (status_codes.LeaderboardGet, *entities.Leaderboard, error)
+}
+
+type leaderboardService struct {
+	minGames uint32
+	repo     repo.LeaderboardRepository
+}
+
+func NewLeaderboardService(config entities.Config, repo repo.LeaderboardRepository) LeaderboardService {
+	minGames := config.Leaderboard.MinGames
+	if minGames == 0 {
+		minGames = leaderboardDefaultMinGames
+	}
+
+	return leaderboardService{
+		minGames: minGames,
+		repo:     repo,
+	}
+}
+
+func (s leaderboardService) GetLeaderboard(
+	ctx context.Context,
+	user *entities.User,
+	kind entities.LeaderboardKind,
+	window entities.LeaderboardWindow,
+	wordLength uint32,
+	wordCount uint32,
+	page uint32,
+	limit uint32,
+) (status_codes.LeaderboardGet, *entities.Leaderboard, error) {
+	filter := entities.LeaderboardFilter{
+		WordLength: wordLength,
+		WordCount:  wordCount,
+		MinGames:   s.minGames,
+	}
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch window {
+	case entities.LeaderboardWindowAll, "":
+	case entities.LeaderboardWindowWeek:
+		// Weeks start on Monday
+		daysSinceMonday := (int(today.Weekday()) + 6) % 7
+		filter.FinishedAfter = today.AddDate(0, 0, -daysSinceMonday)
+	case entities.LeaderboardWindowDaily:
+		filter.DailyDate = &today
+		filter.WordLength = rules.DailyWordLength
+		// Each daily variant is played only once, so any user who played is ranked
+		filter.MinGames = 1
+	default:
+		return status_codes.LeaderboardGetInvalidWindow, nil, nil
+	}
+
+	if limit == 0 {
+		limit = leaderboardDefaultLimit
+	}
+	limit = min(limit, leaderboardMaxLimit)
+
+	// The offset is computed in 64 bits, so that large pages can't wrap around to another page
+	var offset uint64
+	if page > 0 {
+		offset = uint64(page-1) * uint64(limit)
+	}
+
+	entries, own, total, err := s.repo.GetLeaderboard(ctx, kind, filter, user.ID, limit, offset)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[GetLeaderboard] | %v", err)
+	}
+
+	return status_codes.LeaderboardGetSuccess, &entities.Leaderboard{
+		Entries: entries,
+		Own:     own,
+		Total:   total,
+	}, nil
+}
@@ -0,0 +1,928 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/rules"
+	"termo_back_end/internal/status_codes"
+	"termo_back_end/internal/util"
+	"time"
+)
+
+// Lobby player limits
+const (
+	lobbyMinPlayers = 2
+	lobbyMaxPlayers = 8
+)
+
+// Lobby round time limits, in seconds
+const (
+	lobbyMinRoundSeconds     = 30
+	lobbyMaxRoundSeconds     = 30 * 60
+	lobbyDefaultRoundSeconds = 5 * 60
+)
+
+// Lobby codes are made of characters that can't be mistaken for each other
+const (
+	lobbyCodeLength   = 6
+	lobbyCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// defaultLobbyIdleMinutes is how long a lobby can go without any change before being deleted, if not configured
+const defaultLobbyIdleMinutes = 30
+
+// lobbyMaxRounds is how many finished rounds a lobby keeps; older ones are dropped, but still count in the scoreboard
+const lobbyMaxRounds = 20
+
+// lobbySyncLookback is how far before its previous run SyncLobbies looks for changes, so that changes aren't missed
+// because of the clocks of the instances being apart or the database rounding times to the second
+const lobbySyncLookback = 10 * time.Second
+
+// lobbyChangeRetries is how many times a lobby change is attempted when others change the lobby at the same time
+const lobbyChangeRetries = 5
+
+type LobbyAttemptData struct {
+	Status    status_codes.LobbyAttempt
+	GameState entities.GameState
+
+	// Result and Words, the original round words, are only set once the attempt finishes the player's game
+	Result *entities.GameResult
+	Words  []string
+}
+
+type LobbyService interface {
+	// CreateLobby creates a private lobby hosted by the provided user, who joins it right away. Others join it through
+	// its code
+	CreateLobby(
+		ctx context.Context,
+		user *entities.User,
+		settings entities.LobbySettings,
+	) (status_codes.LobbySettings, *entities.LobbyResponse, error)
+
+	// JoinLobby adds the provided user to the lobby with the provided code. Players who join during a round wait for
+	// the next one. Joining a lobby the user is already in does nothing
+	JoinLobby(
+		ctx context.Context,
+		user *entities.User,
+		code string,
+	) (status_codes.LobbyJoin, *entities.LobbyResponse, error)
+
+	// GetLobby returns the lobby with the provided code, as seen by the provided user. Only its players can see it
+	GetLobby(
+		ctx context.Context,
+		user *entities.User,
+		code string,
+	) (status_codes.LobbyGet, *entities.LobbyResponse, error)
+
+	// LeaveLobby removes the provided user from the lobby with the provided code. If they were the host, the player
+	// who joined the earliest becomes the host; lobbies everyone left are deleted
+	LeaveLobby(ctx context.Context, user *entities.User, code string) (status_codes.LobbyLeave, error)
+
+	// UpdateSettings changes the settings of the next rounds of a lobby. Host only, and only between rounds
+	UpdateSettings(
+		ctx context.Context,
+		user *entities.User,
+		code string,
+		settings entities.LobbySettings,
+	) (status_codes.LobbySettings, *entities.LobbyResponse, error)
+
+	// StartRound starts a round with new words for every player in the lobby. Host only
+	//
+	// The round ends once every player has won or lost, or once its time is up, at which point the players who haven't
+	// finished lose. Its results are then added to the lobby's rounds, which keep the latest ones
+	StartRound(
+		ctx context.Context,
+		user *entities.User,
+		code string,
+	) (status_codes.LobbyStart, *entities.LobbyResponse, error)
+
+	// AttemptRound registers an attempt on the provided user's game in the current round of a lobby. Winners score
+	// more points the fewer attempts they took
+	AttemptRound(ctx context.Context, user *entities.User, code string, attempt string) (*LobbyAttemptData, error)
+
+	// KickPlayer removes another player from a lobby. Host only
+	KickPlayer(
+		ctx context.Context,
+		user *entities.User,
+		code string,
+		playerID int64,
+	) (status_codes.LobbyHostAction, error)
+
+	// TransferHost makes another player of a lobby its host. Host only
+	TransferHost(
+		ctx context.Context,
+		user *entities.User,
+		code string,
+		playerID int64,
+	) (status_codes.LobbyHostAction, error)
+
+	// ExpireRounds ends the rounds whose time is up and sends their results to the players. Rounds are ended on time
+	// by the instance that started them; this catches the ones it missed, e.g. because it was restarted. Returns how
+	// many were ended
+	ExpireRounds(ctx context.Context) (int64, error)
+
+	// SyncLobbies sends the lobby changes made by other server instances to the players following their events on
+	// this one. Only needed when lobbies are shared through the database; does nothing otherwise
+	SyncLobbies(ctx context.Context) error
+
+	// DeleteIdleLobbies deletes the lobbies that went without any change for too long. Returns how many were deleted
+	DeleteIdleLobbies(ctx context.Context) (int64, error)
+}
+
+type lobbyService struct {
+	wordMap util.WordMap
+	store   util.LobbyStore
+	events  *util.EventBus
+
+	// sync is nil unless lobbies are shared with other instances
+	sync *lobbySync
+
+	idleTimeout time.Duration
+}
+
+// lobbySync remembers the latest version of each lobby that was sent to the players, so that SyncLobbies only sends
+// the changes made by other instances
+type lobbySync struct {
+	mu       sync.Mutex
+	lastSync time.Time
+	lobbies  map[string]syncedLobby
+}
+
+type syncedLobby struct {
+	version   int64
+	playerIDs []int64
+	updatedAt time.Time
+}
+
+// remember records that the provided version of a lobby was sent to its players. Returns the players of the version
+// sent before, and false if this version or a later one was already sent
+func (l *lobbySync) remember(lobby entities.Lobby) ([]int64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	previous, ok := l.lobbies[lobby.Code]
+	if ok && previous.version >= lobby.Version {
+		return nil, false
+	}
+
+	l.lobbies[lobby.Code] = syncedLobby{
+		version:   lobby.Version,
+		playerIDs: getPlayerIDs(lobby.Players),
+		updatedAt: lobby.UpdatedAt,
+	}
+	return previous.playerIDs, true
+}
+
+// forget drops a deleted lobby
+func (l *lobbySync) forget(code string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.lobbies, code)
+}
+
+// forgetIdle drops the lobbies that weren't changed since the provided time, which are deleted by then
+func (l *lobbySync) forgetIdle(before time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for code, lobby := range l.lobbies {
+		if lobby.updatedAt.Before(before) {
+			delete(l.lobbies, code)
+		}
+	}
+}
+
+func NewLobbyService(
+	config entities.Config,
+	wordMap util.WordMap,
+	store util.LobbyStore,
+	events *util.EventBus,
+) LobbyService {
+	idleMinutes := config.Lobby.IdleMinutes
+	if idleMinutes == 0 {
+		idleMinutes = defaultLobbyIdleMinutes
+	}
+
+	var lobbySyncState *lobbySync
+	if config.Lobby.Store == entities.LobbyStoreDatabase {
+		lobbySyncState = &lobbySync{
+			lobbies: make(map[string]syncedLobby),
+		}
+	}
+
+	return lobbyService{
+		wordMap:     wordMap,
+		store:       store,
+		events:      events,
+		sync:        lobbySyncState,
+		idleTimeout: time.Duration(idleMinutes) * time.Minute,
+	}
+}
+
+func (s lobbyService) CreateLobby(
+	ctx context.Context,
+	user *entities.User,
+	settings entities.LobbySettings,
+) (status_codes.LobbySettings, *entities.LobbyResponse, error) {
+	status, err := s.checkSettings(&settings)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[checkSettings] | %v", err)
+	}
+	if status != status_codes.LobbySettingsSuccess {
+		return status, nil, nil
+	}
+
+	now := time.Now()
+	lobby := entities.Lobby{
+		HostID:   user.ID,
+		Settings: settings,
+		State:    entities.LobbyStateWaiting,
+		Players: []entities.LobbyPlayer{
+			{
+				ID:       user.ID,
+				Name:     user.Name,
+				JoinedAt: now,
+			},
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	// Codes are short enough to be typed, so pick another one if it's taken
+	for {
+		lobby.Code, err = util.GenerateRandomCode(lobbyCodeLength, lobbyCodeAlphabet)
+		if err != nil {
+			return -1, nil, fmt.Errorf("[GenerateRandomCode] | %v", err)
+		}
+
+		err = s.store.CreateLobby(ctx, lobby)
+		if errors.Is(err, util.ErrLobbyExists) {
+			continue
+		}
+		if err != nil {
+			return -1, nil, fmt.Errorf("[CreateLobby] | %v", err)
+		}
+
+		break
+	}
+
+	response := s.toResponse(lobby, user.ID)
+	return status_codes.LobbySettingsSuccess, &response, nil
+}
+
+func (s lobbyService) JoinLobby(
+	ctx context.Context,
+	user *entities.User,
+	code string,
+) (status_codes.LobbyJoin, *entities.LobbyResponse, error) {
+	var status status_codes.LobbyJoin
+	lobby, err := s.change(ctx, code, func(lobby *entities.Lobby, now time.Time) (bool, error) {
+		status = status_codes.LobbyJoinSuccess
+		if lobby.FindPlayer(user.ID) != -1 {
+			return false, nil
+		}
+
+		if uint32(len(lobby.Players)) >= lobby.Settings.MaxPlayers {
+			status = status_codes.LobbyJoinFull
+			return false, nil
+		}
+
+		lobby.Players = append(lobby.Players, entities.LobbyPlayer{
+			ID:       user.ID,
+			Name:     user.Name,
+			JoinedAt: now,
+		})
+		return true, nil
+	})
+	if err != nil {
+		return -1, nil, fmt.Errorf("[change] | %v", err)
+	}
+
+	if lobby == nil {
+		return status_codes.LobbyJoinNotFound, nil, nil
+	}
+	if status != status_codes.LobbyJoinSuccess {
+		return status, nil, nil
+	}
+
+	response := s.toResponse(*lobby, user.ID)
+	return status, &response, nil
+}
+
+func (s lobbyService) GetLobby(
+	ctx context.Context,
+	user *entities.User,
+	code string,
+) (status_codes.LobbyGet, *entities.LobbyResponse, error) {
+	// Going through change ends the round if its time is up
+	lobby, err := s.change(ctx, code, func(lobby *entities.Lobby, now time.Time) (bool, error) {
+		return false, nil
+	})
+	if err != nil {
+		return -1, nil, fmt.Errorf("[change] | %v", err)
+	}
+
+	// Lobbies are private, so outsiders can't tell whether they exist
+	if lobby == nil || lobby.FindPlayer(user.ID) == -1 {
+		return status_codes.LobbyGetNotFound, nil, nil
+	}
+
+	response := s.toResponse(*lobby, user.ID)
+	return status_codes.LobbyGetSuccess, &response, nil
+}
+
+func (s lobbyService) LeaveLobby(
+	ctx context.Context,
+	user *entities.User,
+	code string,
+) (status_codes.LobbyLeave, error) {
+	var found bool
+	_, err := s.change(ctx, code, func(lobby *entities.Lobby, now time.Time) (bool, error) {
+		found = s.removePlayer(lobby, user.ID)
+		return found, nil
+	})
+	if err != nil {
+		return -1, fmt.Errorf("[change] | %v", err)
+	}
+
+	if !found {
+		return status_codes.LobbyLeaveNotFound, nil
+	}
+
+	return status_codes.LobbyLeaveSuccess, nil
+}
+
+func (s lobbyService) UpdateSettings(
+	ctx context.Context,
+	user *entities.User,
+	code string,
+	settings entities.LobbySettings,
+) (status_codes.LobbySettings, *entities.LobbyResponse, error) {
+	status, err := s.checkSettings(&settings)
+	if err != nil {
+		return -1, nil, fmt.Errorf("[checkSettings] | %v", err)
+	}
+	if status != status_codes.LobbySettingsSuccess {
+		return status, nil, nil
+	}
+
+	lobby, err := s.change(ctx, code, func(lobby *entities.Lobby, now time.Time) (bool, error) {
+		switch {
+		case lobby.FindPlayer(user.ID) == -1:
+			status = status_codes.LobbySettingsNotFound
+		case lobby.HostID != user.ID:
+			status = status_codes.LobbySettingsNotHost
+		case lobby.State != entities.LobbyStateWaiting:
+			status = status_codes.LobbySettingsRoundInProgress
+		case uint32(len(lobby.Players)) > settings.MaxPlayers:
+			// Players aren't removed to make room; the host has to kick them first
+			status = status_codes.LobbySettingsInvalidMaxPlayers
+		default:
+			status = status_codes.LobbySettingsSuccess
+			lobby.Settings = settings
+			return true, nil
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return -1, nil, fmt.Errorf("[change] | %v", err)
+	}
+
+	if lobby == nil {
+		return status_codes.LobbySettingsNotFound, nil, nil
+	}
+	if status != status_codes.LobbySettingsSuccess {
+		return status, nil, nil
+	}
+
+	response := s.toResponse(*lobby, user.ID)
+	return status, &response, nil
+}
+
+// checkSettings ensures the settings picked by a host are valid, filling in the ones that weren't picked
+func (s lobbyService) checkSettings(settings *entities.LobbySettings) (status_codes.LobbySettings, error) {
+	if !rules.IsValidWordLength(settings.WordLength) {
+		return status_codes.LobbySettingsInvalidWordLength, nil
+	}
+	if !rules.IsValidWordCount(settings.WordCount) {
+		return status_codes.LobbySettingsInvalidCount, nil
+	}
+
+	if settings.MaxPlayers == 0 {
+		settings.MaxPlayers = lobbyMaxPlayers
+	}
+	if settings.MaxPlayers < lobbyMinPlayers || settings.MaxPlayers > lobbyMaxPlayers {
+		return status_codes.LobbySettingsInvalidMaxPlayers, nil
+	}
+
+	if settings.RoundSeconds == 0 {
+		settings.RoundSeconds = lobbyDefaultRoundSeconds
+	}
+	if settings.RoundSeconds < lobbyMinRoundSeconds || settings.RoundSeconds > lobbyMaxRoundSeconds {
+		return status_codes.LobbySettingsInvalidRoundTime, nil
+	}
+
+	// Ensure there are enough words, so that rounds can start with these settings
+	_, err := s.wordMap.ChooseRandom(settings.WordLength, settings.WordCount)
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidSize) {
+			return status_codes.LobbySettingsInvalidWordLength, nil
+		}
+		if errors.Is(err, util.ErrNotEnoughWords) {
+			return status_codes.LobbySettingsInvalidCount, nil
+		}
+		return -1, fmt.Errorf("[ChooseRandom] | %v", err)
+	}
+
+	return status_codes.LobbySettingsSuccess, nil
+}
+
+func (s lobbyService) StartRound(
+	ctx context.Context,
+	user *entities.User,
+	code string,
+) (status_codes.LobbyStart, *entities.LobbyResponse, error) {
+	var status status_codes.LobbyStart
+	lobby, err := s.change(ctx, code, func(lobby *entities.Lobby, now time.Time) (bool, error) {
+		switch {
+		case lobby.FindPlayer(user.ID) == -1:
+			status = status_codes.LobbyStartNotFound
+			return false, nil
+		case lobby.HostID != user.ID:
+			status = status_codes.LobbyStartNotHost
+			return false, nil
+		case lobby.State != entities.LobbyStateWaiting:
+			status = status_codes.LobbyStartRoundInProgress
+			return false, nil
+		}
+
+		words, err := s.wordMap.ChooseRandom(lobby.Settings.WordLength, lobby.Settings.WordCount)
+		if err != nil {
+			return false, fmt.Errorf("[ChooseRandom] | %v", err)
+		}
+
+		status = status_codes.LobbyStartSuccess
+		lobby.State = entities.LobbyStatePlaying
+		lobby.Round++
+		lobby.Words = words
+		lobby.RoundStartedAt = now
+		lobby.RoundEndsAt = now.Add(time.Duration(lobby.Settings.RoundSeconds) * time.Second)
+
+		for i := range lobby.Players {
+			lobby.Players[i].Playing = true
+			lobby.Players[i].Attempts = nil
+			lobby.Players[i].GameStates = nil
+			lobby.Players[i].Result = nil
+			lobby.Players[i].Points = 0
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return -1, nil, fmt.Errorf("[change] | %v", err)
+	}
+
+	if lobby == nil {
+		return status_codes.LobbyStartNotFound, nil, nil
+	}
+	if status != status_codes.LobbyStartSuccess {
+		return status, nil, nil
+	}
+
+	// End the round once its time is up, even if nobody changes the lobby by then
+	time.AfterFunc(time.Until(lobby.RoundEndsAt), func() {
+		err := s.endExpiredRound(context.Background(), code)
+		if err != nil {
+			log.Printf("[endExpiredRound] | %v", err)
+		}
+	})
+
+	response := s.toResponse(*lobby, user.ID)
+	return status, &response, nil
+}
+
+func (s lobbyService) AttemptRound(
+	ctx context.Context,
+	user *entities.User,
+	code string,
+	attempt string,
+) (*LobbyAttemptData, error) {
+	attempt = s.wordMap.CleanWord(attempt)
+
+	var data *LobbyAttemptData
+	lobby, err := s.change(ctx, code, func(lobby *entities.Lobby, now time.Time) (bool, error) {
+		i := lobby.FindPlayer(user.ID)
+		if i == -1 {
+			data = &LobbyAttemptData{Status: status_codes.LobbyAttemptNotFound}
+			return false, nil
+		}
+
+		player := &lobby.Players[i]
+		if lobby.State != entities.LobbyStatePlaying || !player.Playing || player.Result != nil {
+			data = &LobbyAttemptData{Status: status_codes.LobbyAttemptNotPlaying}
+			return false, nil
+		}
+
+		// Ensure the attempt is a valid real word
+		if uint32(len(attempt)) != lobby.Settings.WordLength {
+			data = &LobbyAttemptData{Status: status_codes.LobbyAttemptInvalid}
+			return false, nil
+		}
+
+		if _, ok := s.wordMap.GetOriginalWord(attempt); !ok {
+			data = &LobbyAttemptData{Status: status_codes.LobbyAttemptNotInDictionary}
+			return false, nil
+		}
+
+		// Check what's right and what's wrong
+		game := entities.Game{Words: lobby.Words, Attempts: player.Attempts}
+		gameState := rules.CheckGameAttempt(game, attempt)
+		won := rules.IsGameWon(game, attempt)
+
+		player.Attempts = append(player.Attempts, attempt)
+		player.GameStates = append(player.GameStates, gameState)
+
+		data = &LobbyAttemptData{
+			Status:    status_codes.LobbyAttemptSuccess,
+			GameState: gameState,
+		}
+
+		maxAttempts := rules.GetGameMaxAttempts(lobby.Settings.WordLength, lobby.Settings.WordCount)
+		if won {
+			player.Result = new(entities.GameResult)
+			*player.Result = entities.GameResultWon
+
+			// Winners get a point for each attempt they had left, plus one for winning
+			player.Points = maxAttempts - uint32(len(player.Attempts)) + 1
+			player.Score += player.Points
+		} else if uint32(len(player.Attempts)) >= maxAttempts {
+			player.Result = new(entities.GameResult)
+			*player.Result = entities.GameResultLost
+		}
+
+		if player.Result != nil {
+			data.Result = player.Result
+			data.Words = s.getOriginalWords(lobby.Words)
+			s.finishRoundIfDone(lobby)
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[change] | %v", err)
+	}
+
+	if lobby == nil {
+		return &LobbyAttemptData{Status: status_codes.LobbyAttemptNotFound}, nil
+	}
+
+	return data, nil
+}
+
+func (s lobbyService) KickPlayer(
+	ctx context.Context,
+	user *entities.User,
+	code string,
+	playerID int64,
+) (status_codes.LobbyHostAction, error) {
+	var status status_codes.LobbyHostAction
+	lobby, err := s.change(ctx, code, func(lobby *entities.Lobby, now time.Time) (bool, error) {
+		status = s.checkHostAction(*lobby, user.ID, playerID)
+
+		// Hosts leave their lobby instead of kicking themselves
+		if status == status_codes.LobbyHostActionSuccess && playerID == user.ID {
+			status = status_codes.LobbyHostActionPlayerNotFound
+		}
+		if status != status_codes.LobbyHostActionSuccess {
+			return false, nil
+		}
+
+		s.removePlayer(lobby, playerID)
+		return true, nil
+	})
+	if err != nil {
+		return -1, fmt.Errorf("[change] | %v", err)
+	}
+
+	if lobby == nil {
+		return status_codes.LobbyHostActionNotFound, nil
+	}
+
+	return status, nil
+}
+
+func (s lobbyService) TransferHost(
+	ctx context.Context,
+	user *entities.User,
+	code string,
+	playerID int64,
+) (status_codes.LobbyHostAction, error) {
+	var status status_codes.LobbyHostAction
+	lobby, err := s.change(ctx, code, func(lobby *entities.Lobby, now time.Time) (bool, error) {
+		status = s.checkHostAction(*lobby, user.ID, playerID)
+		if status != status_codes.LobbyHostActionSuccess || playerID == user.ID {
+			return false, nil
+		}
+
+		lobby.HostID = playerID
+		return true, nil
+	})
+	if err != nil {
+		return -1, fmt.Errorf("[change] | %v", err)
+	}
+
+	if lobby == nil {
+		return status_codes.LobbyHostActionNotFound, nil
+	}
+
+	return status, nil
+}
+
+// checkHostAction ensures the provided user is the host of the lobby and that the player they picked is in it
+func (s lobbyService) checkHostAction(
+	lobby entities.Lobby,
+	userID int64,
+	playerID int64,
+) status_codes.LobbyHostAction {
+	switch {
+	case lobby.FindPlayer(userID) == -1:
+		return status_codes.LobbyHostActionNotFound
+	case lobby.HostID != userID:
+		return status_codes.LobbyHostActionNotHost
+	case lobby.FindPlayer(playerID) == -1:
+		return status_codes.LobbyHostActionPlayerNotFound
+	default:
+		return status_codes.LobbyHostActionSuccess
+	}
+}
+
+func (s lobbyService) ExpireRounds(ctx context.Context) (int64, error) {
+	codes, err := s.store.GetExpiredRounds(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("[GetExpiredRounds] | %v", err)
+	}
+
+	var expired int64
+	for _, code := range codes {
+		err = s.endExpiredRound(ctx, code)
+		if err != nil {
+			return expired, fmt.Errorf("[endExpiredRound] | %v", err)
+		}
+		expired++
+	}
+
+	return expired, nil
+}
+
+// endExpiredRound ends the round of the lobby with the provided code if its time is up, sending its results to the
+// players
+func (s lobbyService) endExpiredRound(ctx context.Context, code string) error {
+	// Going through change ends the round if its time is up
+	_, err := s.change(ctx, code, func(lobby *entities.Lobby, now time.Time) (bool, error) {
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("[change] | %v", err)
+	}
+
+	return nil
+}
+
+func (s lobbyService) SyncLobbies(ctx context.Context) error {
+	if s.sync == nil {
+		return nil
+	}
+
+	// Only one sync runs at a time, so lastSync isn't changed meanwhile
+	now := time.Now()
+	since := s.sync.lastSync
+	if since.IsZero() {
+		since = now
+	}
+
+	lobbies, err := s.store.GetChangedLobbies(ctx, since.Add(-lobbySyncLookback))
+	if err != nil {
+		return fmt.Errorf("[GetChangedLobbies] | %v", err)
+	}
+
+	for _, lobby := range lobbies {
+		previousPlayerIDs, sent := s.sync.remember(lobby)
+
+		// Lobbies that were just created aren't sent, like on the instance that created them
+		if !sent || lobby.Version == 1 {
+			continue
+		}
+
+		s.publishLobby(lobby, previousPlayerIDs, true)
+	}
+
+	s.sync.lastSync = now
+	s.sync.forgetIdle(now.Add(-s.idleTimeout))
+
+	return nil
+}
+
+func (s lobbyService) DeleteIdleLobbies(ctx context.Context) (int64, error) {
+	deleted, err := s.store.DeleteIdleLobbies(ctx, time.Now().Add(-s.idleTimeout))
+	if err != nil {
+		return 0, fmt.Errorf("[DeleteIdleLobbies] | %v", err)
+	}
+
+	return deleted, nil
+}
+
+// change reads the lobby with the provided code and passes it to apply, storing it if apply changed it and then
+// sending it to its players. Rounds whose time is up are ended before apply is called, and lobbies left without players
+// are deleted
+//
+// apply is called again with a fresh copy of the lobby whenever someone else changed it in the meantime, so it must
+// not keep anything from previous calls. Returns the lobby as it was stored; returns nil if not found
+func (s lobbyService) change(
+	ctx context.Context,
+	code string,
+	apply func(lobby *entities.Lobby, now time.Time) (bool, error),
+) (*entities.Lobby, error) {
+	for range lobbyChangeRetries {
+		lobby, err := s.store.GetLobby(ctx, code)
+		if err != nil {
+			return nil, fmt.Errorf("[GetLobby] | %v", err)
+		}
+
+		if lobby == nil {
+			return nil, nil
+		}
+
+		previousPlayerIDs := getPlayerIDs(lobby.Players)
+
+		now := time.Now()
+		changed := s.expireRound(lobby, now)
+
+		applied, err := apply(lobby, now)
+		if err != nil {
+			return nil, fmt.Errorf("[apply] | %v", err)
+		}
+
+		if !changed && !applied {
+			return lobby, nil
+		}
+
+		lobby.UpdatedAt = now
+		if len(lobby.Players) == 0 {
+			err = s.store.DeleteLobby(ctx, lobby.Code, lobby.Version)
+			if err != nil && !errors.Is(err, util.ErrLobbyConflict) {
+				return nil, fmt.Errorf("[DeleteLobby] | %v", err)
+			}
+		} else {
+			err = s.store.UpdateLobby(ctx, *lobby)
+			if err != nil && !errors.Is(err, util.ErrLobbyConflict) {
+				return nil, fmt.Errorf("[UpdateLobby] | %v", err)
+			}
+		}
+
+		// Someone else changed the lobby since it was read, so apply the change to their version
+		if err != nil {
+			continue
+		}
+
+		lobby.Version++
+		if s.sync != nil {
+			if len(lobby.Players) == 0 {
+				s.sync.forget(lobby.Code)
+			} else {
+				s.sync.remember(*lobby)
+			}
+		}
+
+		s.publishLobby(*lobby, previousPlayerIDs, false)
+		return lobby, nil
+	}
+
+	return nil, util.ErrLobbyConflict
+}
+
+// removePlayer removes a player from the lobby, ending the round if everyone left in it has finished. If the player
+// was the host, the player who joined the earliest becomes the host. Returns false if they are not in the lobby
+func (s lobbyService) removePlayer(lobby *entities.Lobby, userID int64) bool {
+	i := lobby.FindPlayer(userID)
+	if i == -1 {
+		return false
+	}
+
+	lobby.Players = slices.Delete(lobby.Players, i, i+1)
+
+	// Players are kept in the order they joined
+	if lobby.HostID == userID && len(lobby.Players) > 0 {
+		lobby.HostID = lobby.Players[0].ID
+	}
+
+	s.finishRoundIfDone(lobby)
+	return true
+}
+
+// expireRound ends the lobby's round if its time is up; the players who haven't finished lose it. Returns whether
+// the round was ended
+func (s lobbyService) expireRound(lobby *entities.Lobby, now time.Time) bool {
+	if lobby.State != entities.LobbyStatePlaying || now.Before(lobby.RoundEndsAt) {
+		return false
+	}
+
+	for i, player := range lobby.Players {
+		if player.Playing && player.Result == nil {
+			lobby.Players[i].Result = new(entities.GameResult)
+			*lobby.Players[i].Result = entities.GameResultExpired
+		}
+	}
+
+	s.finishRoundIfDone(lobby)
+	return true
+}
+
+// finishRoundIfDone ends the lobby's round once every player in it has won or lost, adding its results to the
+// lobby's rounds. Only the latest lobbyMaxRounds rounds are kept, since the lobby is stored whole on every change
+func (s lobbyService) finishRoundIfDone(lobby *entities.Lobby) {
+	if lobby.State != entities.LobbyStatePlaying {
+		return
+	}
+
+	round := entities.LobbyRound{
+		Number:  lobby.Round,
+		Words:   s.getOriginalWords(lobby.Words),
+		Results: make([]entities.LobbyRoundResult, 0, len(lobby.Players)),
+	}
+	for _, player := range lobby.Players {
+		if !player.Playing {
+			continue
+		}
+		if player.Result == nil {
+			return
+		}
+
+		round.Results = append(round.Results, entities.LobbyRoundResult{
+			PlayerID:     player.ID,
+			Name:         player.Name,
+			Result:       *player.Result,
+			AttemptCount: uint32(len(player.Attempts)),
+			Points:       player.Points,
+		})
+	}
+
+	lobby.State = entities.LobbyStateWaiting
+	lobby.Rounds = append(lobby.Rounds, round)
+	if len(lobby.Rounds) > lobbyMaxRounds {
+		lobby.Rounds = slices.Delete(lobby.Rounds, 0, len(lobby.Rounds)-lobbyMaxRounds)
+	}
+}
+
+// publishLobby sends the lobby to its players, and one last time to the previous players who are no longer in it.
+// With followedOnly, only the players following their events on this instance get it
+func (s lobbyService) publishLobby(lobby entities.Lobby, previousPlayerIDs []int64, followedOnly bool) {
+	playerIDs := getPlayerIDs(lobby.Players)
+	for _, playerID := range previousPlayerIDs {
+		if lobby.FindPlayer(playerID) == -1 {
+			playerIDs = append(playerIDs, playerID)
+		}
+	}
+
+	for _, playerID := range playerIDs {
+		if followedOnly && !s.events.IsFollowed(playerID) {
+			continue
+		}
+
+		s.events.Publish(playerID, entities.UserEventLobbyUpdated, s.toResponse(lobby, playerID))
+	}
+}
+
+// getPlayerIDs returns the IDs of the provided lobby players
+func getPlayerIDs(players []entities.LobbyPlayer) []int64 {
+	playerIDs := make([]int64, len(players))
+	for i, player := range players {
+		playerIDs[i] = player.ID
+	}
+
+	return playerIDs
+}
+
+func (s lobbyService) toResponse(lobby entities.Lobby, viewerID int64) entities.LobbyResponse {
+	return lobby.ToResponse(viewerID, rules.GetGameMaxAttempts(lobby.Settings.WordLength, lobby.Settings.WordCount))
+}
+
+// getOriginalWords returns the words as they are in the word list, with diacritics
+func (s lobbyService) getOriginalWords(words []string) []string {
+	original := make([]string, len(words))
+	for i, word := range words {
+		var ok bool
+		original[i], ok = s.wordMap.GetOriginalWord(word)
+		if !ok {
+			original[i] = word
+		}
+	}
+
+	return original
+}
@@ -2,40 +2,93 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 	"termo_back_end/internal/entities"
 	"termo_back_end/internal/modules/repo"
 	"termo_back_end/internal/rules"
 	"termo_back_end/internal/status_codes"
 	"termo_back_end/internal/util"
+	"time"
 )
 
+type UpdateNameData struct {
+	Status status_codes.UserUpdateName
+
+	// RetryAfter is how long the user must wait before changing their name again; only set if the status is COOLDOWN
+	RetryAfter time.Duration
+}
+
 type UserService interface {
-	// UpdateName ensures the new name is valid and then changes it
+	// UpdateName ensures the new name is valid, allowed and not taken and then changes it
+	//
+	// Users have to wait for the configured cooldown between name changes. Changing to the current name does nothing
 	UpdateName(
 		ctx context.Context,
 		user *entities.User,
 		newName string,
-	) (status_codes.UserUpdateName, error)
+	) (*UpdateNameData, error)
 
 	// UpdatePassword ensures the new password is valid and then changes it
+	//
+	// Also returns the rules of the password policy the new password breaks if the status is INVALID
 	UpdatePassword(
 		ctx context.Context,
 		user *entities.User,
 		currentPassword string,
 		newPassword string,
-	) (status_codes.UserUpdatePassword, error)
+	) (status_codes.UserUpdatePassword, []rules.PasswordProblem, error)
+
+	// UpdateEmail ensures the new email address is valid and then changes it. An empty address removes it
+	//
+	// If another user already has the address, it's left unchanged and its owner is told by email, but the update still
+	// succeeds, so that addresses can't be enumerated
+	UpdateEmail(
+		ctx context.Context,
+		user *entities.User,
+		newEmail string,
+	) (status_codes.UserUpdateEmail, error)
+
+	// DeleteAccount deletes the provided user along with all their data, given their password. Guests, who have no
+	// password, don't need to send one
+	DeleteAccount(ctx context.Context, user *entities.User, password string) (status_codes.UserDelete, error)
+
+	// GetStats returns the game statistics of the provided user
+	GetStats(ctx context.Context, user *entities.User) (*entities.UserStatsResponse, error)
 }
 
 type userService struct {
-	repo repo.UserRepository
+	namePolicy     rules.NamePolicy
+	renameCooldown time.Duration
+	repo           repo.UserRepository
+	statsRepo      repo.StatsRepository
+	hasher         util.PasswordHasher
+	passwordPolicy rules.PasswordPolicy
+	mailer         util.Mailer
+	audit          AuditService
 }
 
-func NewUserService(repo repo.UserRepository) UserService {
+func NewUserService(
+	config entities.Config,
+	repo repo.UserRepository,
+	statsRepo repo.StatsRepository,
+	hasher util.PasswordHasher,
+	passwordPolicy rules.PasswordPolicy,
+	mailer util.Mailer,
+	audit AuditService,
+) UserService {
 	return userService{
-		repo: repo,
+		namePolicy:     rules.NewNamePolicy(config.Names.Reserved, config.Names.BlockedWords),
+		renameCooldown: time.Duration(config.Names.RenameCooldownHours) * time.Hour,
+		repo:           repo,
+		statsRepo:      statsRepo,
+		hasher:         hasher,
+		passwordPolicy: passwordPolicy,
+		mailer:         mailer,
+		audit:          audit,
 	}
 }
 
@@ -43,20 +96,63 @@ func (s userService) UpdateName(
 	ctx context.Context,
 	user *entities.User,
 	newName string,
-) (status_codes.UserUpdateName, error) {
+) (*UpdateNameData, error) {
 	// Clean name and validate
 	newName = strings.TrimSpace(newName)
 	if !rules.IsValidUserName(newName) {
-		return status_codes.UserUpdateNameInvalid, nil
+		return &UpdateNameData{Status: status_codes.UserUpdateNameInvalid}, nil
+	}
+
+	if newName == user.Name {
+		return &UpdateNameData{Status: status_codes.UserUpdateNameSuccess}, nil
+	}
+
+	if !s.namePolicy.IsAllowed(newName) {
+		return &UpdateNameData{Status: status_codes.UserUpdateNameNotAllowed}, nil
+	}
+
+	// Check if the user changed their name too recently
+	if s.renameCooldown > 0 {
+		lastChange, err := s.repo.GetLastNameChange(ctx, user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("[GetLastNameChange] | %v", err)
+		}
+
+		if lastChange != nil {
+			wait := time.Until(lastChange.Add(s.renameCooldown))
+			if wait > 0 {
+				return &UpdateNameData{Status: status_codes.UserUpdateNameCooldown, RetryAfter: wait}, nil
+			}
+		}
 	}
 
-	err := s.repo.UpdateName(ctx, user.ID, newName)
+	// Check if another user already has this name, ignoring upper/lowercase letters
+	taken, err := s.repo.IsNameTaken(ctx, newName, user.ID)
 	if err != nil {
+		return nil, fmt.Errorf("[IsNameTaken] | %v", err)
+	}
+
+	if taken {
+		return &UpdateNameData{Status: status_codes.UserUpdateNameTaken}, nil
+	}
+
+	err = s.repo.UpdateName(ctx, user.ID, newName)
+	if err != nil {
+		// The name may have been taken since it was checked
+		if errors.Is(err, repo.ErrNameTaken) {
+			return &UpdateNameData{Status: status_codes.UserUpdateNameTaken}, nil
+		}
 		log.Printf("[UpdateName] | %v", err)
-		return -1, err
+		return nil, err
 	}
 
-	return status_codes.UserUpdateNameSuccess, nil
+	s.audit.Record(ctx, entities.AuditEvent{
+		UserID:  &user.ID,
+		Type:    entities.AuditEventNameChange,
+		Details: map[string]string{"old_name": user.Name, "new_name": newName},
+	})
+
+	return &UpdateNameData{Status: status_codes.UserUpdateNameSuccess}, nil
 }
 
 func (s userService) UpdatePassword(
@@ -64,26 +160,114 @@ func (s userService) UpdatePassword(
 	user *entities.User,
 	currentPassword string,
 	newPassword string,
-) (status_codes.UserUpdatePassword, error) {
+) (status_codes.UserUpdatePassword, []rules.PasswordProblem, error) {
 	// Check if the current password matches the db one
-	if !util.CheckPasswordHash(currentPassword, user.Password) {
-		return status_codes.UserUpdatePasswordWrongCurrent, nil
+	if ok, _ := s.hasher.Verify(currentPassword, user.Password); !ok {
+		return status_codes.UserUpdatePasswordWrongCurrent, nil, nil
 	}
 
 	// Validate the new password
-	if !rules.IsValidUserPassword(newPassword) {
-		return status_codes.UserUpdatePasswordInvalid, nil
+	passwordProblems := s.passwordPolicy.Check(newPassword, user.Name)
+	if len(passwordProblems) > 0 {
+		return status_codes.UserUpdatePasswordInvalid, passwordProblems, nil
 	}
 
-	newPassword, err := util.HashPassword(newPassword)
+	newPassword, err := s.hasher.Hash(newPassword)
 	if err != nil {
-		return -1, fmt.Errorf("[HashPassword] | %v", err)
+		return -1, nil, fmt.Errorf("[Hash] | %v", err)
 	}
 
 	err = s.repo.UpdatePassword(ctx, user.ID, newPassword)
 	if err != nil {
-		return -1, fmt.Errorf("[UpdatePassword] | %v", err)
+		return -1, nil, fmt.Errorf("[UpdatePassword] | %v", err)
+	}
+
+	s.audit.Record(ctx, entities.AuditEvent{UserID: &user.ID, Type: entities.AuditEventPasswordChange})
+
+	return status_codes.UserUpdatePasswordSuccess, nil, nil
+}
+
+func (s userService) UpdateEmail(
+	ctx context.Context,
+	user *entities.User,
+	newEmail string,
+) (status_codes.UserUpdateEmail, error) {
+	// Clean email and validate
+	newEmail = strings.TrimSpace(newEmail)
+	if newEmail != "" && !rules.IsValidUserEmail(newEmail) {
+		return status_codes.UserUpdateEmailInvalid, nil
+	}
+
+	// Check if another user already has this email address
+	if newEmail != "" {
+		emailTaken, err := notifyEmailTaken(ctx, s.repo, s.mailer, newEmail, user.ID)
+		if err != nil {
+			log.Printf("[notifyEmailTaken] | %v", err)
+			return -1, err
+		}
+
+		if emailTaken {
+			return status_codes.UserUpdateEmailSuccess, nil
+		}
+	}
+
+	err := s.repo.UpdateEmail(ctx, user.ID, newEmail)
+
+	// The email address may have been taken since it was checked
+	if errors.Is(err, repo.ErrEmailTaken) {
+		_, err = notifyEmailTaken(ctx, s.repo, s.mailer, newEmail, user.ID)
+		if err != nil {
+			log.Printf("[notifyEmailTaken] | %v", err)
+			return -1, err
+		}
+
+		return status_codes.UserUpdateEmailSuccess, nil
+	}
+	if err != nil {
+		log.Printf("[UpdateEmail] | %v", err)
+		return -1, err
+	}
+
+	// The addresses themselves are not stored, since they are personal data
+	s.audit.Record(ctx, entities.AuditEvent{
+		UserID:  &user.ID,
+		Type:    entities.AuditEventEmailChange,
+		Details: map[string]string{"removed": strconv.FormatBool(newEmail == "")},
+	})
+
+	return status_codes.UserUpdateEmailSuccess, nil
+}
+
+func (s userService) DeleteAccount(
+	ctx context.Context,
+	user *entities.User,
+	password string,
+) (status_codes.UserDelete, error) {
+	if !user.IsGuest {
+		if ok, _ := s.hasher.Verify(password, user.Password); !ok {
+			return status_codes.UserDeleteWrongPassword, nil
+		}
+	}
+
+	err := s.repo.DeleteUser(ctx, user.ID)
+	if err != nil {
+		log.Printf("[DeleteUser] | %v", err)
+		return -1, err
+	}
+
+	// The user's own events were deleted along with their data; only the deletion itself is kept, without anything
+	// that could tell who they were
+	s.audit.RecordAnonymous(ctx, entities.AuditEventAccountDelete)
+
+	return status_codes.UserDeleteSuccess, nil
+}
+
+func (s userService) GetStats(ctx context.Context, user *entities.User) (*entities.UserStatsResponse, error) {
+	stats, err := s.statsRepo.GetUserStats(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("[GetUserStats] | %v", err)
 	}
 
-	return status_codes.UserUpdatePasswordSuccess, nil
+	response := entities.UserStatsToResponse(stats)
+	return &response, nil
 }
@@ -0,0 +1,753 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"termo_back_end/internal/entities"
+	"termo_back_end/internal/modules/repo"
+	"termo_back_end/internal/rules"
+	"termo_back_end/internal/status_codes"
+	"termo_back_end/internal/util"
+	"time"
+)
+
+// Versus room player limits
+const (
+	versusMinPlayers = 2
+	versusMaxPlayers = 8
+)
+
+// Versus room codes are made of characters that can't be mistaken for each other
+const (
+	versusCodeLength   = 6
+	versusCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+const (
+	// versusIdleTimeout is how long a room can wait without any player before being closed
+	versusIdleTimeout = 5 * time.Minute
+
+	// versusReconnectGrace is how long a player who disconnected during a match has to join again before forfeiting
+	versusReconnectGrace = 30 * time.Second
+
+	// versusEventBuffer is how many events can wait to be sent to a player; slower players are disconnected
+	versusEventBuffer = 32
+
+	// versusSaveTimeout limits how long saving a finished match can take
+	versusSaveTimeout = 30 * time.Second
+)
+
+type VersusAttemptData struct {
+	Status    status_codes.VersusAttempt
+	GameState entities.GameState
+
+	// Result and Words, the original game words, are only set once the attempt finishes the player's game
+	Result *entities.GameResult
+	Words  []string
+}
+
+type VersusService interface {
+	// CreateRoom creates a versus room hosted by the provided user, who still has to join it. Returns the code used
+	// to join the room
+	//
+	// Rooms nobody joins are closed after a while
+	CreateRoom(user *entities.User, options entities.VersusRoomOptions) (status_codes.VersusCreate, string, error)
+
+	// ListRooms returns the public rooms waiting for players, from the oldest to the newest
+	ListRooms() []entities.VersusRoomSummary
+
+	// JoinRoom connects the provided user to the room with the provided code, returning the session they play
+	// through. Players who disconnected during a match can join again to resume it; joining again while connected
+	// closes the previous session
+	JoinRoom(user *entities.User, code string) (status_codes.VersusJoin, *VersusSession)
+}
+
+type versusService struct {
+	wordMap   util.WordMap
+	repo      repo.GameRepository
+	userRepo  repo.UserRepository
+	statsRepo repo.StatsRepository
+
+	mu    sync.Mutex
+	rooms map[string]*versusRoom
+}
+
+func NewVersusService(
+	wordMap util.WordMap,
+	repo repo.GameRepository,
+	userRepo repo.UserRepository,
+	statsRepo repo.StatsRepository,
+) VersusService {
+	return &versusService{
+		wordMap:   wordMap,
+		repo:      repo,
+		userRepo:  userRepo,
+		statsRepo: statsRepo,
+		rooms:     make(map[string]*versusRoom),
+	}
+}
+
+// versusRoom is a room where players race to find the same words. Every field is guarded by mu
+type versusRoom struct {
+	mu sync.Mutex
+
+	code     string
+	options  entities.VersusRoomOptions
+	hostID   int64
+	hostName string
+	state    entities.VersusRoomState
+
+	// words are only chosen when the match starts
+	words   []string
+	players []*versusPlayer
+	winner  *versusPlayer
+
+	createdAt time.Time
+	startedAt time.Time
+
+	// idleTimer closes the room while nobody is in it
+	idleTimer *time.Timer
+
+	// closed tells whether the room was removed from the service, so that nobody else can join it
+	closed bool
+}
+
+// versusPlayer is a player of a versus room, along with their game
+type versusPlayer struct {
+	id   int64
+	name string
+
+	// session is nil while the player is disconnected
+	session *VersusSession
+
+	attempts     []string
+	attemptTimes []time.Time
+	states       []entities.GameState
+	result       *entities.GameResult
+
+	// reconnectTimer forfeits the player's game if they don't join again after disconnecting during a match
+	reconnectTimer *time.Timer
+}
+
+// VersusSession is the connection of a player to a versus room. The room's events are sent to Events until the
+// session is closed, either by Close, by the player joining again or for not reading events fast enough
+type VersusSession struct {
+	service *versusService
+	room    *versusRoom
+	player  *versusPlayer
+	events  chan entities.VersusEvent
+
+	// closed and left are guarded by the room's mu
+	closed bool
+	left   bool
+}
+
+func (s *versusService) CreateRoom(
+	user *entities.User,
+	options entities.VersusRoomOptions,
+) (status_codes.VersusCreate, string, error) {
+	if !rules.IsValidWordLength(options.WordLength) {
+		return status_codes.VersusCreateInvalidWordLength, "", nil
+	}
+	if !rules.IsValidWordCount(options.WordCount) {
+		return status_codes.VersusCreateInvalidCount, "", nil
+	}
+
+	if options.MaxPlayers == 0 {
+		options.MaxPlayers = versusMinPlayers
+	}
+	if options.MaxPlayers < versusMinPlayers || options.MaxPlayers > versusMaxPlayers {
+		return status_codes.VersusCreateInvalidMaxPlayers, "", nil
+	}
+
+	// Ensure there are enough words, so that the match can start once the players join
+	_, err := s.wordMap.ChooseRandom(options.WordLength, options.WordCount)
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidSize) {
+			return status_codes.VersusCreateInvalidWordLength, "", nil
+		}
+		if errors.Is(err, util.ErrNotEnoughWords) {
+			return status_codes.VersusCreateInvalidCount, "", nil
+		}
+		return -1, "", fmt.Errorf("[ChooseRandom] | %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var code string
+	for code == "" || s.rooms[code] != nil {
+		code, err = util.GenerateRandomCode(versusCodeLength, versusCodeAlphabet)
+		if err != nil {
+			return -1, "", fmt.Errorf("[GenerateRandomCode] | %v", err)
+		}
+	}
+
+	room := &versusRoom{
+		code:      code,
+		options:   options,
+		hostID:    user.ID,
+		hostName:  user.Name,
+		state:     entities.VersusRoomStateWaiting,
+		createdAt: time.Now(),
+	}
+	room.idleTimer = time.AfterFunc(versusIdleTimeout, func() {
+		s.closeIdleRoom(room)
+	})
+	s.rooms[code] = room
+
+	return status_codes.VersusCreateSuccess, code, nil
+}
+
+func (s *versusService) ListRooms() []entities.VersusRoomSummary {
+	// Rooms are locked only after the service is unlocked, since rooms lock the service when they close
+	s.mu.Lock()
+	rooms := make([]*versusRoom, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	s.mu.Unlock()
+
+	summaries := make([]entities.VersusRoomSummary, 0)
+	for _, room := range rooms {
+		room.mu.Lock()
+		if !room.closed && room.options.Public && room.state == entities.VersusRoomStateWaiting {
+			summaries = append(summaries, entities.VersusRoomSummary{
+				Code:       room.code,
+				HostName:   room.hostName,
+				WordLength: room.options.WordLength,
+				WordCount:  room.options.WordCount,
+				Players:    uint32(len(room.players)),
+				MaxPlayers: room.options.MaxPlayers,
+				CreatedAt:  room.createdAt.UTC(),
+			})
+		}
+		room.mu.Unlock()
+	}
+
+	slices.SortFunc(summaries, func(a, b entities.VersusRoomSummary) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return summaries
+}
+
+func (s *versusService) JoinRoom(user *entities.User, code string) (status_codes.VersusJoin, *VersusSession) {
+	s.mu.Lock()
+	room := s.rooms[strings.ToUpper(strings.TrimSpace(code))]
+	s.mu.Unlock()
+
+	if room == nil {
+		return status_codes.VersusJoinNotFound, nil
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if room.closed {
+		return status_codes.VersusJoinNotFound, nil
+	}
+
+	player := room.findPlayerLocked(user.ID)
+	if player == nil {
+		if room.state != entities.VersusRoomStateWaiting {
+			return status_codes.VersusJoinAlreadyStarted, nil
+		}
+
+		if uint32(len(room.players)) >= room.options.MaxPlayers {
+			return status_codes.VersusJoinFull, nil
+		}
+
+		player = &versusPlayer{
+			id:   user.ID,
+			name: user.Name,
+		}
+		room.players = append(room.players, player)
+		room.idleTimer.Stop()
+	}
+
+	// The new session replaces the previous one, e.g. when the player joins again from another device
+	if player.session != nil {
+		player.session.closeLocked()
+	}
+	if player.reconnectTimer != nil {
+		player.reconnectTimer.Stop()
+		player.reconnectTimer = nil
+	}
+
+	session := &VersusSession{
+		service: s,
+		room:    room,
+		player:  player,
+		events:  make(chan entities.VersusEvent, versusEventBuffer),
+	}
+	player.session = session
+
+	room.broadcastRoomLocked()
+
+	// Full rooms start right away
+	if room.state == entities.VersusRoomStateWaiting && uint32(len(room.players)) == room.options.MaxPlayers {
+		err := s.startLocked(room)
+		if err != nil {
+			log.Printf("[startLocked] | %v", err)
+		}
+	}
+
+	return status_codes.VersusJoinSuccess, session
+}
+
+// Events returns the channel the room's events are sent to; it's closed along with the session
+func (s *VersusSession) Events() <-chan entities.VersusEvent {
+	return s.events
+}
+
+// Start starts the match of a room waiting for players. Only the host can start it, once there are enough players
+func (s *VersusSession) Start() (status_codes.VersusStart, error) {
+	room := s.room
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if room.state != entities.VersusRoomStateWaiting {
+		return status_codes.VersusStartAlreadyStarted, nil
+	}
+
+	if s.player.id != room.hostID {
+		return status_codes.VersusStartNotHost, nil
+	}
+
+	if len(room.players) < versusMinPlayers {
+		return status_codes.VersusStartNotEnoughPlayers, nil
+	}
+
+	err := s.service.startLocked(room)
+	if err != nil {
+		return -1, fmt.Errorf("[startLocked] | %v", err)
+	}
+
+	return status_codes.VersusStartSuccess, nil
+}
+
+// Attempt registers an attempt on the player's game. Opponents are sent the states of its letters, but not the
+// letters themselves
+func (s *VersusSession) Attempt(attempt string) *VersusAttemptData {
+	room := s.room
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	player := s.player
+	if room.state != entities.VersusRoomStatePlaying || player.result != nil {
+		return &VersusAttemptData{Status: status_codes.VersusAttemptNotPlaying}
+	}
+
+	// Ensure the attempt is a valid real word
+	attempt = s.service.wordMap.CleanWord(attempt)
+	if uint32(len(attempt)) != room.options.WordLength {
+		return &VersusAttemptData{Status: status_codes.VersusAttemptInvalid}
+	}
+
+	if _, ok := s.service.wordMap.GetOriginalWord(attempt); !ok {
+		return &VersusAttemptData{Status: status_codes.VersusAttemptNotInDictionary}
+	}
+
+	// Check what's right and what's wrong
+	game := entities.Game{Words: room.words, Attempts: player.attempts}
+	gameState := rules.CheckGameAttempt(game, attempt)
+	won := rules.IsGameWon(game, attempt)
+
+	player.attempts = append(player.attempts, attempt)
+	player.attemptTimes = append(player.attemptTimes, time.Now())
+	player.states = append(player.states, gameState)
+
+	var result *entities.GameResult
+	if won {
+		result = new(entities.GameResult)
+		*result = entities.GameResultWon
+	} else if uint32(len(player.attempts)) >= rules.GetGameMaxAttempts(room.options.WordLength, room.options.WordCount) {
+		result = new(entities.GameResult)
+		*result = entities.GameResultLost
+	}
+
+	room.broadcastLocked(entities.VersusEvent{
+		Type: entities.VersusEventProgress,
+		Data: entities.VersusProgressResponse{
+			PlayerID:  player.id,
+			GameState: gameState,
+			Result:    result,
+		},
+	}, player)
+
+	data := &VersusAttemptData{
+		Status:    status_codes.VersusAttemptSuccess,
+		GameState: gameState,
+	}
+
+	if result != nil {
+		data.Result = result
+		data.Words = s.service.getOriginalWords(room.words)
+		s.service.finishPlayerLocked(room, player, *result)
+	}
+
+	return data
+}
+
+// Forfeit finishes the player's game as abandoned, while the opponents keep playing
+func (s *VersusSession) Forfeit() status_codes.VersusForfeit {
+	room := s.room
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if room.state != entities.VersusRoomStatePlaying || s.player.result != nil {
+		return status_codes.VersusForfeitNotPlaying
+	}
+
+	s.service.abandonLocked(room, s.player)
+
+	return status_codes.VersusForfeitSuccess
+}
+
+// Close disconnects the player from the room. Players leave rooms that are waiting for players right away, while
+// players in a match have some time to join again before forfeiting
+func (s *VersusSession) Close() {
+	room := s.room
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if !s.closed {
+		s.closeLocked()
+	}
+
+	// Sessions replaced by a new one don't make the player leave
+	if s.left || s.player.session != nil {
+		s.left = true
+		return
+	}
+	s.left = true
+
+	s.service.disconnectLocked(room, s.player)
+}
+
+// closeLocked closes the session's events channel and detaches it from the player
+func (s *VersusSession) closeLocked() {
+	s.closed = true
+	close(s.events)
+
+	if s.player.session == s {
+		s.player.session = nil
+	}
+}
+
+// sendLocked sends an event to the session without blocking. Sessions that fall too far behind are closed
+func (s *VersusSession) sendLocked(event entities.VersusEvent) {
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.events <- event:
+	default:
+		s.closeLocked()
+	}
+}
+
+// startLocked chooses the words of a room and starts its match
+func (s *versusService) startLocked(room *versusRoom) error {
+	words, err := s.wordMap.ChooseRandom(room.options.WordLength, room.options.WordCount)
+	if err != nil {
+		return fmt.Errorf("[ChooseRandom] | %v", err)
+	}
+
+	room.words = words
+	room.state = entities.VersusRoomStatePlaying
+	room.startedAt = time.Now()
+	room.idleTimer.Stop()
+
+	// Players who disconnected while the room was waiting already left it, so every player is connected
+	room.broadcastRoomLocked()
+
+	return nil
+}
+
+// disconnectLocked handles a player whose last session was closed
+func (s *versusService) disconnectLocked(room *versusRoom, player *versusPlayer) {
+	switch room.state {
+	case entities.VersusRoomStateWaiting:
+		room.players = slices.DeleteFunc(room.players, func(p *versusPlayer) bool {
+			return p == player
+		})
+
+		if len(room.players) == 0 {
+			room.idleTimer.Reset(versusIdleTimeout)
+			return
+		}
+
+		// The player who joined first after the host becomes the new host
+		if player.id == room.hostID {
+			room.hostID = room.players[0].id
+			room.hostName = room.players[0].name
+		}
+
+		room.broadcastRoomLocked()
+	case entities.VersusRoomStatePlaying:
+		if player.result == nil {
+			player.reconnectTimer = time.AfterFunc(versusReconnectGrace, func() {
+				room.mu.Lock()
+				defer room.mu.Unlock()
+
+				if room.state == entities.VersusRoomStatePlaying && player.session == nil && player.result == nil {
+					s.abandonLocked(room, player)
+				}
+			})
+		}
+
+		room.broadcastRoomLocked()
+	}
+}
+
+// abandonLocked finishes a player's game as abandoned and tells the opponents
+func (s *versusService) abandonLocked(room *versusRoom, player *versusPlayer) {
+	result := entities.GameResultAbandoned
+	room.broadcastLocked(entities.VersusEvent{
+		Type: entities.VersusEventProgress,
+		Data: entities.VersusProgressResponse{
+			PlayerID: player.id,
+			Result:   &result,
+		},
+	}, player)
+
+	s.finishPlayerLocked(room, player, result)
+}
+
+// finishPlayerLocked sets the result of a player's game. The first player to win makes every opponent still playing
+// lose, and the match ends once every player has a result
+func (s *versusService) finishPlayerLocked(room *versusRoom, player *versusPlayer, result entities.GameResult) {
+	player.result = &result
+	if player.reconnectTimer != nil {
+		player.reconnectTimer.Stop()
+		player.reconnectTimer = nil
+	}
+
+	if result == entities.GameResultWon && room.winner == nil {
+		room.winner = player
+
+		for _, opponent := range room.players {
+			if opponent.result != nil {
+				continue
+			}
+
+			lost := entities.GameResultLost
+			opponent.result = &lost
+			if opponent.reconnectTimer != nil {
+				opponent.reconnectTimer.Stop()
+				opponent.reconnectTimer = nil
+			}
+
+			room.broadcastLocked(entities.VersusEvent{
+				Type: entities.VersusEventProgress,
+				Data: entities.VersusProgressResponse{
+					PlayerID: opponent.id,
+					Result:   &lost,
+				},
+			}, nil)
+		}
+	}
+
+	for _, p := range room.players {
+		if p.result == nil {
+			return
+		}
+	}
+
+	s.finishLocked(room)
+}
+
+// finishLocked ends the match of a room, revealing its words and every attempt, and saves it in the background
+func (s *versusService) finishLocked(room *versusRoom) {
+	room.state = entities.VersusRoomStateFinished
+	finishedAt := time.Now()
+
+	// Nobody can join a finished room, but its players still get the final events
+	room.closed = true
+	s.mu.Lock()
+	delete(s.rooms, room.code)
+	s.mu.Unlock()
+
+	finished := entities.VersusFinishedResponse{
+		Words:   s.getOriginalWords(room.words),
+		Players: make([]entities.VersusPlayerResponse, len(room.players)),
+	}
+	if room.winner != nil {
+		finished.WinnerID = &room.winner.id
+	}
+	for i, player := range room.players {
+		finished.Players[i] = player.toResponse(true)
+	}
+
+	room.broadcastLocked(entities.VersusEvent{Type: entities.VersusEventFinished, Data: finished}, nil)
+
+	match := entities.VersusMatch{
+		StartedAt:  room.startedAt,
+		FinishedAt: finishedAt,
+		Games:      make([]entities.Game, len(room.players)),
+	}
+	for i, player := range room.players {
+		match.Games[i] = entities.Game{
+			UserID:       player.id,
+			Words:        room.words,
+			Attempts:     slices.Clone(player.attempts),
+			AttemptTimes: slices.Clone(player.attemptTimes),
+			Result:       player.result,
+			StartedAt:    room.startedAt,
+			FinishedAt:   &finishedAt,
+		}
+	}
+
+	go s.saveMatch(match)
+}
+
+// saveMatch saves a finished match and updates the stats of its players, like a regular game. Failures are only
+// logged, since the players already got the results
+func (s *versusService) saveMatch(match entities.VersusMatch) {
+	ctx, cancel := context.WithTimeout(context.Background(), versusSaveTimeout)
+	defer cancel()
+
+	_, err := s.repo.SaveVersusMatch(ctx, match)
+	if err != nil {
+		log.Printf("[SaveVersusMatch] | %v", err)
+		return
+	}
+
+	for _, game := range match.Games {
+		err = s.statsRepo.RecordGame(
+			ctx,
+			game.UserID,
+			game.GetWordLength(),
+			game.GetWordCount(),
+			*game.Result,
+			uint32(len(game.Attempts)),
+			game.HardMode,
+			nil,
+		)
+		if err != nil {
+			log.Printf("[RecordGame] | %v", err)
+		}
+
+		if *game.Result == entities.GameResultWon {
+			err = s.userRepo.IncrementScore(ctx, game.UserID)
+			if err != nil {
+				log.Printf("[IncrementScore] | %v", err)
+			}
+		}
+	}
+}
+
+// closeIdleRoom closes a room if it's still waiting without any player
+func (s *versusService) closeIdleRoom(room *versusRoom) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if room.closed || room.state != entities.VersusRoomStateWaiting || len(room.players) > 0 {
+		return
+	}
+
+	room.closed = true
+	s.mu.Lock()
+	delete(s.rooms, room.code)
+	s.mu.Unlock()
+}
+
+// getOriginalWords returns the words as they are in the word list, with diacritics
+func (s *versusService) getOriginalWords(words []string) []string {
+	original := make([]string, len(words))
+	for i, word := range words {
+		var ok bool
+		original[i], ok = s.wordMap.GetOriginalWord(word)
+		if !ok {
+			original[i] = word
+		}
+	}
+
+	return original
+}
+
+// findPlayerLocked returns the player with the provided user ID; returns nil if they are not in the room
+func (r *versusRoom) findPlayerLocked(userID int64) *versusPlayer {
+	for _, player := range r.players {
+		if player.id == userID {
+			return player
+		}
+	}
+	return nil
+}
+
+// broadcastLocked sends an event to every connected player except the provided one, if any
+func (r *versusRoom) broadcastLocked(event entities.VersusEvent, except *versusPlayer) {
+	for _, player := range r.players {
+		if player != except && player.session != nil {
+			player.session.sendLocked(event)
+		}
+	}
+}
+
+// broadcastRoomLocked sends the whole room to every connected player. Each player only gets the letters of their
+// own attempts
+func (r *versusRoom) broadcastRoomLocked() {
+	for _, player := range r.players {
+		if player.session != nil {
+			player.session.sendLocked(entities.VersusEvent{
+				Type: entities.VersusEventRoom,
+				Data: r.toResponseLocked(player),
+			})
+		}
+	}
+}
+
+// toResponseLocked builds the room as seen by the provided player
+func (r *versusRoom) toResponseLocked(viewer *versusPlayer) entities.VersusRoomResponse {
+	response := entities.VersusRoomResponse{
+		Code:        r.code,
+		State:       r.state,
+		HostID:      r.hostID,
+		WordLength:  r.options.WordLength,
+		WordCount:   r.options.WordCount,
+		MaxAttempts: rules.GetGameMaxAttempts(r.options.WordLength, r.options.WordCount),
+		MaxPlayers:  r.options.MaxPlayers,
+		Public:      r.options.Public,
+		Players:     make([]entities.VersusPlayerResponse, len(r.players)),
+	}
+
+	if r.state != entities.VersusRoomStateWaiting {
+		startedAt := r.startedAt.UTC()
+		response.StartedAt = &startedAt
+	}
+
+	for i, player := range r.players {
+		response.Players[i] = player.toResponse(player == viewer)
+	}
+
+	return response
+}
+
+// toResponse builds the player's progress, with the letters of their attempts only if revealed
+func (p *versusPlayer) toResponse(reveal bool) entities.VersusPlayerResponse {
+	response := entities.VersusPlayerResponse{
+		ID:         p.id,
+		Name:       p.name,
+		Connected:  p.session != nil,
+		GameStates: slices.Clone(p.states),
+		Result:     p.result,
+	}
+
+	if reveal {
+		response.Attempts = slices.Clone(p.attempts)
+	}
+	if response.GameStates == nil {
+		response.GameStates = make([]entities.GameState, 0)
+	}
+
+	return response
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/gorilla/mux"
@@ -10,29 +11,175 @@ import (
 	"termo_back_end/internal/modules/module"
 	"termo_back_end/internal/modules/repo"
 	"termo_back_end/internal/modules/service"
+	"termo_back_end/internal/rules"
+	"termo_back_end/internal/util"
 	"time"
 )
 
-func Setup(config entities.Config, words []string, db *sql.DB) *mux.Router {
+// guestPurgeInterval is how often stale guest users are deleted
+const guestPurgeInterval = 1 * time.Hour
+
+// lobbyPurgeInterval is how often idle lobbies are deleted, and rounds whose time is up are ended if they weren't yet
+const lobbyPurgeInterval = 1 * time.Minute
+
+// lobbySyncInterval is how often the lobby changes made by other instances are sent to this instance's players, when
+// lobbies are kept in the database
+const lobbySyncInterval = 1 * time.Second
+
+// rateLimitPurgeInterval is how often full rate limit buckets are deleted from the database
+const rateLimitPurgeInterval = 1 * time.Minute
+
+func Setup(config entities.Config, words []string, commonPasswords []string, db *sql.DB) *mux.Router {
 	r := mux.NewRouter()
 
 	// Repositories
 	userRepo := repo.NewUserRepo(db)
 	gameRepo := repo.NewGameRepo(db)
+	statsRepo := repo.NewStatsRepo(db)
+	leaderboardRepo := repo.NewLeaderboardRepo(db)
+	authRepo := repo.NewAuthRepo(db)
+	adminRepo := repo.NewAdminRepo(db)
+	auditRepo := repo.NewAuditRepo(db)
+	challengeRepo := repo.NewChallengeRepo(db)
+
+	// Rate limiter
+	var (
+		rateLimitStore util.RateLimitStore
+		rateLimitRepo  repo.RateLimitRepository
+	)
+	switch config.RateLimit.Store {
+	case entities.RateLimitStoreDatabase:
+		rateLimitRepo = repo.NewRateLimitRepo(db)
+		rateLimitStore = rateLimitRepo
+	case entities.RateLimitStoreMemory, "":
+		rateLimitStore = util.NewMemoryRateLimitStore()
+	default:
+		log.Fatalf("unknown rate limit store: %s", config.RateLimit.Store)
+	}
+	limiter := util.NewRateLimiter(rateLimitStore, util.GetProxyHops(config), config.RateLimit.FailClosed)
+
+	// Lobby store
+	var lobbyStore util.LobbyStore
+	switch config.Lobby.Store {
+	case entities.LobbyStoreDatabase:
+		lobbyStore = repo.NewLobbyRepo(db)
+	case entities.LobbyStoreMemory, "":
+		lobbyStore = util.NewMemoryLobbyStore()
+	default:
+		log.Fatalf("unknown lobby store: %s", config.Lobby.Store)
+	}
+
+	// Mailer
+	var mailer util.Mailer
+	switch config.Mail.Driver {
+	case entities.MailDriverSMTP:
+		smtpConfig := config.Mail.SMTP
+		mailer = util.NewSMTPMailer(
+			smtpConfig.Host,
+			smtpConfig.Port,
+			smtpConfig.User,
+			smtpConfig.Password,
+			config.Mail.From,
+		)
+	case entities.MailDriverLog, "":
+		mailer = util.NewLogMailer(config.Mail.From, config.Mail.Dir)
+	default:
+		log.Fatalf("unknown mail driver: %s", config.Mail.Driver)
+	}
+
+	// Word list
+	wordMap := util.WordMapFromList(words)
+
+	// Daily words are derived from the daily secret, so without one anyone could predict them
+	if config.Game.DailySecret == "" {
+		log.Fatalf("game.daily_secret is not set")
+	}
+
+	// Event bus
+	eventBus := util.NewEventBus()
+
+	// Password hasher
+	hasher, err := util.NewPasswordHasher(config)
+	if err != nil {
+		log.Fatalf("[NewPasswordHasher] | %v", err)
+	}
+
+	// Password policy
+	passwordPolicy, err := rules.NewPasswordPolicy(config, commonPasswords)
+	if err != nil {
+		log.Fatalf("[NewPasswordPolicy] | %v", err)
+	}
 
 	// Services
-	userService := service.NewUserService(userRepo)
-	gameService := service.NewGameService(words, gameRepo, userRepo)
-	authService := service.NewAuthService(config, userRepo)
+	auditService := service.NewAuditService(auditRepo)
+	userService := service.NewUserService(config, userRepo, statsRepo, hasher, passwordPolicy, mailer, auditService)
+	gameService := service.NewGameService(config, wordMap, gameRepo, challengeRepo, userRepo, statsRepo, eventBus)
+	authService := service.NewAuthService(config, userRepo, authRepo, hasher, passwordPolicy, mailer, auditService)
+	leaderboardService := service.NewLeaderboardService(config, leaderboardRepo)
+	adminService := service.NewAdminService(adminRepo, userRepo, authRepo, gameService, auditService)
+	versusService := service.NewVersusService(wordMap, gameRepo, userRepo, statsRepo)
+	challengeService := service.NewChallengeService(wordMap, challengeRepo)
+	lobbyService := service.NewLobbyService(config, wordMap, lobbyStore, eventBus)
+
+	// Background jobs
+	go util.RunPeriodically(context.Background(), guestPurgeInterval, func(ctx context.Context) {
+		deleted, err := authService.PurgeStaleGuests(ctx)
+		if err != nil {
+			log.Printf("[PurgeStaleGuests] | %v", err)
+		}
+		if deleted > 0 {
+			log.Printf("purged %d stale guests", deleted)
+		}
+	})
+	go util.RunPeriodically(context.Background(), lobbyPurgeInterval, func(ctx context.Context) {
+		_, err := lobbyService.ExpireRounds(ctx)
+		if err != nil {
+			log.Printf("[ExpireRounds] | %v", err)
+		}
+
+		deleted, err := lobbyService.DeleteIdleLobbies(ctx)
+		if err != nil {
+			log.Printf("[DeleteIdleLobbies] | %v", err)
+		}
+		if deleted > 0 {
+			log.Printf("deleted %d idle lobbies", deleted)
+		}
+	})
+	if config.Lobby.Store == entities.LobbyStoreDatabase {
+		go util.RunPeriodically(context.Background(), lobbySyncInterval, func(ctx context.Context) {
+			err := lobbyService.SyncLobbies(ctx)
+			if err != nil {
+				log.Printf("[SyncLobbies] | %v", err)
+			}
+		})
+	}
+	if rateLimitRepo != nil {
+		go util.RunPeriodically(context.Background(), rateLimitPurgeInterval, func(ctx context.Context) {
+			_, err := rateLimitRepo.DeleteFullBuckets(ctx)
+			if err != nil {
+				log.Printf("[DeleteFullBuckets] | %v", err)
+			}
+		})
+	}
 
 	// Modules
-	userModule := module.NewUserModule(userService, gameService)
-	gameModule := module.NewGameModule(gameService)
-	authModule := module.NewAuthModule(authService)
+	userModule := module.NewUserModule(userService, gameService, auditService, limiter)
+	gameModule := module.NewGameModule(gameService, limiter)
+	authModule := module.NewAuthModule(config, authService, limiter)
+	leaderboardModule := module.NewLeaderboardModule(leaderboardService, limiter)
+	adminModule := module.NewAdminModule(adminService, auditService, limiter)
+	versusModule := module.NewVersusModule(config, versusService, limiter)
+	challengeModule := module.NewChallengeModule(challengeService, gameService, limiter)
+	lobbyModule := module.NewLobbyModule(lobbyService, limiter)
 
 	apiModules := []entities.Module{
 		gameModule,
 		userModule,
+		leaderboardModule,
+		adminModule,
+		versusModule,
+		challengeModule,
+		lobbyModule,
 	}
 
 	// Set up the main auth module for API
@@ -1,11 +1,47 @@
 package rules
 
 import (
+	"strings"
 	"termo_back_end/internal/entities"
 )
 
 const letterBlank = byte('\n')
 
+// Bounds of the word length and count of regular games
+//
+// TODO: Better way of handling this; hardcoded for now
+const (
+	MinWordLength = 3
+	MaxWordLength = 22
+	MaxWordCount  = 20
+)
+
+// IsValidWordLength checks whether games can be played with words of the provided length
+func IsValidWordLength(wordLength uint32) bool {
+	return wordLength >= MinWordLength && wordLength <= MaxWordLength
+}
+
+// IsValidWordCount checks whether games can be played with the provided number of words
+func IsValidWordCount(wordCount uint32) bool {
+	return wordCount > 0 && wordCount <= MaxWordCount
+}
+
+// DailyWordLength is the word length used by every daily game variant
+const DailyWordLength = 5
+
+// DailyWordCounts lists the word counts of all available daily game variants
+var DailyWordCounts = []uint32{1, 2, 4}
+
+// IsValidDailyWordCount checks whether there is a daily game variant with the provided word count
+func IsValidDailyWordCount(wordCount uint32) bool {
+	for _, count := range DailyWordCounts {
+		if count == wordCount {
+			return true
+		}
+	}
+	return false
+}
+
 // GetGameMaxAttempts returns the maximum number of attempts a user can make for a given word length and game count
 func GetGameMaxAttempts(wordLength uint32, wordCount uint32) uint32 {
 	return wordCount + wordLength
@@ -88,6 +124,98 @@ func IsGameWon(game entities.Game, currentAttempt string) bool {
 	return true
 }
 
+// CheckHardMode checks whether an attempt reuses every hint revealed by the game's previous attempts. Returns nil if
+// the attempt is valid, or the first violation found otherwise
+//
+// Letters revealed in the correct position must be kept in place and letters revealed in the wrong position must be
+// used somewhere, as many times as they were revealed by a single attempt. Since the hints of different words may
+// contradict each other, the attempt only has to follow the hints of one of the words not yet found
+//
+// Note: The input attempt is expected to be cleaned and to have the same length as the game words
+func CheckHardMode(game entities.Game, attempt string) *entities.HardModeViolation {
+	states := make([][]entities.GameWordState, len(game.Attempts))
+	for i, prevAttempt := range game.Attempts {
+		states[i] = CheckGameAttempt(game, prevAttempt)
+	}
+
+	var firstViolation *entities.HardModeViolation
+	for board, word := range game.Words {
+		// Hints from words that were already found don't need to be reused
+		if isWordFound(game, word) {
+			continue
+		}
+
+		violation := checkHardModeBoard(game, states, board, attempt)
+		if violation == nil {
+			return nil
+		}
+
+		if firstViolation == nil {
+			firstViolation = violation
+		}
+	}
+
+	return firstViolation
+}
+
+// checkHardModeBoard checks whether an attempt reuses every hint revealed for a single game word, given the states
+// of all previous attempts
+func checkHardModeBoard(
+	game entities.Game,
+	states [][]entities.GameWordState,
+	board int,
+	attempt string,
+) *entities.HardModeViolation {
+	for j, prevAttempt := range game.Attempts {
+		// Count how many times each letter must appear in the attempt
+		required := make(map[byte]int)
+		for i, state := range states[j][board] {
+			switch state {
+			case entities.GameLetterStateCorrect:
+				if attempt[i] != prevAttempt[i] {
+					return &entities.HardModeViolation{
+						Rule:     entities.HardModeRuleCorrectPosition,
+						Board:    board,
+						Letter:   string(prevAttempt[i]),
+						Position: i,
+					}
+				}
+				required[prevAttempt[i]]++
+			case entities.GameLetterStateWrongPosition:
+				required[prevAttempt[i]]++
+			}
+		}
+
+		for i, state := range states[j][board] {
+			if state != entities.GameLetterStateWrongPosition {
+				continue
+			}
+
+			letter := prevAttempt[i]
+			if strings.Count(attempt, string(letter)) < required[letter] {
+				return &entities.HardModeViolation{
+					Rule:     entities.HardModeRuleMissingLetter,
+					Board:    board,
+					Letter:   string(letter),
+					Position: i,
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// isWordFound checks whether any of the game's attempts matches the word
+func isWordFound(game entities.Game, word string) bool {
+	for _, attempt := range game.Attempts {
+		if attempt == word {
+			return true
+		}
+	}
+	return false
+}
+
 func index(s []byte, b byte) int {
 	for i, v := range s {
 		if v == b {
@@ -0,0 +1,113 @@
+package rules
+
+import (
+	"termo_back_end/internal/entities"
+	"testing"
+)
+
+func TestCheckHardMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		words    []string
+		attempts []string
+		attempt  string
+		want     *entities.HardModeViolation
+	}{
+		{
+			name:    "first attempt",
+			words:   []string{"carta"},
+			attempt: "lindo",
+		},
+		{
+			name:     "correct letters kept",
+			words:    []string{"carta"},
+			attempts: []string{"carro"},
+			attempt:  "carpa",
+		},
+		{
+			name:     "correct letter moved",
+			words:    []string{"carta"},
+			attempts: []string{"carro"},
+			attempt:  "barra",
+			want: &entities.HardModeViolation{
+				Rule:     entities.HardModeRuleCorrectPosition,
+				Letter:   "c",
+				Position: 0,
+			},
+		},
+		{
+			name:     "misplaced letters reused",
+			words:    []string{"carta"},
+			attempts: []string{"traco"},
+			attempt:  "carta",
+		},
+		{
+			name:     "misplaced letter missing",
+			words:    []string{"carta"},
+			attempts: []string{"traco"},
+			attempt:  "corte",
+			want: &entities.HardModeViolation{
+				Rule:     entities.HardModeRuleMissingLetter,
+				Letter:   "a",
+				Position: 2,
+			},
+		},
+		{
+			name:     "repeated letter reused as many times",
+			words:    []string{"salas"},
+			attempts: []string{"aaxyz"},
+			attempt:  "palha",
+		},
+		{
+			name:     "repeated letter reused fewer times",
+			words:    []string{"salas"},
+			attempts: []string{"aaxyz"},
+			attempt:  "paxxx",
+			want: &entities.HardModeViolation{
+				Rule:     entities.HardModeRuleMissingLetter,
+				Letter:   "a",
+				Position: 0,
+			},
+		},
+		{
+			name:     "hints of one word followed",
+			words:    []string{"carta", "piano"},
+			attempts: []string{"carro"},
+			attempt:  "piano",
+		},
+		{
+			name:     "hints of no word followed",
+			words:    []string{"carta", "piano"},
+			attempts: []string{"carro"},
+			attempt:  "bolsa",
+			want: &entities.HardModeViolation{
+				Rule:     entities.HardModeRuleCorrectPosition,
+				Letter:   "c",
+				Position: 0,
+			},
+		},
+		{
+			name:     "hints of found words ignored",
+			words:    []string{"carta", "piano"},
+			attempts: []string{"carta"},
+			attempt:  "lindo",
+			want: &entities.HardModeViolation{
+				Rule:     entities.HardModeRuleMissingLetter,
+				Board:    1,
+				Letter:   "a",
+				Position: 1,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		game := entities.Game{Words: test.words, Attempts: test.attempts}
+
+		got := CheckHardMode(game, test.attempt)
+		switch {
+		case got == nil && test.want == nil:
+		case got == nil || test.want == nil || *got != *test.want:
+			t.Errorf("%s: got violation %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
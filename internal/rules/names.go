@@ -0,0 +1,161 @@
+package rules
+
+import (
+	"strings"
+	"unicode"
+)
+
+// defaultReservedNames can't be taken by any user, as they could be mistaken for the staff or the game itself
+var defaultReservedNames = []string{
+	"admin",
+	"administrator",
+	"administrador",
+	"anonymous",
+	"everyone",
+	"help",
+	"mod",
+	"moderator",
+	"moderador",
+	"null",
+	"official",
+	"oficial",
+	"root",
+	"staff",
+	"suporte",
+	"support",
+	"system",
+	"sistema",
+	"termo",
+	"undefined",
+}
+
+// defaultBlockedWords can't start any word of a name. The list is kept short and only has words that are unlikely to
+// start harmless words; more words can be added through the config
+var defaultBlockedWords = []string{
+	"arrombad",
+	"asshole",
+	"bitch",
+	"buceta",
+	"caralho",
+	"cunt",
+	"cuzao",
+	"faggot",
+	"filhodaputa",
+	"fodase",
+	"fuck",
+	"merda",
+	"nigga",
+	"nigger",
+	"piroca",
+	"porra",
+	"punheta",
+	"putaria",
+	"pussy",
+	"shit",
+	"whore",
+	"xoxota",
+}
+
+// leetReplacer undoes the digit substitutions commonly used to get around blocked words
+var leetReplacer = strings.NewReplacer(
+	"0", "o",
+	"1", "i",
+	"3", "e",
+	"4", "a",
+	"5", "s",
+	"7", "t",
+)
+
+// NamePolicy decides which valid names can be taken, blocking reserved names and names with blocked words
+type NamePolicy struct {
+	reserved     map[string]bool
+	blockedWords []string
+}
+
+// NewNamePolicy builds a NamePolicy with the default lists plus the provided reserved names and blocked words. Both
+// are matched ignoring upper/lowercase letters
+func NewNamePolicy(reservedNames []string, blockedWords []string) NamePolicy {
+	policy := NamePolicy{
+		reserved: make(map[string]bool),
+	}
+
+	for _, name := range append(defaultReservedNames, reservedNames...) {
+		policy.reserved[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	for _, word := range append(defaultBlockedWords, blockedWords...) {
+		word = normalizeName(word)
+		if word != "" {
+			policy.blockedWords = append(policy.blockedWords, word)
+		}
+	}
+
+	return policy
+}
+
+// IsAllowed checks whether a valid name can be taken. Expects it to be already trimmed
+//
+// A name is not allowed if it's reserved, ignoring upper/lowercase letters and underscores, or if any of its words
+// starts with a blocked word, also after undoing digit substitutions such as 0 for o. Words are split by underscores
+// and by uppercase letters, as in snake_case and camelCase; the whole name without underscores also counts as a word,
+// so that spacing a blocked word out doesn't get around it
+//
+// Blocked words are only matched at the start of words, so that harmless names merely containing one are allowed
+func (p NamePolicy) IsAllowed(name string) bool {
+	lower := strings.ToLower(name)
+	if p.reserved[lower] || p.reserved[strings.ReplaceAll(lower, "_", "")] {
+		return false
+	}
+
+	words := append(splitNameWords(name), strings.ReplaceAll(name, "_", ""))
+	for _, word := range words {
+		normalized := normalizeName(word)
+		for _, blocked := range p.blockedWords {
+			if strings.HasPrefix(normalized, blocked) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// splitNameWords splits a name into words by underscores and by uppercase letters that start a word, as in
+// "snake_case", "camelCase" and "HTTPServer"
+func splitNameWords(name string) []string {
+	var (
+		words []string
+		start int
+	)
+	runes := []rune(name)
+	for i, r := range runes {
+		if r == '_' {
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+
+		// An uppercase letter starts a word after a lowercase letter or digit, or before a lowercase letter when it
+		// ends a run of uppercase letters
+		if i > start && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || nextLower {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
+
+// normalizeName lowercases a name and undoes digit substitutions, so that blocked words can be found in it
+func normalizeName(name string) string {
+	return leetReplacer.Replace(strings.ToLower(strings.TrimSpace(name)))
+}
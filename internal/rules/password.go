@@ -0,0 +1,218 @@
+package rules
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"termo_back_end/internal/entities"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Default password length bounds
+const (
+	defaultMinPasswordLength = 8
+	defaultMaxPasswordLength = MaxUserPasswordLength
+)
+
+// PasswordProblem is a rule of the password policy broken by a password
+type PasswordProblem string
+
+const (
+	PasswordTooShort         PasswordProblem = "TOO_SHORT"
+	PasswordTooLong          PasswordProblem = "TOO_LONG"
+	PasswordMissingUppercase PasswordProblem = "MISSING_UPPERCASE"
+	PasswordMissingLowercase PasswordProblem = "MISSING_LOWERCASE"
+	PasswordMissingDigit     PasswordProblem = "MISSING_DIGIT"
+	PasswordMissingSymbol    PasswordProblem = "MISSING_SYMBOL"
+	PasswordInvalidCharacter PasswordProblem = "INVALID_CHARACTER"
+	PasswordCommon           PasswordProblem = "COMMON"
+	PasswordSimilarToName    PasswordProblem = "SIMILAR_TO_NAME"
+)
+
+// passwordClassProblems are the problems of passwords missing each character class
+var passwordClassProblems = map[string]PasswordProblem{
+	entities.PasswordClassUppercase: PasswordMissingUppercase,
+	entities.PasswordClassLowercase: PasswordMissingLowercase,
+	entities.PasswordClassDigit:     PasswordMissingDigit,
+	entities.PasswordClassSymbol:    PasswordMissingSymbol,
+}
+
+// defaultCommonPasswords can't be used by anyone, since they are the first ones tried when guessing passwords. More
+// passwords, such as a list of breached ones, can be added through the config
+var defaultCommonPasswords = []string{
+	"111111",
+	"123123",
+	"123456",
+	"1234567",
+	"12345678",
+	"123456789",
+	"1234567890",
+	"12345678910",
+	"1q2w3e4r",
+	"1q2w3e4r5t",
+	"abc123",
+	"abc@123",
+	"admin123",
+	"admin@123",
+	"brasil123",
+	"changeme",
+	"iloveyou",
+	"letmein",
+	"mudar123",
+	"mudar@123",
+	"p@ssw0rd",
+	"p@ssword1",
+	"passw0rd",
+	"password",
+	"password1",
+	"password1!",
+	"password123",
+	"password@123",
+	"qwe123",
+	"qwerty",
+	"qwerty123",
+	"qwerty@123",
+	"senha123",
+	"senha@123",
+	"termo123",
+	"termo@123",
+	"trustno1",
+	"welcome1",
+	"welcome@123",
+}
+
+// PasswordPolicy decides which passwords can be used, according to the password policy config
+type PasswordPolicy struct {
+	minLength        uint32
+	maxLength        uint32
+	requiredClasses  []string
+	passphraseLength uint32
+	common           map[string]bool
+	checkName        bool
+}
+
+// NewPasswordPolicy builds a PasswordPolicy from the config, blocking the default common passwords plus the provided
+// ones, ignoring upper/lowercase letters
+func NewPasswordPolicy(config entities.Config, commonPasswords []string) (PasswordPolicy, error) {
+	policyConfig := config.Auth.PasswordPolicy
+
+	policy := PasswordPolicy{
+		minLength:        policyConfig.MinLength,
+		maxLength:        policyConfig.MaxLength,
+		requiredClasses:  policyConfig.RequiredClasses,
+		passphraseLength: policyConfig.PassphraseLength,
+		common:           make(map[string]bool),
+		checkName:        !policyConfig.AllowSimilarToName,
+	}
+
+	if policy.minLength == 0 {
+		policy.minLength = defaultMinPasswordLength
+	}
+	if policy.maxLength == 0 {
+		policy.maxLength = defaultMaxPasswordLength
+	}
+	if policy.maxLength > MaxUserPasswordLength {
+		return PasswordPolicy{}, fmt.Errorf("max password length can't be over %d", MaxUserPasswordLength)
+	}
+	if policy.minLength > policy.maxLength {
+		return PasswordPolicy{}, fmt.Errorf("min password length can't be over the max length")
+	}
+
+	// Every class is required if none were configured, as opposed to an empty list
+	if policy.requiredClasses == nil {
+		policy.requiredClasses = []string{
+			entities.PasswordClassUppercase,
+			entities.PasswordClassLowercase,
+			entities.PasswordClassDigit,
+			entities.PasswordClassSymbol,
+		}
+	}
+	for _, class := range policy.requiredClasses {
+		if _, ok := passwordClassProblems[class]; !ok {
+			return PasswordPolicy{}, fmt.Errorf("unknown password character class: %s", class)
+		}
+	}
+
+	for _, password := range append(defaultCommonPasswords, commonPasswords...) {
+		if password != "" {
+			policy.common[strings.ToLower(password)] = true
+		}
+	}
+
+	return policy, nil
+}
+
+// Check returns the rules a password breaks, or nil if it can be used. The name is the one of the user the password
+// is for, so that passwords similar to it can be rejected
+//
+// The length is counted in characters, and only control characters are invalid, so that passphrases may have spaces.
+// Passwords at least as long as the passphrase length don't need any character class
+func (p PasswordPolicy) Check(password string, name string) []PasswordProblem {
+	var problems []PasswordProblem
+
+	length := uint32(utf8.RuneCountInString(password))
+	if length < p.minLength {
+		problems = append(problems, PasswordTooShort)
+	}
+	if length > p.maxLength {
+		problems = append(problems, PasswordTooLong)
+	}
+
+	classes := make(map[string]bool)
+	invalid := false
+	for _, char := range password {
+		switch {
+		case unicode.IsUpper(char):
+			classes[entities.PasswordClassUppercase] = true
+		case unicode.IsLower(char):
+			classes[entities.PasswordClassLowercase] = true
+		case unicode.IsNumber(char):
+			classes[entities.PasswordClassDigit] = true
+		case unicode.IsPunct(char) || unicode.IsSymbol(char):
+			classes[entities.PasswordClassSymbol] = true
+		case unicode.IsControl(char) || char == utf8.RuneError:
+			invalid = true
+		}
+	}
+	if invalid {
+		problems = append(problems, PasswordInvalidCharacter)
+	}
+
+	isPassphrase := p.passphraseLength > 0 && length >= p.passphraseLength
+	if !isPassphrase {
+		for _, class := range p.requiredClasses {
+			if !classes[class] {
+				problems = append(problems, passwordClassProblems[class])
+			}
+		}
+	}
+
+	lower := strings.ToLower(password)
+	if p.common[lower] {
+		problems = append(problems, PasswordCommon)
+	}
+
+	if p.checkName && isSimilarToName(lower, strings.ToLower(name)) {
+		problems = append(problems, PasswordSimilarToName)
+	}
+
+	return problems
+}
+
+// isSimilarToName checks whether a lowercase password contains a lowercase name, either forwards or backwards, or is
+// contained by it, ignoring underscores
+func isSimilarToName(password string, name string) bool {
+	password = strings.ReplaceAll(password, "_", "")
+	name = strings.ReplaceAll(name, "_", "")
+	if name == "" || password == "" {
+		return false
+	}
+
+	reversed := []rune(name)
+	slices.Reverse(reversed)
+
+	return strings.Contains(password, name) ||
+		strings.Contains(password, string(reversed)) ||
+		strings.Contains(name, password)
+}
@@ -0,0 +1,146 @@
+package rules
+
+import (
+	"slices"
+	"strings"
+	"termo_back_end/internal/entities"
+	"testing"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	var defaultConfig entities.Config
+
+	var passphraseConfig entities.Config
+	passphraseConfig.Auth.PasswordPolicy.PassphraseLength = 20
+
+	var relaxedConfig entities.Config
+	relaxedConfig.Auth.PasswordPolicy.MinLength = 4
+	relaxedConfig.Auth.PasswordPolicy.MaxLength = 12
+	relaxedConfig.Auth.PasswordPolicy.RequiredClasses = []string{}
+	relaxedConfig.Auth.PasswordPolicy.AllowSimilarToName = true
+
+	tests := []struct {
+		name     string
+		config   entities.Config
+		common   []string
+		password string
+		want     []PasswordProblem
+	}{
+		{
+			name:     "valid",
+			config:   defaultConfig,
+			password: "Abcdef1!",
+		},
+		{
+			name:     "characters counted instead of bytes",
+			config:   defaultConfig,
+			password: "Ação1!",
+			want:     []PasswordProblem{PasswordTooShort},
+		},
+		{
+			name:     "too long",
+			config:   defaultConfig,
+			password: strings.Repeat("Aa1!", 65),
+			want:     []PasswordProblem{PasswordTooLong},
+		},
+		{
+			name:     "missing classes",
+			config:   defaultConfig,
+			password: "abcdefgh",
+			want:     []PasswordProblem{PasswordMissingUppercase, PasswordMissingDigit, PasswordMissingSymbol},
+		},
+		{
+			name:     "control character",
+			config:   defaultConfig,
+			password: "Abc\x01def1!",
+			want:     []PasswordProblem{PasswordInvalidCharacter},
+		},
+		{
+			name:     "built-in common password",
+			config:   defaultConfig,
+			password: "Password1!",
+			want:     []PasswordProblem{PasswordCommon},
+		},
+		{
+			name:     "configured common password",
+			config:   defaultConfig,
+			common:   []string{"termo-2024!"},
+			password: "Termo-2024!",
+			want:     []PasswordProblem{PasswordCommon},
+		},
+		{
+			name:     "contains the name",
+			config:   defaultConfig,
+			password: "Player-123",
+			want:     []PasswordProblem{PasswordSimilarToName},
+		},
+		{
+			name:     "contains the name backwards",
+			config:   defaultConfig,
+			password: "Reyalp-123",
+			want:     []PasswordProblem{PasswordSimilarToName},
+		},
+		{
+			name:     "passphrase without classes",
+			config:   passphraseConfig,
+			password: "correct horse battery staple",
+		},
+		{
+			name:     "short passphrase",
+			config:   passphraseConfig,
+			password: "correct horse",
+			want:     []PasswordProblem{PasswordMissingUppercase, PasswordMissingDigit, PasswordMissingSymbol},
+		},
+		{
+			name:     "no required classes",
+			config:   relaxedConfig,
+			password: "abcd",
+		},
+		{
+			name:     "configured max length",
+			config:   relaxedConfig,
+			password: "abcdefghijklm",
+			want:     []PasswordProblem{PasswordTooLong},
+		},
+		{
+			name:     "name allowed",
+			config:   relaxedConfig,
+			password: "player",
+		},
+	}
+
+	for _, test := range tests {
+		policy, err := NewPasswordPolicy(test.config, test.common)
+		if err != nil {
+			t.Fatalf("%s: [NewPasswordPolicy] | %v", test.name, err)
+		}
+
+		got := policy.Check(test.password, "player")
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: got problems %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNewPasswordPolicyInvalid(t *testing.T) {
+	var unknownClass entities.Config
+	unknownClass.Auth.PasswordPolicy.RequiredClasses = []string{"emoji"}
+
+	var tooLong entities.Config
+	tooLong.Auth.PasswordPolicy.MaxLength = MaxUserPasswordLength + 1
+
+	var minOverMax entities.Config
+	minOverMax.Auth.PasswordPolicy.MinLength = 20
+	minOverMax.Auth.PasswordPolicy.MaxLength = 10
+
+	for name, config := range map[string]entities.Config{
+		"unknown class": unknownClass,
+		"max too long":  tooLong,
+		"min over max":  minOverMax,
+	} {
+		_, err := NewPasswordPolicy(config, nil)
+		if err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
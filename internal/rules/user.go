@@ -1,46 +1,35 @@
 package rules
 
 import (
+	"net/mail"
 	"regexp"
-	"unicode"
+	"strings"
 )
 
+// MaxUserPasswordLength is the most characters the password policy can allow. Passwords are hashed with argon2id,
+// which has no length limit of its own, so this only keeps hashing cheap
+const MaxUserPasswordLength = 256
+
+// GuestNamePrefix starts the generated names of guest users, and can't be used by registered users
+const GuestNamePrefix = "guest_"
+
 // IsValidUserName checks whether a name is valid. Expects it to be already trimmed
 //
-// For a name to be valid, it must have between 3 and 32 characters and only lower/uppercase letters and digits
+// For a name to be valid, it must have between 3 and 32 characters and only lower/uppercase letters, digits and
+// underscores, and it can't start with GuestNamePrefix
 func IsValidUserName(name string) bool {
 	re := regexp.MustCompile(`^[a-zA-Z0-9_]{3,32}$`)
-	return re.MatchString(name)
+	return re.MatchString(name) && !strings.HasPrefix(strings.ToLower(name), GuestNamePrefix)
 }
 
-// IsValidUserPassword checks whether a password is valid. For a password to be valid, it can't be longer than 72
-// characters and must have at least:
+// IsValidUserEmail checks whether an email address is valid. Expects it to be already trimmed
 //
-//   - 8 characters
-//   - an uppercase letter
-//   - a lowercase letter
-//   - a digit
-//   - a special symbol, such as !@#$%¨&*()
-func IsValidUserPassword(password string) bool {
-	if len(password) < 8 || len(password) > 72 {
+// For an email address to be valid, it must be a plain address, without a display name, and have at most 254 characters
+func IsValidUserEmail(email string) bool {
+	if len(email) > 254 {
 		return false
 	}
 
-	var upp, low, num, sym bool
-	for _, char := range password {
-		switch {
-		case unicode.IsUpper(char):
-			upp = true
-		case unicode.IsLower(char):
-			low = true
-		case unicode.IsNumber(char):
-			num = true
-		case unicode.IsPunct(char) || unicode.IsSymbol(char):
-			sym = true
-		default:
-			return false
-		}
-	}
-
-	return upp && low && num && sym
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
 }
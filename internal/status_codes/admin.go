@@ -0,0 +1,119 @@
+package status_codes
+
+type AdminBan int64
+type AdminUnban int64
+type AdminResetScore int64
+type AdminFinishGame int64
+type AdminSetRole int64
+type AdminNameHistory int64
+
+const (
+	AdminBanSuccess AdminBan = iota
+	AdminBanNotFound
+	AdminBanIsAdmin
+)
+
+const (
+	AdminUnbanSuccess AdminUnban = iota
+	AdminUnbanNotFound
+	AdminUnbanNotBanned
+)
+
+const (
+	AdminResetScoreSuccess AdminResetScore = iota
+	AdminResetScoreNotFound
+)
+
+const (
+	AdminFinishGameSuccess AdminFinishGame = iota
+	AdminFinishGameNotFound
+	AdminFinishGameNoActiveGame
+)
+
+const (
+	AdminSetRoleSuccess AdminSetRole = iota
+	AdminSetRoleNotFound
+	AdminSetRoleInvalidRole
+	AdminSetRoleOwnRole
+)
+
+const (
+	AdminNameHistorySuccess AdminNameHistory = iota
+	AdminNameHistoryNotFound
+)
+
+func (c AdminBan) String() string {
+	switch c {
+	case AdminBanSuccess:
+		return "SUCCESS"
+	case AdminBanNotFound:
+		return "NOT_FOUND"
+	case AdminBanIsAdmin:
+		return "IS_ADMIN"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c AdminUnban) String() string {
+	switch c {
+	case AdminUnbanSuccess:
+		return "SUCCESS"
+	case AdminUnbanNotFound:
+		return "NOT_FOUND"
+	case AdminUnbanNotBanned:
+		return "NOT_BANNED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c AdminResetScore) String() string {
+	switch c {
+	case AdminResetScoreSuccess:
+		return "SUCCESS"
+	case AdminResetScoreNotFound:
+		return "NOT_FOUND"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c AdminFinishGame) String() string {
+	switch c {
+	case AdminFinishGameSuccess:
+		return "SUCCESS"
+	case AdminFinishGameNotFound:
+		return "NOT_FOUND"
+	case AdminFinishGameNoActiveGame:
+		return "NO_ACTIVE_GAME"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c AdminSetRole) String() string {
+	switch c {
+	case AdminSetRoleSuccess:
+		return "SUCCESS"
+	case AdminSetRoleNotFound:
+		return "NOT_FOUND"
+	case AdminSetRoleInvalidRole:
+		return "INVALID_ROLE"
+	case AdminSetRoleOwnRole:
+		return "OWN_ROLE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c AdminNameHistory) String() string {
+	switch c {
+	case AdminNameHistorySuccess:
+		return "SUCCESS"
+	case AdminNameHistoryNotFound:
+		return "NOT_FOUND"
+	default:
+		return "UNKNOWN"
+	}
+}
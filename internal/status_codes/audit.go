@@ -0,0 +1,19 @@
+package status_codes
+
+type AuditEventsGet int64
+
+const (
+	AuditEventsGetSuccess AuditEventsGet = iota
+	AuditEventsGetInvalidCursor
+)
+
+func (c AuditEventsGet) String() string {
+	switch c {
+	case AuditEventsGetSuccess:
+		return "SUCCESS"
+	case AuditEventsGetInvalidCursor:
+		return "INVALID_CURSOR"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -0,0 +1,173 @@
+package status_codes
+
+type TokenRefresh int64
+type UserLogout int64
+type PasswordResetRequest int64
+type PasswordResetConfirm int64
+type LoginSecondFactor int64
+type TwoFactorEnroll int64
+type TwoFactorConfirm int64
+type TwoFactorDisable int64
+type StreamTicket int64
+
+const (
+	TokenRefreshSuccess TokenRefresh = iota
+	TokenRefreshInvalidToken
+)
+
+const (
+	UserLogoutSuccess UserLogout = iota
+)
+
+const (
+	PasswordResetRequestSuccess PasswordResetRequest = iota
+	PasswordResetRequestInvalidEmail
+)
+
+const (
+	PasswordResetConfirmSuccess PasswordResetConfirm = iota
+	PasswordResetConfirmInvalidToken
+	PasswordResetConfirmInvalidPassword
+)
+
+const (
+	LoginSecondFactorSuccess LoginSecondFactor = iota
+	LoginSecondFactorInvalidChallenge
+	LoginSecondFactorInvalidCode
+	LoginSecondFactorTooManyAttempts
+	LoginSecondFactorBanned
+)
+
+const (
+	TwoFactorEnrollSuccess TwoFactorEnroll = iota
+	TwoFactorEnrollAlreadyEnabled
+)
+
+const (
+	TwoFactorConfirmSuccess TwoFactorConfirm = iota
+	TwoFactorConfirmNotEnrolled
+	TwoFactorConfirmAlreadyEnabled
+	TwoFactorConfirmInvalidCode
+)
+
+const (
+	TwoFactorDisableSuccess TwoFactorDisable = iota
+	TwoFactorDisableNotEnabled
+	TwoFactorDisableWrongPassword
+	TwoFactorDisableInvalidCode
+)
+
+const (
+	StreamTicketSuccess StreamTicket = iota
+)
+
+func (c TokenRefresh) String() string {
+	switch c {
+	case TokenRefreshSuccess:
+		return "SUCCESS"
+	case TokenRefreshInvalidToken:
+		return "INVALID_TOKEN"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c UserLogout) String() string {
+	switch c {
+	case UserLogoutSuccess:
+		return "SUCCESS"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c PasswordResetRequest) String() string {
+	switch c {
+	case PasswordResetRequestSuccess:
+		return "SUCCESS"
+	case PasswordResetRequestInvalidEmail:
+		return "INVALID_EMAIL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c PasswordResetConfirm) String() string {
+	switch c {
+	case PasswordResetConfirmSuccess:
+		return "SUCCESS"
+	case PasswordResetConfirmInvalidToken:
+		return "INVALID_TOKEN"
+	case PasswordResetConfirmInvalidPassword:
+		return "INVALID_PASSWORD"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c LoginSecondFactor) String() string {
+	switch c {
+	case LoginSecondFactorSuccess:
+		return "SUCCESS"
+	case LoginSecondFactorInvalidChallenge:
+		return "INVALID_CHALLENGE"
+	case LoginSecondFactorInvalidCode:
+		return "INVALID_CODE"
+	case LoginSecondFactorTooManyAttempts:
+		return "TOO_MANY_ATTEMPTS"
+	case LoginSecondFactorBanned:
+		return "BANNED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c TwoFactorEnroll) String() string {
+	switch c {
+	case TwoFactorEnrollSuccess:
+		return "SUCCESS"
+	case TwoFactorEnrollAlreadyEnabled:
+		return "ALREADY_ENABLED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c TwoFactorConfirm) String() string {
+	switch c {
+	case TwoFactorConfirmSuccess:
+		return "SUCCESS"
+	case TwoFactorConfirmNotEnrolled:
+		return "NOT_ENROLLED"
+	case TwoFactorConfirmAlreadyEnabled:
+		return "ALREADY_ENABLED"
+	case TwoFactorConfirmInvalidCode:
+		return "INVALID_CODE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c TwoFactorDisable) String() string {
+	switch c {
+	case TwoFactorDisableSuccess:
+		return "SUCCESS"
+	case TwoFactorDisableNotEnabled:
+		return "NOT_ENABLED"
+	case TwoFactorDisableWrongPassword:
+		return "WRONG_PASSWORD"
+	case TwoFactorDisableInvalidCode:
+		return "INVALID_CODE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c StreamTicket) String() string {
+	switch c {
+	case StreamTicketSuccess:
+		return "SUCCESS"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -0,0 +1,59 @@
+package status_codes
+
+type ChallengeCreate int64
+type ChallengeGet int64
+type ChallengeResults int64
+
+const (
+	ChallengeCreateSuccess ChallengeCreate = iota
+	ChallengeCreateInvalidWordLength
+	ChallengeCreateInvalidCount
+	ChallengeCreateInvalidWords
+)
+
+const (
+	ChallengeGetSuccess ChallengeGet = iota
+	ChallengeGetNotFound
+)
+
+const (
+	ChallengeResultsSuccess ChallengeResults = iota
+	ChallengeResultsNotFound
+)
+
+func (c ChallengeCreate) String() string {
+	switch c {
+	case ChallengeCreateSuccess:
+		return "SUCCESS"
+	case ChallengeCreateInvalidWordLength:
+		return "INVALID_WORD_LENGTH"
+	case ChallengeCreateInvalidCount:
+		return "INVALID_COUNT"
+	case ChallengeCreateInvalidWords:
+		return "INVALID_WORDS"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c ChallengeGet) String() string {
+	switch c {
+	case ChallengeGetSuccess:
+		return "SUCCESS"
+	case ChallengeGetNotFound:
+		return "NOT_FOUND"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c ChallengeResults) String() string {
+	switch c {
+	case ChallengeResultsSuccess:
+		return "SUCCESS"
+	case ChallengeResultsNotFound:
+		return "NOT_FOUND"
+	default:
+		return "UNKNOWN"
+	}
+}
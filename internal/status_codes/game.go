@@ -2,18 +2,43 @@ package status_codes
 
 type GameStart int64
 type GameAttempt int64
+type GameForfeit int64
+type GameHistory int64
+type GameReplay int64
 
 const (
 	GameStartSuccess GameStart = iota
 	GameStartActiveGame
 	GameStartInvalidWordLength
 	GameStartInvalidCount
+	GameStartDailyAlreadyPlayed
+	GameStartChallengeNotFound
+	GameStartChallengeAlreadyPlayed
+	GameStartOwnChallenge
 )
 
 const (
 	GameAttemptSuccess GameAttempt = iota
 	GameAttemptNoActiveGame
 	GameAttemptInvalid
+	GameAttemptNotInDictionary
+	GameAttemptHardModeViolation
+)
+
+const (
+	GameForfeitSuccess GameForfeit = iota
+	GameForfeitNoActiveGame
+)
+
+const (
+	GameHistorySuccess GameHistory = iota
+	GameHistoryInvalidCursor
+)
+
+const (
+	GameReplaySuccess GameReplay = iota
+	GameReplayNotFound
+	GameReplayInProgress
 )
 
 func (c GameStart) String() string {
@@ -26,6 +51,14 @@ func (c GameStart) String() string {
 		return "INVALID_WORD_LENGTH"
 	case GameStartInvalidCount:
 		return "INVALID_COUNT"
+	case GameStartDailyAlreadyPlayed:
+		return "DAILY_ALREADY_PLAYED"
+	case GameStartChallengeNotFound:
+		return "CHALLENGE_NOT_FOUND"
+	case GameStartChallengeAlreadyPlayed:
+		return "CHALLENGE_ALREADY_PLAYED"
+	case GameStartOwnChallenge:
+		return "OWN_CHALLENGE"
 	default:
 		return "UNKNOWN"
 	}
@@ -39,6 +72,45 @@ func (c GameAttempt) String() string {
 		return "NO_ACTIVE_GAME"
 	case GameAttemptInvalid:
 		return "INVALID"
+	case GameAttemptNotInDictionary:
+		return "NOT_IN_DICTIONARY"
+	case GameAttemptHardModeViolation:
+		return "HARD_MODE_VIOLATION"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c GameForfeit) String() string {
+	switch c {
+	case GameForfeitSuccess:
+		return "SUCCESS"
+	case GameForfeitNoActiveGame:
+		return "NO_ACTIVE_GAME"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c GameHistory) String() string {
+	switch c {
+	case GameHistorySuccess:
+		return "SUCCESS"
+	case GameHistoryInvalidCursor:
+		return "INVALID_CURSOR"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c GameReplay) String() string {
+	switch c {
+	case GameReplaySuccess:
+		return "SUCCESS"
+	case GameReplayNotFound:
+		return "NOT_FOUND"
+	case GameReplayInProgress:
+		return "IN_PROGRESS"
 	default:
 		return "UNKNOWN"
 	}
@@ -0,0 +1,19 @@
+package status_codes
+
+type LeaderboardGet int64
+
+const (
+	LeaderboardGetSuccess LeaderboardGet = iota
+	LeaderboardGetInvalidWindow
+)
+
+func (c LeaderboardGet) String() string {
+	switch c {
+	case LeaderboardGetSuccess:
+		return "SUCCESS"
+	case LeaderboardGetInvalidWindow:
+		return "INVALID_WINDOW"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -0,0 +1,163 @@
+package status_codes
+
+type LobbySettings int64
+type LobbyJoin int64
+type LobbyGet int64
+type LobbyLeave int64
+type LobbyStart int64
+type LobbyAttempt int64
+type LobbyHostAction int64
+
+const (
+	LobbySettingsSuccess LobbySettings = iota
+	LobbySettingsNotFound
+	LobbySettingsNotHost
+	LobbySettingsRoundInProgress
+	LobbySettingsInvalidWordLength
+	LobbySettingsInvalidCount
+	LobbySettingsInvalidMaxPlayers
+	LobbySettingsInvalidRoundTime
+)
+
+const (
+	LobbyJoinSuccess LobbyJoin = iota
+	LobbyJoinNotFound
+	LobbyJoinFull
+)
+
+const (
+	LobbyGetSuccess LobbyGet = iota
+	LobbyGetNotFound
+)
+
+const (
+	LobbyLeaveSuccess LobbyLeave = iota
+	LobbyLeaveNotFound
+)
+
+const (
+	LobbyStartSuccess LobbyStart = iota
+	LobbyStartNotFound
+	LobbyStartNotHost
+	LobbyStartRoundInProgress
+)
+
+const (
+	LobbyAttemptSuccess LobbyAttempt = iota
+	LobbyAttemptNotFound
+	LobbyAttemptNotPlaying
+	LobbyAttemptInvalid
+	LobbyAttemptNotInDictionary
+)
+
+const (
+	LobbyHostActionSuccess LobbyHostAction = iota
+	LobbyHostActionNotFound
+	LobbyHostActionNotHost
+	LobbyHostActionPlayerNotFound
+)
+
+func (c LobbySettings) String() string {
+	switch c {
+	case LobbySettingsSuccess:
+		return "SUCCESS"
+	case LobbySettingsNotFound:
+		return "NOT_FOUND"
+	case LobbySettingsNotHost:
+		return "NOT_HOST"
+	case LobbySettingsRoundInProgress:
+		return "ROUND_IN_PROGRESS"
+	case LobbySettingsInvalidWordLength:
+		return "INVALID_WORD_LENGTH"
+	case LobbySettingsInvalidCount:
+		return "INVALID_COUNT"
+	case LobbySettingsInvalidMaxPlayers:
+		return "INVALID_MAX_PLAYERS"
+	case LobbySettingsInvalidRoundTime:
+		return "INVALID_ROUND_TIME"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c LobbyJoin) String() string {
+	switch c {
+	case LobbyJoinSuccess:
+		return "SUCCESS"
+	case LobbyJoinNotFound:
+		return "NOT_FOUND"
+	case LobbyJoinFull:
+		return "FULL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c LobbyGet) String() string {
+	switch c {
+	case LobbyGetSuccess:
+		return "SUCCESS"
+	case LobbyGetNotFound:
+		return "NOT_FOUND"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c LobbyLeave) String() string {
+	switch c {
+	case LobbyLeaveSuccess:
+		return "SUCCESS"
+	case LobbyLeaveNotFound:
+		return "NOT_FOUND"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c LobbyStart) String() string {
+	switch c {
+	case LobbyStartSuccess:
+		return "SUCCESS"
+	case LobbyStartNotFound:
+		return "NOT_FOUND"
+	case LobbyStartNotHost:
+		return "NOT_HOST"
+	case LobbyStartRoundInProgress:
+		return "ROUND_IN_PROGRESS"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c LobbyAttempt) String() string {
+	switch c {
+	case LobbyAttemptSuccess:
+		return "SUCCESS"
+	case LobbyAttemptNotFound:
+		return "NOT_FOUND"
+	case LobbyAttemptNotPlaying:
+		return "NOT_PLAYING"
+	case LobbyAttemptInvalid:
+		return "INVALID"
+	case LobbyAttemptNotInDictionary:
+		return "NOT_IN_DICTIONARY"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c LobbyHostAction) String() string {
+	switch c {
+	case LobbyHostActionSuccess:
+		return "SUCCESS"
+	case LobbyHostActionNotFound:
+		return "NOT_FOUND"
+	case LobbyHostActionNotHost:
+		return "NOT_HOST"
+	case LobbyHostActionPlayerNotFound:
+		return "PLAYER_NOT_FOUND"
+	default:
+		return "UNKNOWN"
+	}
+}
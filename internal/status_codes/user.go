@@ -4,23 +4,36 @@ type UserRegister int64
 type UserLogin int64
 type UserUpdateName int64
 type UserUpdatePassword int64
+type UserUpdateEmail int64
+type UserUpgrade int64
+type UserDelete int64
 
 const (
 	UserRegisterSuccess UserRegister = iota
 	UserRegisterInvalidName
 	UserRegisterInvalidPassword
 	UserRegisterAlreadyRegistered
+	UserRegisterTooManyAttempts
+	UserRegisterInvalidEmail
+	UserRegisterNameNotAllowed
 )
 
 const (
 	UserLoginSuccess UserLogin = iota
 	UserLoginNotFound
 	UserLoginWrongPassword
+	UserLoginTooManyAttempts
+	UserLoginInvalidCredentials
+	UserLoginSecondFactorRequired
+	UserLoginBanned
 )
 
 const (
 	UserUpdateNameSuccess UserUpdateName = iota
 	UserUpdateNameInvalid
+	UserUpdateNameTaken
+	UserUpdateNameNotAllowed
+	UserUpdateNameCooldown
 )
 
 const (
@@ -29,6 +42,26 @@ const (
 	UserUpdatePasswordInvalid
 )
 
+const (
+	UserDeleteSuccess UserDelete = iota
+	UserDeleteWrongPassword
+)
+
+const (
+	UserUpgradeSuccess UserUpgrade = iota
+	UserUpgradeNotGuest
+	UserUpgradeInvalidName
+	UserUpgradeInvalidPassword
+	UserUpgradeInvalidEmail
+	UserUpgradeAlreadyRegistered
+	UserUpgradeNameNotAllowed
+)
+
+const (
+	UserUpdateEmailSuccess UserUpdateEmail = iota
+	UserUpdateEmailInvalid
+)
+
 func (c UserRegister) String() string {
 	switch c {
 	case UserRegisterSuccess:
@@ -39,6 +72,12 @@ func (c UserRegister) String() string {
 		return "INVALID_PASSWORD"
 	case UserRegisterAlreadyRegistered:
 		return "ALREADY_REGISTERED"
+	case UserRegisterTooManyAttempts:
+		return "TOO_MANY_ATTEMPTS"
+	case UserRegisterInvalidEmail:
+		return "INVALID_EMAIL"
+	case UserRegisterNameNotAllowed:
+		return "NAME_NOT_ALLOWED"
 	default:
 		return "UNKNOWN"
 	}
@@ -52,6 +91,14 @@ func (c UserLogin) String() string {
 		return "NOT_FOUND"
 	case UserLoginWrongPassword:
 		return "WRONG_PASSWORD"
+	case UserLoginTooManyAttempts:
+		return "TOO_MANY_ATTEMPTS"
+	case UserLoginInvalidCredentials:
+		return "INVALID_CREDENTIALS"
+	case UserLoginSecondFactorRequired:
+		return "SECOND_FACTOR_REQUIRED"
+	case UserLoginBanned:
+		return "BANNED"
 	default:
 		return "UNKNOWN"
 	}
@@ -63,6 +110,12 @@ func (c UserUpdateName) String() string {
 		return "SUCCESS"
 	case UserUpdateNameInvalid:
 		return "INVALID"
+	case UserUpdateNameTaken:
+		return "TAKEN"
+	case UserUpdateNameNotAllowed:
+		return "NOT_ALLOWED"
+	case UserUpdateNameCooldown:
+		return "COOLDOWN"
 	default:
 		return "UNKNOWN"
 	}
@@ -80,3 +133,46 @@ func (c UserUpdatePassword) String() string {
 		return "UNKNOWN"
 	}
 }
+
+func (c UserUpdateEmail) String() string {
+	switch c {
+	case UserUpdateEmailSuccess:
+		return "SUCCESS"
+	case UserUpdateEmailInvalid:
+		return "INVALID"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c UserUpgrade) String() string {
+	switch c {
+	case UserUpgradeSuccess:
+		return "SUCCESS"
+	case UserUpgradeNotGuest:
+		return "NOT_GUEST"
+	case UserUpgradeInvalidName:
+		return "INVALID_NAME"
+	case UserUpgradeInvalidPassword:
+		return "INVALID_PASSWORD"
+	case UserUpgradeInvalidEmail:
+		return "INVALID_EMAIL"
+	case UserUpgradeAlreadyRegistered:
+		return "ALREADY_REGISTERED"
+	case UserUpgradeNameNotAllowed:
+		return "NAME_NOT_ALLOWED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c UserDelete) String() string {
+	switch c {
+	case UserDeleteSuccess:
+		return "SUCCESS"
+	case UserDeleteWrongPassword:
+		return "WRONG_PASSWORD"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -0,0 +1,111 @@
+package status_codes
+
+type VersusCreate int64
+type VersusJoin int64
+type VersusStart int64
+type VersusAttempt int64
+type VersusForfeit int64
+
+const (
+	VersusCreateSuccess VersusCreate = iota
+	VersusCreateInvalidWordLength
+	VersusCreateInvalidCount
+	VersusCreateInvalidMaxPlayers
+)
+
+const (
+	VersusJoinSuccess VersusJoin = iota
+	VersusJoinNotFound
+	VersusJoinFull
+	VersusJoinAlreadyStarted
+)
+
+const (
+	VersusStartSuccess VersusStart = iota
+	VersusStartNotHost
+	VersusStartNotEnoughPlayers
+	VersusStartAlreadyStarted
+)
+
+const (
+	VersusAttemptSuccess VersusAttempt = iota
+	VersusAttemptNotPlaying
+	VersusAttemptInvalid
+	VersusAttemptNotInDictionary
+)
+
+const (
+	VersusForfeitSuccess VersusForfeit = iota
+	VersusForfeitNotPlaying
+)
+
+func (c VersusCreate) String() string {
+	switch c {
+	case VersusCreateSuccess:
+		return "SUCCESS"
+	case VersusCreateInvalidWordLength:
+		return "INVALID_WORD_LENGTH"
+	case VersusCreateInvalidCount:
+		return "INVALID_COUNT"
+	case VersusCreateInvalidMaxPlayers:
+		return "INVALID_MAX_PLAYERS"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c VersusJoin) String() string {
+	switch c {
+	case VersusJoinSuccess:
+		return "SUCCESS"
+	case VersusJoinNotFound:
+		return "NOT_FOUND"
+	case VersusJoinFull:
+		return "FULL"
+	case VersusJoinAlreadyStarted:
+		return "ALREADY_STARTED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c VersusStart) String() string {
+	switch c {
+	case VersusStartSuccess:
+		return "SUCCESS"
+	case VersusStartNotHost:
+		return "NOT_HOST"
+	case VersusStartNotEnoughPlayers:
+		return "NOT_ENOUGH_PLAYERS"
+	case VersusStartAlreadyStarted:
+		return "ALREADY_STARTED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c VersusAttempt) String() string {
+	switch c {
+	case VersusAttemptSuccess:
+		return "SUCCESS"
+	case VersusAttemptNotPlaying:
+		return "NOT_PLAYING"
+	case VersusAttemptInvalid:
+		return "INVALID"
+	case VersusAttemptNotInDictionary:
+		return "NOT_IN_DICTIONARY"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c VersusForfeit) String() string {
+	switch c {
+	case VersusForfeitSuccess:
+		return "SUCCESS"
+	case VersusForfeitNotPlaying:
+		return "NOT_PLAYING"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -2,46 +2,284 @@ package util
 
 import (
 	"aidanwoods.dev/go-paseto"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"termo_back_end/internal/entities"
 	"time"
 )
 
 const keyUserID = "id"
 
+// AccessTokenDuration is how long an auth token is valid for
+const AccessTokenDuration = 1 * time.Hour
+
+// RefreshTokenDuration is how long a refresh token is valid for
+const RefreshTokenDuration = 30 * 24 * time.Hour
+
+// PasswordResetTokenDuration is how long a password reset token is valid for
+const PasswordResetTokenDuration = 1 * time.Hour
+
+// ChallengeTokenDuration is how long a user has to send their second factor code after sending their password
+const ChallengeTokenDuration = 5 * time.Minute
+
+// StreamTicketDuration is how long a user has to open a websocket or event stream after getting a ticket for it
+const StreamTicketDuration = 30 * time.Second
+
+// Token subjects, so that a token issued for one purpose can't be used for another
+const (
+	tokenSubjectAuth         = "auth"
+	tokenSubjectChallenge    = "2fa"
+	tokenSubjectStreamTicket = "stream"
+)
+
+// ErrUnknownAuthKey is returned when a token was signed by a key that is not in the keyring
+var ErrUnknownAuthKey = errors.New("authKeyring: unknown key")
+
+// ErrRetiredAuthKey is returned when a token was signed by a key after it was retired
+var ErrRetiredAuthKey = errors.New("authKeyring: token signed after key retirement")
+
+// tokenFooter is stored in the footer of every token, identifying which key signed it
+type tokenFooter struct {
+	KeyID string `json:"kid"`
+}
+
+type authPublicKey struct {
+	key       paseto.V4AsymmetricPublicKey
+	retiredAt *time.Time
+}
+
+// AuthKeyring stores every key accepted when verifying tokens, along with the key used to sign new ones
+type AuthKeyring struct {
+	activeID   string
+	signingKey paseto.V4AsymmetricSecretKey
+	publicKeys map[string]authPublicKey
+}
+
+// NewAuthKeyring loads the keyring from the auth config. If no keyring is configured, the single key pair is used with
+// the ID entities.DefaultAuthKeyID
+func NewAuthKeyring(config entities.Config) (*AuthKeyring, error) {
+	keys := config.Auth.Keys
+	activeID := config.Auth.ActiveKeyID
+	if len(keys) == 0 {
+		keys = []entities.AuthKey{{
+			ID:         entities.DefaultAuthKeyID,
+			PublicKey:  config.Auth.PublicKey,
+			PrivateKey: config.Auth.PrivateKey,
+		}}
+		activeID = entities.DefaultAuthKeyID
+	}
+
+	keyring := AuthKeyring{
+		activeID:   activeID,
+		publicKeys: make(map[string]authPublicKey),
+	}
+
+	var foundActive bool
+	for _, k := range keys {
+		if _, ok := keyring.publicKeys[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate key ID %q", k.ID)
+		}
+
+		publicKey, err := paseto.NewV4AsymmetricPublicKeyFromHex(k.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("[NewV4AsymmetricPublicKeyFromHex] %q | %v", k.ID, err)
+		}
+
+		keyring.publicKeys[k.ID] = authPublicKey{
+			key:       publicKey,
+			retiredAt: k.RetiredAt,
+		}
+
+		if k.ID != activeID {
+			continue
+		}
+
+		if k.RetiredAt != nil {
+			return nil, fmt.Errorf("active key %q is retired", k.ID)
+		}
+
+		keyring.signingKey, err = paseto.NewV4AsymmetricSecretKeyFromHex(k.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("[NewV4AsymmetricSecretKeyFromHex] %q | %v", k.ID, err)
+		}
+		foundActive = true
+	}
+
+	if !foundActive {
+		return nil, fmt.Errorf("active key %q not found", activeID)
+	}
+
+	return &keyring, nil
+}
+
+// Sign signs a token with the active key, storing its ID in the footer
+func (k AuthKeyring) Sign(token paseto.Token) (string, error) {
+	footer, err := json.Marshal(tokenFooter{KeyID: k.activeID})
+	if err != nil {
+		return "", fmt.Errorf("[json.Marshal] | %v", err)
+	}
+
+	token.SetFooter(footer)
+	return token.V4Sign(k.signingKey, nil), nil
+}
+
+// Parse verifies a token with the key identified in its footer and checks it against the parser rules
+//
+// Tokens signed by a retired key are only accepted if they were issued before the key was retired
+func (k AuthKeyring) Parse(parser paseto.Parser, tokenString string) (*paseto.Token, error) {
+	keyID := entities.DefaultAuthKeyID
+
+	footer, err := parser.UnsafeParseFooter(paseto.V4Public, tokenString)
+	if err != nil {
+		return nil, fmt.Errorf("[parser.UnsafeParseFooter] | %v", err)
+	}
+
+	if len(footer) > 0 {
+		var parsedFooter tokenFooter
+		err = json.Unmarshal(footer, &parsedFooter)
+		if err != nil {
+			return nil, fmt.Errorf("[json.Unmarshal] | %v", err)
+		}
+		keyID = parsedFooter.KeyID
+	}
+
+	publicKey, ok := k.publicKeys[keyID]
+	if !ok {
+		return nil, ErrUnknownAuthKey
+	}
+
+	// The footer is authenticated, so it can only be trusted after the signature is verified
+	token, err := parser.ParseV4Public(publicKey.key, tokenString, nil)
+	if err != nil {
+		return nil, fmt.Errorf("[parser.ParseV4Public] | %v", err)
+	}
+
+	if publicKey.retiredAt != nil {
+		issuedAt, err := token.GetIssuedAt()
+		if err != nil {
+			return nil, fmt.Errorf("[token.GetIssuedAt] | %v", err)
+		}
+
+		if !issuedAt.Before(*publicKey.retiredAt) {
+			return nil, ErrRetiredAuthKey
+		}
+	}
+
+	return token, nil
+}
+
 // GenerateAuthToken generates a new auth token storing the provided user ID
-func GenerateAuthToken(userID int64, privateKey paseto.V4AsymmetricSecretKey) (string, error) {
+//
+// Each token gets a random identifier (jti), used to revoke it before it expires
+func GenerateAuthToken(userID int64, keyring *AuthKeyring) (string, error) {
+	return generateUserToken(tokenSubjectAuth, userID, AccessTokenDuration, keyring)
+}
+
+// ParseAuthToken attempts to verify an auth token string and extract its claims
+func ParseAuthToken(tokenString string, keyring *AuthKeyring) (*entities.AuthToken, error) {
+	return parseUserToken(tokenSubjectAuth, tokenString, keyring)
+}
+
+// GenerateChallengeToken generates a token proving that the provided user sent the right password, to be exchanged for
+// an auth token along with their second factor code
+func GenerateChallengeToken(userID int64, keyring *AuthKeyring) (string, error) {
+	return generateUserToken(tokenSubjectChallenge, userID, ChallengeTokenDuration, keyring)
+}
+
+// ParseChallengeToken attempts to verify a challenge token string and extract its claims
+func ParseChallengeToken(tokenString string, keyring *AuthKeyring) (*entities.AuthToken, error) {
+	return parseUserToken(tokenSubjectChallenge, tokenString, keyring)
+}
+
+// GenerateStreamTicket generates a ticket authenticating the provided user when opening a websocket or event stream.
+// Browsers can't set headers on those, so the ticket is sent in the URL instead of the auth token, which would end up
+// in access logs
+func GenerateStreamTicket(userID int64, keyring *AuthKeyring) (string, error) {
+	return generateUserToken(tokenSubjectStreamTicket, userID, StreamTicketDuration, keyring)
+}
+
+// ParseStreamTicket attempts to verify a stream ticket string and extract its claims
+func ParseStreamTicket(tokenString string, keyring *AuthKeyring) (*entities.AuthToken, error) {
+	return parseUserToken(tokenSubjectStreamTicket, tokenString, keyring)
+}
+
+// generateUserToken generates a new token with the provided subject, storing the provided user ID
+func generateUserToken(
+	subject string,
+	userID int64,
+	duration time.Duration,
+	keyring *AuthKeyring,
+) (string, error) {
 	token := paseto.NewToken()
 	now := time.Now()
 
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", fmt.Errorf("[GenerateRandomToken] | %v", err)
+	}
+
 	token.SetIssuer("termo")
-	token.SetSubject("auth")
+	token.SetSubject(subject)
+	token.SetJti(jti)
 	token.SetIssuedAt(now)
 	token.SetNotBefore(now)
-	token.SetExpiration(now.Add(1 * time.Hour))
+	token.SetExpiration(now.Add(duration))
 
-	err := token.Set(keyUserID, userID)
+	err = token.Set(keyUserID, userID)
 	if err != nil {
 		return "", fmt.Errorf("[token.Set] | %v", err)
 	}
 
-	signed := token.V4Sign(privateKey, nil)
+	signed, err := keyring.Sign(token)
+	if err != nil {
+		return "", fmt.Errorf("[keyring.Sign] | %v", err)
+	}
+
 	return signed, nil
 }
 
-// GetIDFromToken attempts to extract the stored user ID from an auth token string
-func GetIDFromToken(tokenString string, publicKey paseto.V4AsymmetricPublicKey) (int64, error) {
+// parseUserToken attempts to verify a token string with the provided subject and extract its claims
+func parseUserToken(subject string, tokenString string, keyring *AuthKeyring) (*entities.AuthToken, error) {
 	parser := paseto.NewParser()
+	parser.AddRule(paseto.Subject(subject))
+
+	parsedToken, err := keyring.Parse(parser, tokenString)
+	if err != nil {
+		return nil, fmt.Errorf("[keyring.Parse] | %v", err)
+	}
 
-	parsedToken, err := parser.ParseV4Public(publicKey, tokenString, nil)
+	var token entities.AuthToken
+	err = parsedToken.Get(keyUserID, &token.UserID)
 	if err != nil {
-		return 0, fmt.Errorf("[parser.ParseV4Public] | %v", err)
+		return nil, fmt.Errorf("[parsedToken.Get] | %v", err)
 	}
 
-	var userID int64
-	err = parsedToken.Get(keyUserID, &userID)
+	token.ID, err = parsedToken.GetJti()
 	if err != nil {
-		return 0, fmt.Errorf("[parsedToken.Get] | %v", err)
+		return nil, fmt.Errorf("[parsedToken.GetJti] | %v", err)
 	}
 
-	return userID, nil
+	token.IssuedAt, err = parsedToken.GetIssuedAt()
+	if err != nil {
+		return nil, fmt.Errorf("[parsedToken.GetIssuedAt] | %v", err)
+	}
+
+	token.ExpiresAt, err = parsedToken.GetExpiration()
+	if err != nil {
+		return nil, fmt.Errorf("[parsedToken.GetExpiration] | %v", err)
+	}
+
+	return &token, nil
+}
+
+// GenerateAuthKey generates a new key pair for the auth keyring with the provided ID
+func GenerateAuthKey(id string) entities.AuthKey {
+	privateKey := paseto.NewV4AsymmetricSecretKey()
+
+	return entities.AuthKey{
+		ID:         id,
+		PublicKey:  privateKey.Public().ExportHex(),
+		PrivateKey: privateKey.ExportHex(),
+	}
 }
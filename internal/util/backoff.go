@@ -0,0 +1,98 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+type backoffEntry struct {
+	failures    uint32
+	lastFailure time.Time
+}
+
+// Backoff tracks failures by key in memory and makes keys wait exponentially longer between attempts as they fail
+//
+// The first freeFailures failures don't require any wait. After that, the wait starts at base and doubles with each
+// further failure, up to max. A key is forgotten once it doesn't fail for longer than max
+type Backoff struct {
+	mu           sync.Mutex
+	entries      map[string]*backoffEntry
+	freeFailures uint32
+	base         time.Duration
+	max          time.Duration
+	lastSweep    time.Time
+}
+
+func NewBackoff(freeFailures uint32, base time.Duration, max time.Duration) *Backoff {
+	return &Backoff{
+		entries:      make(map[string]*backoffEntry),
+		freeFailures: freeFailures,
+		base:         base,
+		max:          max,
+		lastSweep:    time.Now(),
+	}
+}
+
+// Wait returns how long the key must wait before its next attempt; 0 if it can try now
+func (b *Backoff) Wait(key string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.entries[key]
+	if !ok || entry.failures <= b.freeFailures {
+		return 0
+	}
+
+	// Double the wait for each failure past the free ones, avoiding overflows
+	wait := b.base
+	for i := b.freeFailures + 1; i < entry.failures && wait < b.max; i++ {
+		wait *= 2
+	}
+	wait = min(wait, b.max)
+
+	remaining := time.Until(entry.lastFailure.Add(wait))
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// AddFailure registers a failed attempt of the key
+func (b *Backoff) AddFailure(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.sweep(now)
+
+	entry, ok := b.entries[key]
+	if !ok {
+		entry = &backoffEntry{}
+		b.entries[key] = entry
+	}
+
+	entry.failures++
+	entry.lastFailure = now
+}
+
+// Reset forgets every failure of the key
+func (b *Backoff) Reset(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.entries, key)
+}
+
+// sweep forgets the keys that haven't failed for longer than max. Expects the lock to be held
+func (b *Backoff) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < b.max {
+		return
+	}
+	b.lastSweep = now
+
+	for key, entry := range b.entries {
+		if now.Sub(entry.lastFailure) > b.max {
+			delete(b.entries, key)
+		}
+	}
+}
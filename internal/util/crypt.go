@@ -1,21 +1,39 @@
 package util
 
 import (
-	"golang.org/x/crypto/bcrypt"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
 )
 
-// HashPassword hashes the provided password using the bcrypt library. It can't handle passwords longer than 72
-// characters, so make sure it is below that limit
-func HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// GenerateRandomToken generates a URL-safe random string from the provided number of random bytes
+func GenerateRandomToken(size int) (string, error) {
+	bytes := make([]byte, size)
+	_, err := rand.Read(bytes)
 	if err != nil {
 		return "", err
 	}
-	return string(hash), nil
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
 }
 
-// CheckPasswordHash checks if a plain password matches a hashed password
-func CheckPasswordHash(password, hashed string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password))
-	return err == nil
+// GenerateRandomCode generates a random string of the provided length, made of characters from the alphabet
+func GenerateRandomCode(length int, alphabet string) (string, error) {
+	code := make([]byte, length)
+	for i := range code {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = alphabet[idx.Int64()]
+	}
+	return string(code), nil
+}
+
+// HashToken hashes a random token to be stored in the database. Unlike passwords, random tokens are long enough that a
+// fast hash is safe to use
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
 }
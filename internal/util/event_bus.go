@@ -0,0 +1,219 @@
+package util
+
+import (
+	"sync"
+	"termo_back_end/internal/entities"
+	"time"
+)
+
+const (
+	// eventBusHistorySize is how many of the latest events of each user are kept to be replayed
+	eventBusHistorySize = 50
+
+	// eventBusHistoryTTL is how long the events of users without subscribers are kept after the last one
+	eventBusHistoryTTL = 10 * time.Minute
+
+	// eventBusSweepInterval is how often expired histories are forgotten
+	eventBusSweepInterval = time.Minute
+
+	// eventBusSubscriptionBuffer is how many events can wait to be read by a subscriber; slower ones are dropped
+	eventBusSubscriptionBuffer = 32
+)
+
+// EventBus delivers the events of each user to their subscribers. The latest events of each user are kept for a while,
+// so that subscribers who reconnect get the events they missed
+//
+// Events are kept in memory, so subscribers only get the events published by the same server instance
+type EventBus struct {
+	mu sync.Mutex
+
+	// firstID is the ID before the first event of this instance; lastID is the ID of the latest event
+	firstID uint64
+	lastID  uint64
+
+	// sweptID is the ID of the latest event forgotten along with its user's history
+	sweptID uint64
+
+	users     map[int64]*eventBusUser
+	lastSweep time.Time
+}
+
+type eventBusUser struct {
+	history       []entities.UserEvent
+	subscriptions map[*EventSubscription]bool
+	updatedAt     time.Time
+
+	// subscribedAt is the last time the user had a subscription
+	subscribedAt time.Time
+
+	// droppedID is the ID of the latest event dropped from the history
+	droppedID uint64
+}
+
+// EventSubscription receives the events of a user until it's closed, either by Close or for not reading events fast
+// enough
+type EventSubscription struct {
+	bus    *EventBus
+	userID int64
+	events chan entities.UserEvent
+
+	// closed is guarded by the bus' mu
+	closed bool
+}
+
+func NewEventBus() *EventBus {
+	// IDs start from the current time, so that the IDs of events from before a restart aren't mistaken for new ones
+	firstID := uint64(time.Now().UnixNano())
+
+	return &EventBus{
+		firstID:   firstID,
+		lastID:    firstID,
+		sweptID:   firstID,
+		users:     make(map[int64]*eventBusUser),
+		lastSweep: time.Now(),
+	}
+}
+
+// Publish sends an event to every subscriber of a user and keeps it to be replayed
+func (b *EventBus) Publish(userID int64, eventType entities.UserEventType, data any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.sweep(now)
+
+	b.lastID++
+	event := entities.UserEvent{
+		ID:   b.lastID,
+		Type: eventType,
+		Data: data,
+	}
+
+	user := b.getUser(userID)
+	user.updatedAt = now
+	user.history = append(user.history, event)
+	if len(user.history) > eventBusHistorySize {
+		user.droppedID = user.history[0].ID
+		user.history = append(user.history[:0], user.history[1:]...)
+	}
+
+	for subscription := range user.subscriptions {
+		select {
+		case subscription.events <- event:
+		default:
+			subscription.closeLocked()
+		}
+	}
+}
+
+// Subscribe subscribes to the events of a user, returning the events to be sent before the ones from the subscription
+//
+// Subscribers who reconnect send the ID of the last event they got, and get every event published since then. If some
+// of them were already forgotten, or the ID is unknown, a single UserEventSync event is returned instead
+func (b *EventBus) Subscribe(userID int64, lastEventID uint64, resume bool) (*EventSubscription, []entities.UserEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	user := b.getUser(userID)
+	subscription := &EventSubscription{
+		bus:    b,
+		userID: userID,
+		events: make(chan entities.UserEvent, eventBusSubscriptionBuffer),
+	}
+	user.subscriptions[subscription] = true
+	user.subscribedAt = time.Now()
+
+	if !resume {
+		return subscription, nil
+	}
+
+	// Events after the ID might have been forgotten if it's older than the latest dropped event
+	canReplay := lastEventID >= b.firstID && lastEventID <= b.lastID && lastEventID >= user.droppedID
+	if !canReplay {
+		return subscription, []entities.UserEvent{{ID: b.lastID, Type: entities.UserEventSync}}
+	}
+
+	var missed []entities.UserEvent
+	for _, event := range user.history {
+		if event.ID > lastEventID {
+			missed = append(missed, event)
+		}
+	}
+
+	return subscription, missed
+}
+
+// Events returns the channel the subscription's events are sent to; it's closed along with the subscription
+func (s *EventSubscription) Events() <-chan entities.UserEvent {
+	return s.events
+}
+
+// Close stops the subscription; closing it more than once does nothing
+func (s *EventSubscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	if !s.closed {
+		s.closeLocked()
+	}
+}
+
+// closeLocked closes the subscription's channel and removes it from its user. Expects the bus' lock to be held
+func (s *EventSubscription) closeLocked() {
+	s.closed = true
+	close(s.events)
+
+	if user, ok := s.bus.users[s.userID]; ok {
+		delete(user.subscriptions, s)
+		user.subscribedAt = time.Now()
+	}
+}
+
+// IsFollowed tells whether the user has subscribers, or had some recently enough for them to reconnect and get the
+// events they missed
+func (b *EventBus) IsFollowed(userID int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	user, ok := b.users[userID]
+	if !ok {
+		return false
+	}
+
+	return len(user.subscriptions) > 0 || time.Since(user.subscribedAt) < eventBusHistoryTTL
+}
+
+// getUser returns the events of a user, creating them if needed. Expects the lock to be held
+func (b *EventBus) getUser(userID int64) *eventBusUser {
+	user, ok := b.users[userID]
+	if !ok {
+		// Users whose history was forgotten may have had any event up to the latest forgotten one
+		user = &eventBusUser{
+			subscriptions: make(map[*EventSubscription]bool),
+			updatedAt:     time.Now(),
+			droppedID:     b.sweptID,
+		}
+		b.users[userID] = user
+	}
+
+	return user
+}
+
+// sweep forgets the histories of users without subscribers that had no events for a while. Expects the lock to be held
+func (b *EventBus) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < eventBusSweepInterval {
+		return
+	}
+	b.lastSweep = now
+
+	for userID, user := range b.users {
+		if len(user.subscriptions) > 0 || now.Sub(user.updatedAt) < eventBusHistoryTTL {
+			continue
+		}
+
+		if len(user.history) > 0 {
+			b.sweptID = max(b.sweptID, user.history[len(user.history)-1].ID)
+		}
+		delete(b.users, userID)
+	}
+}
@@ -0,0 +1,23 @@
+package util
+
+import (
+	"context"
+	"time"
+)
+
+// RunPeriodically runs a job right away and then once every interval, until the context is done. Blocks until then, so
+// it's supposed to be run in its own goroutine
+func RunPeriodically(ctx context.Context, interval time.Duration, job func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		job(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
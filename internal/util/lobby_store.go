@@ -0,0 +1,158 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"termo_back_end/internal/entities"
+	"time"
+)
+
+// ErrLobbyExists is returned by lobby stores when creating a lobby with a code that is already taken
+var ErrLobbyExists = errors.New("lobbyStore: lobby already exists")
+
+// ErrLobbyConflict is returned by lobby stores when a lobby was changed or deleted since it was read
+var ErrLobbyConflict = errors.New("lobbyStore: lobby changed since it was read")
+
+// LobbyStore keeps the lobbies of the lobby service. Changes only succeed if the lobby is still at the version it was
+// read at, so that concurrent changes aren't lost
+//
+// The in-memory store only shares lobbies between requests handled by the same instance; deployments with multiple
+// instances should use a shared store, like the database
+type LobbyStore interface {
+	// GetLobby returns the lobby with the provided code; returns nil if not found
+	GetLobby(ctx context.Context, code string) (*entities.Lobby, error)
+
+	// CreateLobby stores a new lobby at version 1; returns ErrLobbyExists if its code is taken
+	CreateLobby(ctx context.Context, lobby entities.Lobby) error
+
+	// UpdateLobby replaces a lobby with the provided one, incrementing its version; returns ErrLobbyConflict if the
+	// stored lobby is not at the provided one's version
+	UpdateLobby(ctx context.Context, lobby entities.Lobby) error
+
+	// DeleteLobby deletes a lobby; returns ErrLobbyConflict if the stored lobby is not at the provided version
+	DeleteLobby(ctx context.Context, code string, version int64) error
+
+	// GetChangedLobbies returns the lobbies that were changed since the provided time
+	GetChangedLobbies(ctx context.Context, since time.Time) ([]entities.Lobby, error)
+
+	// GetExpiredRounds returns the codes of the lobbies whose round is being played but ended before the provided time
+	GetExpiredRounds(ctx context.Context, before time.Time) ([]string, error)
+
+	// DeleteIdleLobbies deletes every lobby that wasn't changed since the provided time. Returns how many were deleted
+	DeleteIdleLobbies(ctx context.Context, before time.Time) (int64, error)
+}
+
+// memoryLobbyStore keeps the lobbies in memory. Lobbies are copied in and out, so that callers can't change them
+// without storing them
+type memoryLobbyStore struct {
+	mu      sync.Mutex
+	lobbies map[string]entities.Lobby
+}
+
+func NewMemoryLobbyStore() LobbyStore {
+	return &memoryLobbyStore{
+		lobbies: make(map[string]entities.Lobby),
+	}
+}
+
+func (s *memoryLobbyStore) GetLobby(_ context.Context, code string) (*entities.Lobby, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lobby, ok := s.lobbies[code]
+	if !ok {
+		return nil, nil
+	}
+
+	lobby = lobby.Clone()
+	return &lobby, nil
+}
+
+func (s *memoryLobbyStore) CreateLobby(_ context.Context, lobby entities.Lobby) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.lobbies[lobby.Code]; ok {
+		return ErrLobbyExists
+	}
+
+	lobby = lobby.Clone()
+	lobby.Version = 1
+	s.lobbies[lobby.Code] = lobby
+
+	return nil
+}
+
+func (s *memoryLobbyStore) UpdateLobby(_ context.Context, lobby entities.Lobby) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.lobbies[lobby.Code]
+	if !ok || stored.Version != lobby.Version {
+		return ErrLobbyConflict
+	}
+
+	lobby = lobby.Clone()
+	lobby.Version++
+	s.lobbies[lobby.Code] = lobby
+
+	return nil
+}
+
+func (s *memoryLobbyStore) DeleteLobby(_ context.Context, code string, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.lobbies[code]
+	if !ok || stored.Version != version {
+		return ErrLobbyConflict
+	}
+
+	delete(s.lobbies, code)
+
+	return nil
+}
+
+func (s *memoryLobbyStore) GetChangedLobbies(_ context.Context, since time.Time) ([]entities.Lobby, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var lobbies []entities.Lobby
+	for _, lobby := range s.lobbies {
+		if !lobby.UpdatedAt.Before(since) {
+			lobbies = append(lobbies, lobby.Clone())
+		}
+	}
+
+	return lobbies, nil
+}
+
+func (s *memoryLobbyStore) GetExpiredRounds(_ context.Context, before time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var codes []string
+	for code, lobby := range s.lobbies {
+		if lobby.State == entities.LobbyStatePlaying && lobby.RoundEndsAt.Before(before) {
+			codes = append(codes, code)
+		}
+	}
+
+	return codes, nil
+}
+
+func (s *memoryLobbyStore) DeleteIdleLobbies(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for code, lobby := range s.lobbies {
+		if lobby.UpdatedAt.Before(before) {
+			delete(s.lobbies, code)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
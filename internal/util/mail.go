@@ -0,0 +1,114 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mail is a plain text email
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	// Send delivers an email to its recipient
+	Send(ctx context.Context, mail Mail) error
+}
+
+// buildMailMessage builds the raw message of an email, with headers
+func buildMailMessage(from string, mail Mail) []byte {
+	// Line breaks in headers would allow injecting other headers
+	header := strings.NewReplacer("\r", "", "\n", "")
+
+	var b strings.Builder
+	b.WriteString("From: " + header.Replace(from) + "\r\n")
+	b.WriteString("To: " + header.Replace(mail.To) + "\r\n")
+	b.WriteString("Subject: " + header.Replace(mail.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+
+	return []byte(b.String())
+}
+
+type smtpMailer struct {
+	addr     string
+	from     string
+	envelope string
+	auth     smtp.Auth
+}
+
+// NewSMTPMailer creates a mailer that delivers emails through an SMTP server. STARTTLS is used if the server supports
+// it; authentication is skipped if user is empty
+func NewSMTPMailer(host string, port int, user string, password string, from string) Mailer {
+	var auth smtp.Auth
+	if user != "" {
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+
+	// The sender may have a display name, which can't be used in the SMTP envelope
+	envelope := from
+	address, err := mail.ParseAddress(from)
+	if err == nil {
+		envelope = address.Address
+	}
+
+	return smtpMailer{
+		addr:     host + ":" + strconv.Itoa(port),
+		from:     from,
+		envelope: envelope,
+		auth:     auth,
+	}
+}
+
+func (m smtpMailer) Send(_ context.Context, mail Mail) error {
+	err := smtp.SendMail(m.addr, m.auth, m.envelope, []string{mail.To}, buildMailMessage(m.from, mail))
+	if err != nil {
+		return fmt.Errorf("[smtp.SendMail] | %v", err)
+	}
+
+	return nil
+}
+
+type logMailer struct {
+	from string
+	dir  string
+}
+
+// NewLogMailer creates a mailer for local development that doesn't deliver emails. They are written to files in the
+// provided directory, or only logged if it is empty
+func NewLogMailer(from string, dir string) Mailer {
+	return logMailer{
+		from: from,
+		dir:  dir,
+	}
+}
+
+func (m logMailer) Send(_ context.Context, mail Mail) error {
+	message := buildMailMessage(m.from, mail)
+	if m.dir == "" {
+		log.Printf("mail to %s:\n%s", mail.To, message)
+		return nil
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), filepath.Base(mail.To))
+	err := os.WriteFile(filepath.Join(m.dir, name), message, 0o644)
+	if err != nil {
+		return fmt.Errorf("[os.WriteFile] | %v", err)
+	}
+
+	return nil
+}
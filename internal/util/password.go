@@ -0,0 +1,253 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"termo_back_end/internal/entities"
+)
+
+// Default argon2id parameters, as recommended by OWASP
+const (
+	defaultArgon2Memory      = 19 * 1024
+	defaultArgon2Iterations  = 2
+	defaultArgon2Parallelism = 1
+	argon2SaltLength         = 16
+	argon2KeyLength          = 32
+)
+
+// bcryptMaxPasswordLength is the most bytes of a password bcrypt can handle
+const bcryptMaxPasswordLength = 72
+
+// PasswordHasher hashes passwords to be stored and checks plain passwords against stored hashes
+type PasswordHasher interface {
+	// Hash hashes the provided password with the configured algorithm and parameters
+	Hash(password string) (string, error)
+
+	// Verify checks if a plain password matches a hashed password made by any supported algorithm. If it matches but
+	// the hash was made by another algorithm or with other parameters than the configured ones, needsRehash is true,
+	// so that the stored hash can be replaced by a new one
+	Verify(password, hashed string) (ok bool, needsRehash bool)
+}
+
+// passwordAlgorithm is a single hashing algorithm used by the PasswordHasher
+type passwordAlgorithm interface {
+	// Matches checks whether a hash was made by this algorithm
+	Matches(hashed string) bool
+
+	Hash(password string) (string, error)
+
+	// Verify checks if a plain password matches a hash made by this algorithm. outdated is true if the hash was made
+	// with other parameters than the algorithm's ones
+	Verify(password, hashed string) (ok bool, outdated bool)
+}
+
+type passwordHasher struct {
+	current    passwordAlgorithm
+	algorithms []passwordAlgorithm
+}
+
+// NewPasswordHasher builds the PasswordHasher from the auth config. New passwords are hashed with the configured
+// algorithm, while hashes made by any of the supported algorithms are still verified
+func NewPasswordHasher(config entities.Config) (PasswordHasher, error) {
+	hashing := config.Auth.PasswordHashing
+
+	argon2idAlg := argon2idAlgorithm{
+		memory:      hashing.Argon2.MemoryKiB,
+		iterations:  hashing.Argon2.Iterations,
+		parallelism: hashing.Argon2.Parallelism,
+	}
+	if argon2idAlg.memory == 0 {
+		argon2idAlg.memory = defaultArgon2Memory
+	}
+	if argon2idAlg.iterations == 0 {
+		argon2idAlg.iterations = defaultArgon2Iterations
+	}
+	if argon2idAlg.parallelism == 0 {
+		argon2idAlg.parallelism = defaultArgon2Parallelism
+	}
+
+	bcryptAlg := bcryptAlgorithm{cost: hashing.BcryptCost}
+	if bcryptAlg.cost == 0 {
+		bcryptAlg.cost = bcrypt.DefaultCost
+	}
+	if bcryptAlg.cost < bcrypt.MinCost || bcryptAlg.cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	hasher := passwordHasher{
+		algorithms: []passwordAlgorithm{argon2idAlg, bcryptAlg},
+	}
+
+	switch hashing.Algorithm {
+	case entities.PasswordHashArgon2id, "":
+		hasher.current = argon2idAlg
+	case entities.PasswordHashBcrypt:
+		hasher.current = bcryptAlg
+	default:
+		return nil, fmt.Errorf("unknown password hashing algorithm: %s", hashing.Algorithm)
+	}
+
+	return hasher, nil
+}
+
+func (h passwordHasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+func (h passwordHasher) Verify(password, hashed string) (bool, bool) {
+	for _, algorithm := range h.algorithms {
+		if !algorithm.Matches(hashed) {
+			continue
+		}
+
+		ok, outdated := algorithm.Verify(password, hashed)
+		if !ok {
+			return false, false
+		}
+
+		return true, outdated || algorithm != h.current
+	}
+
+	return false, false
+}
+
+// argon2idAlgorithm hashes passwords with argon2id, encoding hashes in the PHC string format:
+//
+//	$argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type argon2idAlgorithm struct {
+	// memory is in KiB
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+const argon2idPrefix = "$argon2id$"
+
+func (a argon2idAlgorithm) Matches(hashed string) bool {
+	return strings.HasPrefix(hashed, argon2idPrefix)
+}
+
+func (a argon2idAlgorithm) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.iterations, a.memory, a.parallelism, argon2KeyLength)
+
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		a.memory,
+		a.iterations,
+		a.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a argon2idAlgorithm) Verify(password, hashed string) (bool, bool) {
+	params, salt, key, err := decodeArgon2idHash(hashed)
+	if err != nil {
+		return false, false
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism,
+		uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, otherKey) != 1 {
+		return false, false
+	}
+
+	outdated := params != a || len(salt) != argon2SaltLength || len(key) != argon2KeyLength
+	return true, outdated
+}
+
+// decodeArgon2idHash reads the parameters, salt and key of an argon2id hash in the PHC string format
+func decodeArgon2idHash(hashed string) (argon2idAlgorithm, []byte, []byte, error) {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 {
+		return argon2idAlgorithm{}, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return argon2idAlgorithm{}, nil, nil, err
+	}
+	if version != argon2.Version {
+		return argon2idAlgorithm{}, nil, nil, fmt.Errorf("unsupported argon2 version: %d", version)
+	}
+
+	var params argon2idAlgorithm
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism)
+	if err != nil {
+		return argon2idAlgorithm{}, nil, nil, err
+	}
+	if params.iterations == 0 || params.parallelism == 0 {
+		return argon2idAlgorithm{}, nil, nil, errors.New("invalid argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2idAlgorithm{}, nil, nil, err
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return argon2idAlgorithm{}, nil, nil, err
+	}
+	if len(key) == 0 {
+		return argon2idAlgorithm{}, nil, nil, errors.New("invalid argon2id key")
+	}
+
+	return params, salt, key, nil
+}
+
+// bcryptAlgorithm hashes passwords with bcrypt. It's kept for the hashes made before argon2id was the default
+type bcryptAlgorithm struct {
+	cost int
+}
+
+func (a bcryptAlgorithm) Matches(hashed string) bool {
+	return strings.HasPrefix(hashed, "$2a$") || strings.HasPrefix(hashed, "$2b$") || strings.HasPrefix(hashed, "$2y$")
+}
+
+func (a bcryptAlgorithm) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword(bcryptInput(password), a.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (a bcryptAlgorithm) Verify(password, hashed string) (bool, bool) {
+	err := bcrypt.CompareHashAndPassword([]byte(hashed), bcryptInput(password))
+	if err != nil {
+		return false, false
+	}
+
+	cost, err := bcrypt.Cost([]byte(hashed))
+	return true, err != nil || cost != a.cost
+}
+
+// bcryptInput returns the bytes bcrypt hashes for a password. Since bcrypt can't handle passwords longer than 72
+// bytes, longer passwords are hashed with SHA-256 first. Passwords that long were never allowed before argon2id,
+// so existing hashes are not affected
+func bcryptInput(password string) []byte {
+	if len(password) <= bcryptMaxPasswordLength {
+		return []byte(password)
+	}
+
+	hash := sha256.Sum256([]byte(password))
+	return []byte(hex.EncodeToString(hash[:]))
+}
@@ -0,0 +1,194 @@
+package util
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"termo_back_end/internal/entities"
+	"time"
+)
+
+// RateLimitStore keeps the token buckets of the rate limiter
+//
+// The in-memory store only limits requests handled by the same instance; deployments with multiple instances should use
+// a shared store, like the database
+type RateLimitStore interface {
+	// Take attempts to take a request from the bucket with the provided key, creating a full one if it doesn't exist
+	//
+	// Returns whether the request is allowed and, if not, how long until it would be
+	Take(ctx context.Context, key string, limit entities.RateLimit) (bool, time.Duration, error)
+}
+
+// TakeRateLimitToken refills a token bucket that had the provided amount of tokens elapsed time ago and attempts to
+// take a token from it
+//
+// Returns the tokens left, whether a token was taken and, if not, how long until one is available
+func TakeRateLimitToken(
+	limit entities.RateLimit,
+	tokens float64,
+	elapsed time.Duration,
+) (float64, bool, time.Duration) {
+	tokens = math.Min(limit.Capacity(), tokens+elapsed.Seconds()*limit.Rate())
+	if tokens >= 1 {
+		return tokens - 1, true, 0
+	}
+
+	wait := time.Duration((1 - tokens) / limit.Rate() * float64(time.Second))
+	return tokens, false, wait
+}
+
+// RateLimitFullAfter returns how long a bucket with the provided amount of tokens takes to be full again, after which
+// it can be forgotten
+func RateLimitFullAfter(limit entities.RateLimit, tokens float64) time.Duration {
+	return time.Duration((limit.Capacity() - tokens) / limit.Rate() * float64(time.Second))
+}
+
+type memoryRateLimitBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	fullAt    time.Time
+}
+
+// memoryRateLimitStore keeps the token buckets in memory
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryRateLimitBucket
+	lastSweep time.Time
+}
+
+// memoryRateLimitSweepInterval is how often full buckets are forgotten
+const memoryRateLimitSweepInterval = time.Minute
+
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{
+		buckets:   make(map[string]*memoryRateLimitBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *memoryRateLimitStore) Take(
+	_ context.Context,
+	key string,
+	limit entities.RateLimit,
+) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryRateLimitBucket{
+			tokens:    limit.Capacity(),
+			updatedAt: now,
+		}
+		s.buckets[key] = bucket
+	}
+
+	tokens, allowed, wait := TakeRateLimitToken(limit, bucket.tokens, now.Sub(bucket.updatedAt))
+	bucket.tokens = tokens
+	bucket.updatedAt = now
+	bucket.fullAt = now.Add(RateLimitFullAfter(limit, tokens))
+
+	return allowed, wait, nil
+}
+
+// sweep forgets the buckets that are already full. Expects the lock to be held
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memoryRateLimitSweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, bucket := range s.buckets {
+		if now.After(bucket.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// RateLimiter builds middlewares that limit how often each client can make requests
+type RateLimiter struct {
+	store     RateLimitStore
+	proxyHops uint32
+
+	// failClosed makes every limit refuse requests while the store fails
+	failClosed bool
+}
+
+// NewRateLimiter creates a rate limiter with buckets kept in the provided store. See GetClientIP for proxyHops and
+// Middleware for failClosed
+func NewRateLimiter(store RateLimitStore, proxyHops uint32, failClosed bool) *RateLimiter {
+	return &RateLimiter{
+		store:      store,
+		proxyHops:  proxyHops,
+		failClosed: failClosed,
+	}
+}
+
+// Middleware returns a middleware that limits requests with the provided limit. Clients over the limit get a 429
+// response with a Retry-After header
+//
+// The name identifies the limit, so that different limits don't share buckets. If the store fails, requests get a 503
+// response if the limiter or the limit fails closed, and are allowed otherwise
+func (l *RateLimiter) Middleware(name string, limit entities.RateLimit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := name + ":" + l.clientKey(r, limit.Key)
+
+			allowed, wait, err := l.store.Take(r.Context(), key, limit)
+			if err != nil {
+				log.Printf("[Take] | %v", err)
+				if l.failClosed || limit.FailClosed {
+					http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !allowed {
+				SetRetryAfter(w, wait)
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Wrap wraps a handler with the provided rate limit; returns the handler as is if the limit is nil. See Middleware
+func (l *RateLimiter) Wrap(name string, limit *entities.RateLimit, handler http.Handler) http.Handler {
+	if limit == nil {
+		return handler
+	}
+	return l.Middleware(name, *limit)(handler)
+}
+
+// clientKey returns the identifier requests are counted by
+func (l *RateLimiter) clientKey(r *http.Request, key entities.RateLimitKey) string {
+	if key == entities.RateLimitKeyUser {
+		// Not every route has a session, so the user is read without logging when missing
+		if user, ok := r.Context().Value("user").(*entities.User); ok {
+			return "user:" + strconv.FormatInt(user.ID, 10)
+		}
+	}
+	return "ip:" + GetClientIP(r, l.proxyHops)
+}
+
+// SetRetryAfter sets the Retry-After header if the client must wait before retrying. Returns the wait in seconds,
+// rounded up
+func SetRetryAfter(w http.ResponseWriter, wait time.Duration) uint32 {
+	if wait <= 0 {
+		return 0
+	}
+
+	seconds := uint32((wait + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatUint(uint64(seconds), 10))
+	return seconds
+}
@@ -0,0 +1,95 @@
+package util
+
+import (
+	"math"
+	"termo_back_end/internal/entities"
+	"testing"
+	"time"
+)
+
+func TestTakeRateLimitToken(t *testing.T) {
+	// 10 requests a minute regain a token every 6 seconds
+	limit := entities.RateLimit{Requests: 10, Per: time.Minute}
+	burstLimit := entities.RateLimit{Requests: 60, Per: time.Minute, Burst: 2}
+
+	tests := []struct {
+		name       string
+		limit      entities.RateLimit
+		tokens     float64
+		elapsed    time.Duration
+		wantTokens float64
+		wantTaken  bool
+		wantWait   time.Duration
+	}{
+		{
+			name:       "full bucket",
+			limit:      limit,
+			tokens:     10,
+			wantTokens: 9,
+			wantTaken:  true,
+		},
+		{
+			name:       "empty bucket",
+			limit:      limit,
+			tokens:     0,
+			wantTokens: 0,
+			wantWait:   6 * time.Second,
+		},
+		{
+			name:       "partly refilled",
+			limit:      limit,
+			tokens:     0,
+			elapsed:    3 * time.Second,
+			wantTokens: 0.5,
+			wantWait:   3 * time.Second,
+		},
+		{
+			name:       "refilled one token",
+			limit:      limit,
+			tokens:     0,
+			elapsed:    6 * time.Second,
+			wantTokens: 0,
+			wantTaken:  true,
+		},
+		{
+			name:       "refill capped at the capacity",
+			limit:      limit,
+			tokens:     9,
+			elapsed:    time.Hour,
+			wantTokens: 9,
+			wantTaken:  true,
+		},
+		{
+			name:       "refill capped at the burst",
+			limit:      burstLimit,
+			tokens:     0,
+			elapsed:    10 * time.Second,
+			wantTokens: 1,
+			wantTaken:  true,
+		},
+		{
+			name:       "burst used up",
+			limit:      burstLimit,
+			tokens:     0.25,
+			wantTokens: 0.25,
+			wantWait:   750 * time.Millisecond,
+		},
+	}
+
+	for _, test := range tests {
+		tokens, taken, wait := TakeRateLimitToken(test.limit, test.tokens, test.elapsed)
+		if math.Abs(tokens-test.wantTokens) > 1e-9 || taken != test.wantTaken {
+			t.Errorf(
+				"%s: got %f tokens and taken %t, want %f tokens and taken %t",
+				test.name,
+				tokens,
+				taken,
+				test.wantTokens,
+				test.wantTaken,
+			)
+		}
+		if (wait - test.wantWait).Abs() > time.Millisecond {
+			t.Errorf("%s: got wait %s, want %s", test.name, wait, test.wantWait)
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package util
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"termo_back_end/internal/entities"
 )
 
 // ReadBody reads the request body and unmarshals it into the given result. If anything goes wrong, it writes an error
@@ -78,3 +83,74 @@ func WriteResponseJSON[T any](w http.ResponseWriter, response T) {
 func WriteInternalError(w http.ResponseWriter) {
 	http.Error(w, "internal error", http.StatusInternalServerError)
 }
+
+// GetProxyHops returns how many addresses at the end of the X-Forwarded-For header were added by trusted reverse
+// proxies, as configured; 0 if the header isn't trusted
+func GetProxyHops(config entities.Config) uint32 {
+	if !config.Server.TrustProxy {
+		return 0
+	}
+	if config.Server.ProxyHops == 0 {
+		return 1
+	}
+	return config.Server.ProxyHops
+}
+
+// GetClientIP returns the IP address of the client that sent the request
+//
+// If proxyHops is not 0, the address added to the X-Forwarded-For header by the outermost of that many reverse proxies
+// is used when present, so only set it when the server is behind reverse proxies that append to this header
+func GetClientIP(r *http.Request, proxyHops uint32) string {
+	if forwarded := r.Header.Values("X-Forwarded-For"); proxyHops > 0 && len(forwarded) > 0 {
+		// Proxies append the address they got the request from, so only the last addresses can be trusted; the ones
+		// before them were sent by the client
+		addresses := strings.Split(strings.Join(forwarded, ","), ",")
+		i := max(len(addresses)-int(proxyHops), 0)
+		return strings.TrimSpace(addresses[i])
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// CheckOrigin checks whether a request was sent from the server's own origin or one of the allowed origins, ignoring
+// upper/lowercase letters. Requests without an Origin header don't come from browsers, so other sites can't make them
+// on a user's behalf, and are allowed
+func CheckOrigin(r *http.Request, allowedOrigins []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	for _, allowed := range allowedOrigins {
+		if strings.EqualFold(origin, allowed) {
+			return true
+		}
+	}
+
+	originURL, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(originURL.Host, r.Host)
+}
+
+// GetClientInfo returns data about the client that sent the request. See GetClientIP for proxyHops
+func GetClientInfo(r *http.Request, proxyHops uint32) entities.ClientInfo {
+	return entities.ClientInfo{
+		IP:        GetClientIP(r, proxyHops),
+		UserAgent: r.UserAgent(),
+	}
+}
+
+// WithClientInfo returns a copy of the context carrying the provided client data, so that services can tell who caused
+// an action
+func WithClientInfo(ctx context.Context, client entities.ClientInfo) context.Context {
+	return context.WithValue(ctx, "client", client)
+}
+
+// GetContextClientInfo returns the client data stored in the context by WithClientInfo; returns empty data if none
+func GetContextClientInfo(ctx context.Context) entities.ClientInfo {
+	client, _ := ctx.Value("client").(entities.ClientInfo)
+	return client
+}
@@ -31,3 +31,39 @@ func GetUser(r *http.Request) (*entities.User, error) {
 
 	return contextUser.(*entities.User), nil
 }
+
+// RequireRoles builds a middleware that only lets users with any of the provided roles through, answering everyone else
+// with 403 Forbidden. It expects the user in the request's context, so it must run after the session middleware
+//
+// Returns the handler as is if no roles are provided
+func RequireRoles(roles []entities.Role, next http.Handler) http.Handler {
+	if len(roles) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := GetUser(r)
+		if err != nil {
+			WriteInternalError(w)
+			return
+		}
+
+		if !user.HasRole(roles...) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetAuthToken attempts to retrieve the claims of the auth token used in the request, stored in the request's context
+func GetAuthToken(r *http.Request) (*entities.AuthToken, error) {
+	contextToken := r.Context().Value("token")
+	if contextToken == nil {
+		log.Printf("token not found in request")
+		return nil, fmt.Errorf("token not found in request")
+	}
+
+	return contextToken.(*entities.AuthToken), nil
+}
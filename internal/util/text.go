@@ -8,6 +8,7 @@ import (
 	"math/rand"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // ErrInvalidSize is returned when an invalid size is passed
@@ -45,6 +46,16 @@ func (w WordMap) CleanWord(word string) string {
 //   - If there are no words with the specified size, returns ErrInvalidSize
 //   - If there aren't enough words with the specified size and count, returns ErrNotEnoughWords
 func (w WordMap) ChooseRandom(wordLength, count uint32) ([]string, error) {
+	return w.choose(wordLength, count, rand.Shuffle)
+}
+
+// ChooseSeeded works like ChooseRandom, but the chosen words only depend on the provided seed, meaning the same seed
+// always returns the same words for the same word list
+func (w WordMap) ChooseSeeded(wordLength, count uint32, seed int64) ([]string, error) {
+	return w.choose(wordLength, count, rand.New(rand.NewSource(seed)).Shuffle)
+}
+
+func (w WordMap) choose(wordLength, count uint32, shuffle func(n int, swap func(i, j int))) ([]string, error) {
 	// Ensure wordLength is between min/max sizes
 	if wordLength < w.minSize || wordLength > w.maxSize {
 		return nil, ErrInvalidSize
@@ -66,7 +77,7 @@ func (w WordMap) ChooseRandom(wordLength, count uint32) ([]string, error) {
 	copy(copied, words)
 
 	// Shuffle in-place
-	rand.Shuffle(len(copied), func(i, j int) {
+	shuffle(len(copied), func(i, j int) {
 		copied[i], copied[j] = copied[j], copied[i]
 	})
 
@@ -99,7 +110,7 @@ func WordMapFromList(words []string) WordMap {
 		if _, ok := sizeMap[wordLen]; !ok {
 			sizeMap[wordLen] = make([]string, 0)
 		}
-		sizeMap[wordLen] = append(sizeMap[wordLen], word)
+		sizeMap[wordLen] = append(sizeMap[wordLen], cleaned)
 
 		// Update min/max sizes
 		if wordLen < minSize {
@@ -134,3 +145,11 @@ func RemoveWhitespace(text string) string {
 		return r
 	}, text)
 }
+
+// TruncateRunes cuts a text to at most maxLength characters
+func TruncateRunes(text string, maxLength int) string {
+	if utf8.RuneCountInString(text) <= maxLength {
+		return text
+	}
+	return string([]rune(text)[:maxLength])
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/handlers"
@@ -13,6 +14,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"termo_back_end/internal/entities"
 	"termo_back_end/internal/router"
 	"termo_back_end/internal/util"
@@ -44,9 +46,41 @@ func loadWordListFile() ([]string, error) {
 	return words, nil
 }
 
+// loadCommonPasswordsFile loads the passwords nobody can use, one per line, from the provided file. Returns no
+// passwords if no file is configured
+func loadCommonPasswordsFile(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("[os.Open] | %v", err)
+	}
+	defer util.DeferFileClose(file)
+
+	var passwords []string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		password := strings.TrimSpace(scanner.Text())
+		if password != "" {
+			passwords = append(passwords, password)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("[scanner.Scan] | %v", err)
+	}
+
+	log.Printf("loaded %d common passwords", len(passwords))
+
+	return passwords, nil
+}
+
 func openDB(config entities.Config) (*sql.DB, error) {
 	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%d)/%s",
+		"%s:%s@tcp(%s:%d)/%s?parseTime=true",
 		config.Database.User,
 		config.Database.Password,
 		config.Database.Host,
@@ -113,7 +147,33 @@ func createServer(router *mux.Router) *http.Server {
 	return &server
 }
 
+// runGenerateKey implements the genkey subcommand, which prints a new auth key pair ready to be added to the keyring
+// in config.json
+func runGenerateKey(args []string) {
+	flags := flag.NewFlagSet("genkey", flag.ExitOnError)
+	id := flags.String("id", time.Now().UTC().Format("20060102150405"), "ID of the new key")
+	_ = flags.Parse(args)
+
+	bytes, err := json.MarshalIndent(util.GenerateAuthKey(*id), "", "  ")
+	if err != nil {
+		log.Fatalf("[json.MarshalIndent] | %v", err)
+	}
+
+	fmt.Println(string(bytes))
+}
+
 func main() {
+	// Handle subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "genkey":
+			runGenerateKey(os.Args[2:])
+			return
+		default:
+			log.Fatalf("unknown subcommand: %s", os.Args[1])
+		}
+	}
+
 	// Load the words-list file
 	words, err := loadWordListFile()
 	if err != nil {
@@ -134,6 +194,13 @@ func main() {
 		return
 	}
 
+	// Load the common passwords file, if any
+	commonPasswords, err := loadCommonPasswordsFile(config.Auth.PasswordPolicy.CommonPasswordsFile)
+	if err != nil {
+		log.Fatalf("[loadCommonPasswordsFile] | %v", err)
+		return
+	}
+
 	// Open database
 	db, err := openDB(*config)
 	if err != nil {
@@ -142,7 +209,7 @@ func main() {
 	}
 
 	// Set up all route handlers
-	r := router.Setup(*config, words, db)
+	r := router.Setup(*config, words, commonPasswords, db)
 
 	// Create server
 	server := createServer(r)